@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/feedback"
+	"github.com/nivthefox/inkwell/lint"
+	"github.com/nivthefox/inkwell/processor"
+)
+
+// runBuild compiles the book.
+func runBuild(args []string) error {
+	flags := newFlagSet("build", "Compiles the book into every output listed in the config.")
+	path := flags.String("config", defaultConfig, "path to the config file")
+	review := flags.Bool("review", false, "keep comments and changes, as margin comments and tracked changes")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.NewInkwellConfig(*path)
+	if err != nil {
+		return err
+	}
+	if *review {
+		cfg.Comments.Review = true
+	}
+
+	return processor.ProcessBook(*cfg)
+}
+
+// runStats prints the summary of the book.
+func runStats(args []string) error {
+	flags := newFlagSet("stats", "Prints the word and character counts of the book, its sections, parts,\nchapters and scenes, without writing any of the outputs.")
+	path := flags.String("config", defaultConfig, "path to the config file")
+	format := flags.String("format", "", "yaml, json, csv or markdown (default summary_format, or yaml)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.NewInkwellConfig(*path)
+	if err != nil {
+		return err
+	}
+	_, summary, err := processor.Compile(*cfg)
+	if err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = cfg.SummaryFormat
+	}
+	out, err := summary.Format(*format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// runLint checks the config and its source files.
+func runLint(args []string) error {
+	flags := newFlagSet("lint", "Checks the config and the source files it lists for problems, such as missing,\nempty or duplicated files. Exits with status 1 if any problem is found.")
+	path := flags.String("config", defaultConfig, "path to the config file")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.NewInkwellConfig(*path)
+	if err != nil {
+		return err
+	}
+
+	problems := lint.Check(*cfg)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems", len(problems))
+	}
+	return nil
+}
+
+// runLocate prints the source file and lines which a line or paragraph of an output came from.
+func runLocate(args []string) error {
+	description := "Prints the source file and lines which a line or paragraph of a Markdown output\ncame from, as recorded in the source map of the last build. The paragraph is\ngiven by its number or id as the output shows it, such as manuscript.md:<412>."
+	flags := newFlagSet("locate", description)
+	flags.Usage = func() {
+		w := flags.Output()
+		fmt.Fprintf(w, "Usage: inkwell locate [flags] <output-file>:<line|paragraph>\n\n%s\n\nFlags:\n", description)
+		flags.PrintDefaults()
+	}
+	path := flags.String("config", defaultConfig, "path to the config file")
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if err != nil {
+		return usageError{err}
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return usageError{errors.New("want a single position, such as manuscript.md:120")}
+	}
+
+	idx := strings.LastIndex(flags.Arg(0), ":")
+	if idx < 0 {
+		flags.Usage()
+		return usageError{fmt.Errorf("invalid position %q: want <output-file>:<line|paragraph>", flags.Arg(0))}
+	}
+	output, position := flags.Arg(0)[:idx], flags.Arg(0)[idx+1:]
+
+	cfg, err := config.NewInkwellConfig(*path)
+	if err != nil {
+		return err
+	}
+	if cfg.SourceMap == "" {
+		return errors.New("the config has no source_map; add one and build the book")
+	}
+
+	sources, err := processor.ReadSourceMap(string(cfg.SourceMap))
+	if err != nil {
+		return err
+	}
+	paragraph, err := sources.Locate(output, position)
+	if err != nil {
+		return err
+	}
+
+	lines := strconv.Itoa(paragraph.SourceLines[0])
+	if paragraph.SourceLines[1] != paragraph.SourceLines[0] {
+		lines += "-" + strconv.Itoa(paragraph.SourceLines[1])
+	}
+	fmt.Println(paragraph.Source + ":" + lines)
+	return nil
+}
+
+// runFeedback runs the feedback subcommand named by the first argument.
+func runFeedback(args []string) error {
+	if len(args) == 0 || args[0] != "import" {
+		fmt.Fprintln(os.Stderr, "Usage: inkwell feedback import [flags] <comments-file>")
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			return flag.ErrHelp
+		}
+		return usageError{errors.New("want the import subcommand")}
+	}
+	return runFeedbackImport(args[1:])
+}
+
+// runFeedbackImport traces comments from readers to the source files of the paragraphs they
+// are about, and writes them into the source files or prints a report of them.
+func runFeedbackImport(args []string) error {
+	description := "Reads comments from readers on the numbered paragraphs of an output, traces each\n" +
+		"to its source file and lines through the source map of the last build, and writes\n" +
+		"them into the source files. The comments are read from a CSV file with paragraph,\n" +
+		"comment and reviewer columns, a YAML list of the same, or a text file with lines\n" +
+		"such as \"<57> this feels rushed\" or the comments of an annotated copy of the output."
+	flags := newFlagSet("feedback import", description)
+	flags.Usage = func() {
+		w := flags.Output()
+		fmt.Fprintf(w, "Usage: inkwell feedback import [flags] <comments-file>\n\n%s\n\nFlags:\n", description)
+		flags.PrintDefaults()
+	}
+	path := flags.String("config", defaultConfig, "path to the config file")
+	output := flags.String("output", "", "the output the paragraphs are numbered in (default output_filename)")
+	reviewer := flags.String("reviewer", "", "the name of the reader, for comments which do not give one")
+	to := flags.String("to", "critic", "critic, callout or report: write CriticMarkup comments or comment callouts\ninto the source files, or print a report of the comments by file")
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if err != nil {
+		return usageError{err}
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return usageError{errors.New("want a single file of comments")}
+	}
+	switch *to {
+	case "critic", "callout", "report":
+	default:
+		flags.Usage()
+		return usageError{fmt.Errorf("invalid -to %q: want critic, callout or report", *to)}
+	}
+
+	cfg, err := config.NewInkwellConfig(*path)
+	if err != nil {
+		return err
+	}
+	if cfg.SourceMap == "" {
+		return errors.New("the config has no source_map; add one and build the book")
+	}
+	if *output == "" {
+		*output = string(cfg.OutputFilename)
+	}
+
+	comments, err := feedback.Read(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	for idx := range comments {
+		if comments[idx].Reviewer == "" {
+			comments[idx].Reviewer = *reviewer
+		}
+	}
+	sources, err := processor.ReadSourceMap(string(cfg.SourceMap))
+	if err != nil {
+		return err
+	}
+	resolved, warnings := feedback.Resolve(comments, sources, *output)
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "warning: "+warning)
+	}
+
+	if *to == "report" {
+		fmt.Print(feedback.Report(resolved))
+		return nil
+	}
+	err = feedback.Insert(resolved, *to == "callout")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d of %d comments\n", len(resolved), len(comments))
+	return nil
+}
+
+const scaffold = `# Configuration for inkwell; see https://github.com/nivthefox/inkwell for every option.
+title: %s
+authors:
+  - %s
+summary: ""
+output_filename: manuscript.md
+summary_filename: summary.yaml
+chapters:
+  - title: Chapter 1
+    scenes:
+      - files:
+          - %s
+`
+
+const scaffoldScene = "chapters/01/01.md"
+
+// runInit writes a new config and a first scene.
+func runInit(args []string) error {
+	flags := newFlagSet("init", "Creates a config with a single chapter, and an empty first scene for it.")
+	path := flags.String("config", defaultConfig, "path of the config file to create")
+	title := flags.String("title", "", "title of the book (default the name of the current directory)")
+	author := flags.String("author", "Anonymous", "name of the author")
+	force := flags.Bool("force", false, "overwrite an existing config")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if *title == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		*title = filepath.Base(dir)
+	}
+
+	if _, err := os.Stat(*path); err == nil && !*force {
+		return fmt.Errorf("%s already exists; use -force to overwrite it", *path)
+	}
+
+	// the files a config lists are found from the working directory, so the scene beside the
+	// config is listed by its path from there
+	scene := filepath.Join(filepath.Dir(*path), scaffoldScene)
+	err := os.MkdirAll(filepath.Dir(*path), 0755)
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf(scaffold, strconv.Quote(*title), strconv.Quote(*author), filepath.ToSlash(scene))
+	err = os.WriteFile(*path, []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Println("created " + *path)
+
+	if _, err := os.Stat(scene); err == nil {
+		return nil
+	}
+	err = os.MkdirAll(filepath.Dir(scene), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(scene, []byte("Your story begins here.\n"), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Println("created " + scene)
+	return nil
+}
@@ -3,39 +3,211 @@ package config
 import (
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
 )
 
 // OutputFilename is a type that represents the name of the output file
 type OutputFilename string
 
+// defaultParagraphMapping is the name of the file next to the config which keeps the ids of the
+// paragraphs, unless the config names another
+const defaultParagraphMapping = ".inkwell-paragraphs.yaml"
+
+// DefaultLanguage is the language of a book whose config does not give one
+const DefaultLanguage = "en"
+
 // InkwellConfig is a struct that represents the configuration of the book
 type InkwellConfig struct {
-	Title   string   `yaml:"title"`
-	Summary string   `yaml:"summary"`
-	Authors []string `yaml:"authors"`
+	Title    string   `yaml:"title"`
+	Summary  string   `yaml:"summary"`
+	Authors  []string `yaml:"authors"`
+	Language string   `yaml:"language,omitempty"`
+
+	DedicationFilename string             `yaml:"dedication"`
+	SceneSeparator     string             `yaml:"scene_separator"`
+	SceneHeading       string             `yaml:"scene_heading,omitempty"`
+	ChapterNumbering   NumberingConfig    `yaml:"chapter_numbering,omitempty"`
+	TOC                TOCConfig          `yaml:"toc,omitempty"`
+	Typography         TypographyConfig   `yaml:"typography,omitempty"`
+	Sections           []SectionConfig    `yaml:"sections"`
+	Chapters           []ChapterConfig    `yaml:"chapters"`
+	ChaptersDirectory  string             `yaml:"chapters_directory,omitempty"`
+	Parts              []PartConfig       `yaml:"parts,omitempty"`
+	OutputFilename     OutputFilename     `yaml:"output_filename,omitempty"`
+	OutputEpub         OutputFilename     `yaml:"output_epub,omitempty"`
+	Epub               EpubConfig         `yaml:"epub,omitempty"`
+	OutputDocx         OutputFilename     `yaml:"output_docx,omitempty"`
+	Docx               DocxConfig         `yaml:"docx,omitempty"`
+	OutputHTML         OutputFilename     `yaml:"output_html,omitempty"`
+	HTML               HTMLConfig         `yaml:"html,omitempty"`
+	OutputPDF          OutputFilename     `yaml:"output_pdf,omitempty"`
+	PDF                PDFConfig          `yaml:"pdf,omitempty"`
+	OutputLatex        OutputFilename     `yaml:"output_latex,omitempty"`
+	Latex              LatexConfig        `yaml:"latex,omitempty"`
+	OutputNumbers      bool               `yaml:"number_paragraphs,omitempty"`
+	SummaryFilename    OutputFilename     `yaml:"summary_filename,omitempty"`
+	SummaryFormat      string             `yaml:"summary_format,omitempty"`
+	SourceMap          OutputFilename     `yaml:"source_map,omitempty"`
+	StripWikiLinks     bool               `yaml:"strip_wiki_links,omitempty"`
+	WikiLinks          WikiLinksConfig    `yaml:"wiki_links,omitempty"`
+	Vault              string             `yaml:"vault,omitempty"`
+	Comments           CommentsConfig     `yaml:"comments,omitempty"`
+	ParagraphIDs       ParagraphIDsConfig `yaml:"paragraph_ids,omitempty"`
+	WordCount          WordCountConfig    `yaml:"word_count,omitempty"`
+
+	directories []string
+	glossary    []string
+	vault       []string
+}
+
+// NumberingConfig is a struct that represents how chapters are numbered and how the number is
+// written into their headings
+type NumberingConfig struct {
+	Style          string `yaml:"style,omitempty"`
+	Heading        string `yaml:"heading,omitempty"`
+	RestartPerPart bool   `yaml:"restart_per_part,omitempty"`
+}
+
+// TOCConfig is a struct that represents the options of the table of contents. A depth of zero
+// lists every level.
+type TOCConfig struct {
+	Title      string `yaml:"title,omitempty"`
+	Depth      int    `yaml:"depth,omitempty"`
+	Scenes     bool   `yaml:"scenes,omitempty"`
+	Manuscript bool   `yaml:"manuscript,omitempty"`
+}
+
+// TypographyConfig is a struct that represents the smart typography of the book or of one of
+// its outputs. The locale selects the quotation marks, and defaults to the language of the book.
+type TypographyConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Locale  string `yaml:"locale,omitempty"`
+}
+
+// WikiLinksConfig is a struct that represents how wiki links are resolved. A link to a note in
+// the glossary directory, rather than to a part of the book, adds the note to a glossary at the
+// back of the book.
+type WikiLinksConfig struct {
+	Glossary      string `yaml:"glossary,omitempty"`
+	GlossaryTitle string `yaml:"glossary_title,omitempty"`
+}
+
+// CommentsConfig is a struct that represents how the notes which authors leave in their source
+// files are handled. Obsidian and HTML comments are comments, as are the lines which start with
+// one of the markers, TODO: unless others are given. CriticMarkup changes are accepted unless
+// changes is reject. A review build keeps the comments and changes, to be shown as margin
+// comments and tracked changes by the outputs which can.
+type CommentsConfig struct {
+	Markers []string `yaml:"markers,omitempty"`
+	Changes string   `yaml:"changes,omitempty"`
+	Review  bool     `yaml:"review,omitempty"`
+}
 
-	DedicationFilename string          `yaml:"dedication"`
-	SceneSeparator     string          `yaml:"scene_separator"`
-	Sections           []SectionConfig `yaml:"sections"`
-	Chapters           []ChapterConfig `yaml:"chapters"`
-	OutputFilename     OutputFilename  `yaml:"output_filename,omitempty"`
-	OutputNumbers      bool            `yaml:"number_paragraphs,omitempty"`
-	SummaryFilename    OutputFilename  `yaml:"summary_filename,omitempty"`
-	StripWikiLinks     bool            `yaml:"strip_wiki_links,omitempty"`
+// ParagraphIDsConfig is a struct that represents the stable ids which number the paragraphs of
+// the outputs in place of their running numbers. The mapping file keeps the id of each paragraph
+// from one build to the next, and the report lists the ids which were changed, merged or deleted.
+type ParagraphIDsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Mapping string `yaml:"mapping,omitempty"`
+	Report  string `yaml:"report,omitempty"`
+}
+
+// WordCountConfig is a struct that represents how the words and characters of the book are
+// counted. The rule is word, scrivener, publisher or cjk, and defaults to word; characters are
+// counted as graphemes unless runes are asked for.
+type WordCountConfig struct {
+	Rule       string `yaml:"rule,omitempty"`
+	Characters string `yaml:"characters,omitempty"`
+}
+
+// EpubConfig is a struct that represents the options of the EPUB output
+type EpubConfig struct {
+	Ornament   string            `yaml:"ornament,omitempty"`
+	Typography *TypographyConfig `yaml:"typography,omitempty"`
+}
+
+// DocxConfig is a struct that represents the manuscript format options of the DOCX output
+type DocxConfig struct {
+	Font       string            `yaml:"font,omitempty"`
+	Surname    string            `yaml:"surname,omitempty"`
+	ShortTitle string            `yaml:"short_title,omitempty"`
+	Contact    []string          `yaml:"contact,omitempty"`
+	SceneBreak string            `yaml:"scene_break,omitempty"`
+	TOC        bool              `yaml:"toc,omitempty"`
+	Typography *TypographyConfig `yaml:"typography,omitempty"`
+}
+
+// HTMLConfig is a struct that represents the layout and theme options of the HTML output
+type HTMLConfig struct {
+	MultiPage  bool              `yaml:"multi_page,omitempty"`
+	Theme      string            `yaml:"theme,omitempty"`
+	Stylesheet string            `yaml:"stylesheet,omitempty"`
+	Typography *TypographyConfig `yaml:"typography,omitempty"`
+}
+
+// PDFConfig is a struct that represents the page geometry and typography of the PDF output.
+// Sizes and margins are in inches, and the font size is in points.
+type PDFConfig struct {
+	TrimSize       string            `yaml:"trim_size,omitempty"`
+	InsideMargin   float64           `yaml:"inside_margin,omitempty"`
+	OutsideMargin  float64           `yaml:"outside_margin,omitempty"`
+	TopMargin      float64           `yaml:"top_margin,omitempty"`
+	BottomMargin   float64           `yaml:"bottom_margin,omitempty"`
+	FontSize       float64           `yaml:"font_size,omitempty"`
+	LineHeight     float64           `yaml:"line_height,omitempty"`
+	DropCaps       bool              `yaml:"drop_caps,omitempty"`
+	Font           string            `yaml:"font,omitempty"`
+	BoldFont       string            `yaml:"bold_font,omitempty"`
+	ItalicFont     string            `yaml:"italic_font,omitempty"`
+	BoldItalicFont string            `yaml:"bold_italic_font,omitempty"`
+	Typography     *TypographyConfig `yaml:"typography,omitempty"`
+}
+
+// LatexConfig is a struct that represents the preamble and macros of the LaTeX output
+type LatexConfig struct {
+	Preamble   string            `yaml:"preamble,omitempty"`
+	SceneBreak string            `yaml:"scene_break,omitempty"`
+	Typography *TypographyConfig `yaml:"typography,omitempty"`
 }
 
 // SectionConfig is a struct that represents the configuration of a section
 type SectionConfig struct {
 	Title          string         `yaml:"title"`
+	Placement      string         `yaml:"placement,omitempty"`
+	ExcludeFromTOC bool           `yaml:"exclude_from_toc,omitempty"`
 	Files          []string       `yaml:"files"`
+	Directory      string         `yaml:"directory,omitempty"`
+	Sort           string         `yaml:"sort,omitempty"`
 	OutputFilename OutputFilename `yaml:"output_filename,omitempty"`
 	OutputNumbers  bool           `yaml:"number_paragraphs,omitempty"`
 }
 
+// PartConfig is a struct that represents the configuration of a part, which holds its own
+// sections, chapters and further parts. Its scene separator and scene heading apply to every
+// chapter within it which does not set its own.
+type PartConfig struct {
+	Title             string          `yaml:"title"`
+	SceneSeparator    string          `yaml:"scene_separator,omitempty"`
+	SceneHeading      string          `yaml:"scene_heading,omitempty"`
+	Sections          []SectionConfig `yaml:"sections"`
+	Chapters          []ChapterConfig `yaml:"chapters"`
+	ChaptersDirectory string          `yaml:"chapters_directory,omitempty"`
+	Parts             []PartConfig    `yaml:"parts,omitempty"`
+	OutputFilename    OutputFilename  `yaml:"output_filename,omitempty"`
+	OutputNumbers     bool            `yaml:"number_paragraphs,omitempty"`
+}
+
 // ChapterConfig is a struct that represents the configuration of a chapter
 type ChapterConfig struct {
 	Title          string `yaml:"title"`
+	Number         string `yaml:"number,omitempty"`
+	Unnumbered     bool   `yaml:"unnumbered,omitempty"`
+	ExcludeFromTOC bool   `yaml:"exclude_from_toc,omitempty"`
 	Scenes         []SceneConfig
+	Directory      string         `yaml:"directory,omitempty"`
+	Sort           string         `yaml:"sort,omitempty"`
+	SceneSeparator string         `yaml:"scene_separator,omitempty"`
+	SceneHeading   string         `yaml:"scene_heading,omitempty"`
 	OutputFilename OutputFilename `yaml:"output_filename,omitempty"`
 	OutputNumbers  bool           `yaml:"number_paragraphs,omitempty"`
 }
@@ -43,6 +215,8 @@ type ChapterConfig struct {
 // SceneConfig is a struct that represents the configuration of a scene
 type SceneConfig struct {
 	Files          []string       `yaml:"files"`
+	Directory      string         `yaml:"directory,omitempty"`
+	Sort           string         `yaml:"sort,omitempty"`
 	OutputFilename OutputFilename `yaml:"output_filename,omitempty"`
 	OutputNumbers  bool           `yaml:"number_paragraphs,omitempty"`
 }
@@ -66,5 +240,106 @@ func NewInkwellConfig(filename string) (*InkwellConfig, error) {
 		config.SceneSeparator = "*&#9;*&#9;*"
 	}
 
+	if config.Language == "" {
+		config.Language = DefaultLanguage
+	}
+
+	if config.ParagraphIDs.Mapping == "" {
+		config.ParagraphIDs.Mapping = filepath.Join(filepath.Dir(filename), defaultParagraphMapping)
+	}
+
+	err = config.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	inherit(config.Chapters, config.Parts, config.SceneSeparator, config.SceneHeading)
+
 	return &config, nil
 }
+
+// inherit fills in the scene separator and scene heading of the chapters and parts which do
+// not set their own with those of the book or part which holds them.
+func inherit(chapters []ChapterConfig, parts []PartConfig, separator string, heading string) {
+	for idx := range chapters {
+		if chapters[idx].SceneSeparator == "" {
+			chapters[idx].SceneSeparator = separator
+		}
+		if chapters[idx].SceneHeading == "" {
+			chapters[idx].SceneHeading = heading
+		}
+	}
+
+	for idx := range parts {
+		part := &parts[idx]
+		if part.SceneSeparator == "" {
+			part.SceneSeparator = separator
+		}
+		if part.SceneHeading == "" {
+			part.SceneHeading = heading
+		}
+		inherit(part.Chapters, part.Parts, part.SceneSeparator, part.SceneHeading)
+	}
+}
+
+// SourceFiles returns the paths of every source file listed in the configuration, in book order,
+// followed by the notes in the glossary and the files in the vault
+func (c *InkwellConfig) SourceFiles() []string {
+	var files []string
+	if c.DedicationFilename != "" {
+		files = append(files, c.DedicationFilename)
+	}
+	files = append(files, contentFiles(c.Sections, c.Chapters, c.Parts)...)
+	files = append(files, c.glossary...)
+	return append(files, c.vault...)
+}
+
+// TypographyFor returns the typography of an output, which is that of the book unless the
+// output gives its own. An output which leaves out the locale uses the locale of the book, and
+// a book without one uses its language.
+func (c *InkwellConfig) TypographyFor(output *TypographyConfig) TypographyConfig {
+	typography := c.Typography
+	if output != nil {
+		locale := typography.Locale
+		typography = *output
+		if typography.Locale == "" {
+			typography.Locale = locale
+		}
+	}
+	if typography.Locale == "" {
+		typography.Locale = c.Language
+	}
+	return typography
+}
+
+// ChapterCount returns the number of chapters in the book, including those within parts
+func (c *InkwellConfig) ChapterCount() int {
+	return chapterCount(c.Chapters, c.Parts)
+}
+
+// contentFiles returns the source files of the sections and chapters, followed by those of
+// the parts
+func contentFiles(sections []SectionConfig, chapters []ChapterConfig, parts []PartConfig) []string {
+	var files []string
+	for _, section := range sections {
+		files = append(files, section.Files...)
+	}
+	for _, chapter := range chapters {
+		for _, scene := range chapter.Scenes {
+			files = append(files, scene.Files...)
+		}
+	}
+	for _, part := range parts {
+		files = append(files, contentFiles(part.Sections, part.Chapters, part.Parts)...)
+	}
+	return files
+}
+
+// chapterCount returns the number of chapters, including those within the parts
+func chapterCount(chapters []ChapterConfig, parts []PartConfig) int {
+	count := len(chapters)
+	for _, part := range parts {
+		count += chapterCount(part.Chapters, part.Parts)
+	}
+	return count
+}
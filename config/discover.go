@@ -0,0 +1,437 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceExtensions are the extensions of the files discovered in a directory
+var sourceExtensions = map[string]bool{".md": true, ".markdown": true}
+
+// discover expands the glob patterns and directories in the configuration into lists of files,
+// derives chapters and scenes from directories, and finds the files in the vault and the notes
+// in the glossary. The directories it reads are recorded so they can be watched for new files.
+func (c *InkwellConfig) discover() error {
+	c.directories = nil
+
+	chapters, err := c.discoverContents(c.Sections, c.Chapters, c.ChaptersDirectory, c.Parts)
+	if err != nil {
+		return err
+	}
+	c.Chapters = chapters
+
+	c.vault = nil
+	if c.Vault != "" {
+		files, err := c.glob(filepath.Join(c.Vault, "**", "*"))
+		if err != nil {
+			return err
+		}
+		c.vault = files
+	}
+
+	c.glossary = nil
+	if c.WikiLinks.Glossary != "" {
+		notes, err := c.glob(filepath.Join(c.WikiLinks.Glossary, "**", "*"))
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			if sourceExtensions[strings.ToLower(filepath.Ext(note))] {
+				c.glossary = append(c.glossary, note)
+			}
+		}
+	}
+
+	return nil
+}
+
+// discoverContents expands the sections and chapters of the book or of a part, and then those
+// of each part within it. It returns the chapters with any found in the chapters directory
+// appended.
+func (c *InkwellConfig) discoverContents(sections []SectionConfig, chapters []ChapterConfig, chaptersDirectory string, parts []PartConfig) ([]ChapterConfig, error) {
+	for idx := range sections {
+		section := &sections[idx]
+		files, err := c.expand(section.Files, section.Directory, section.Sort)
+		if err != nil {
+			return nil, err
+		}
+		section.Files = files
+	}
+
+	if chaptersDirectory != "" {
+		entries, err := c.list(chaptersDirectory, "", true)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if info, err := os.Stat(entry); err == nil && info.IsDir() {
+				chapters = append(chapters, ChapterConfig{Title: directoryTitle(entry), Directory: entry})
+			}
+		}
+	}
+
+	for idx := range chapters {
+		chapter := &chapters[idx]
+		for sidx := range chapter.Scenes {
+			scene := &chapter.Scenes[sidx]
+			files, err := c.expand(scene.Files, scene.Directory, scene.Sort)
+			if err != nil {
+				return nil, err
+			}
+			scene.Files = files
+		}
+
+		if chapter.Directory == "" {
+			continue
+		}
+
+		// each file in the directory is a scene, as is each subdirectory
+		entries, err := c.list(chapter.Directory, chapter.Sort, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			info, err := os.Stat(entry)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				chapter.Scenes = append(chapter.Scenes, SceneConfig{Files: []string{entry}})
+				continue
+			}
+			files, err := c.list(entry, chapter.Sort, false)
+			if err != nil {
+				return nil, err
+			}
+			if len(files) > 0 {
+				chapter.Scenes = append(chapter.Scenes, SceneConfig{Files: files})
+			}
+		}
+	}
+
+	for idx := range parts {
+		part := &parts[idx]
+		found, err := c.discoverContents(part.Sections, part.Chapters, part.ChaptersDirectory, part.Parts)
+		if err != nil {
+			return nil, err
+		}
+		part.Chapters = found
+	}
+
+	return chapters, nil
+}
+
+// VaultFiles returns the notes and other files found in the vault, which may be embedded in
+// the source files
+func (c *InkwellConfig) VaultFiles() []string {
+	return c.vault
+}
+
+// GlossaryFiles returns the notes found in the glossary directory
+func (c *InkwellConfig) GlossaryFiles() []string {
+	return c.glossary
+}
+
+// Directories returns the directories which were read to discover source files
+func (c *InkwellConfig) Directories() []string {
+	return c.directories
+}
+
+// expand replaces the glob patterns in the list of files with the files they match, and
+// appends the files in the directory. Paths without pattern characters are kept as they are,
+// and a pattern which matches no files is an error, since it most likely has a typo.
+func (c *InkwellConfig) expand(files []string, directory string, rule string) ([]string, error) {
+	var expanded []string
+	for _, file := range files {
+		if !strings.ContainsAny(file, "*?[") {
+			expanded = append(expanded, file)
+			continue
+		}
+		matches, err := c.glob(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern %q matches no files", file)
+		}
+		sorted, err := sortFiles(matches, rule)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, sorted...)
+	}
+
+	if directory != "" {
+		listed, err := c.list(directory, rule, false)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, listed...)
+	}
+
+	return expanded, nil
+}
+
+// list returns the source files in the directory in sorted order, along with its
+// subdirectories if they are requested. Hidden entries are skipped.
+func (c *InkwellConfig) list(directory string, rule string, subdirectories bool) ([]string, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	c.directories = append(c.directories, directory)
+
+	var paths []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.IsDir() {
+			if subdirectories {
+				paths = append(paths, filepath.Join(directory, entry.Name()))
+			}
+			continue
+		}
+		if sourceExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			paths = append(paths, filepath.Join(directory, entry.Name()))
+		}
+	}
+
+	return sortFiles(paths, rule)
+}
+
+// glob returns the files which match the pattern. In addition to the syntax of filepath.Match,
+// a path segment of ** matches any number of directories.
+func (c *InkwellConfig) glob(pattern string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	base := 0
+	for base < len(segments)-1 && !strings.ContainsAny(segments[base], "*?[") {
+		base++
+	}
+	root := strings.Join(segments[:base], "/")
+	if root == "" && strings.HasPrefix(pattern, "/") {
+		root = "/"
+	}
+	if root == "" {
+		root = "."
+	}
+	rest := segments[base:]
+	for _, segment := range rest {
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == filepath.FromSlash(root) && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(filepath.FromSlash(root), path)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if rel == "." {
+			parts = nil
+		}
+		if len(parts) > 0 && strings.HasPrefix(parts[len(parts)-1], ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() {
+			if !matchPrefix(rest, parts) {
+				return filepath.SkipDir
+			}
+			c.directories = append(c.directories, path)
+			return nil
+		}
+		if matchSegments(rest, parts) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// matchSegments reports whether the path segments match the pattern segments.
+func matchSegments(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(path); skip++ {
+			if matchSegments(pattern[1:], path[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], path[0])
+	return ok && matchSegments(pattern[1:], path[1:])
+}
+
+// matchPrefix reports whether the directory could contain files matching the pattern.
+func matchPrefix(pattern []string, path []string) bool {
+	for idx, segment := range path {
+		if idx >= len(pattern)-1 {
+			return false
+		}
+		if pattern[idx] == "**" {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern[idx], segment); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sortFiles sorts the paths by the given rule: natural (the default), alphabetical, modified,
+// or order, which sorts by the order key in the front matter of each file.
+func sortFiles(paths []string, rule string) ([]string, error) {
+	sorted := append([]string{}, paths...)
+	switch rule {
+	case "", "natural":
+		sort.SliceStable(sorted, func(i, j int) bool { return naturalLess(sorted[i], sorted[j]) })
+
+	case "alphabetical":
+		sort.Strings(sorted)
+
+	case "modified":
+		times := map[string]int64{}
+		for _, path := range sorted {
+			if info, err := os.Stat(path); err == nil {
+				times[path] = info.ModTime().UnixNano()
+			}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if times[sorted[i]] != times[sorted[j]] {
+				return times[sorted[i]] < times[sorted[j]]
+			}
+			return naturalLess(sorted[i], sorted[j])
+		})
+
+	case "order":
+		orders := map[string]*float64{}
+		for _, path := range sorted {
+			orders[path] = frontMatterOrder(path)
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := orders[sorted[i]], orders[sorted[j]]
+			switch {
+			case a != nil && b != nil && *a != *b:
+				return *a < *b
+			case a != nil && b == nil:
+				return true
+			case a == nil && b != nil:
+				return false
+			}
+			return naturalLess(sorted[i], sorted[j])
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown sort rule %q", rule)
+	}
+	return sorted, nil
+}
+
+// naturalLess compares the strings so that runs of digits are ordered by their numeric value,
+// and letters without regard to case.
+func naturalLess(a string, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	if len(ra)-i != len(rb)-j {
+		return len(ra)-i < len(rb)-j
+	}
+	return a < b
+}
+
+// frontMatterOrder returns the order key from the YAML front matter of the file, if it has one.
+func frontMatterOrder(path string) *float64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return nil
+	}
+	builder := &strings.Builder{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); trimmed == "---" || trimmed == "..." {
+			var meta struct {
+				Order *float64 `yaml:"order"`
+			}
+			if yaml.Unmarshal([]byte(builder.String()), &meta) != nil {
+				return nil
+			}
+			return meta.Order
+		}
+		builder.WriteString(line + "\n")
+	}
+	return nil
+}
+
+// directoryTitle returns the title of a chapter derived from its directory, without any
+// leading number used to order the directories.
+func directoryTitle(directory string) string {
+	name := filepath.Base(directory)
+	title := strings.TrimLeftFunc(name, unicode.IsDigit)
+	if title == name {
+		return name
+	}
+	title = strings.TrimLeft(title, " -_.")
+	if title == "" {
+		return name
+	}
+	return title
+}
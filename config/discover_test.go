@@ -0,0 +1,270 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates the files under the directory with the given contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// inDir changes into the directory for the rest of the test.
+func inDir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestNaturalLess(t *testing.T) {
+	files := []string{"scene 10.md", "Scene 2.md", "scene 1.md", "appendix.md", "scene 02a.md"}
+	sorted, err := sortFiles(files, "natural")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"appendix.md", "scene 1.md", "Scene 2.md", "scene 02a.md", "scene 10.md"}
+	if !reflect.DeepEqual(sorted, want) {
+		t.Errorf("sortFiles() = %v, want %v", sorted, want)
+	}
+}
+
+func TestSortFilesByOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.md": "---\norder: 2\n---\nA",
+		"b.md": "---\norder: 1.5\n---\nB",
+		"c.md": "C",
+	})
+	paths := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md"), filepath.Join(dir, "c.md")}
+
+	sorted, err := sortFiles(paths, "order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{paths[1], paths[0], paths[2]}
+	if !reflect.DeepEqual(sorted, want) {
+		t.Errorf("sortFiles() = %v, want %v", sorted, want)
+	}
+
+	if _, err := sortFiles(paths, "random"); err == nil {
+		t.Error("sortFiles() should reject an unknown rule")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"chapter 3/10.md":          "",
+		"chapter 3/2.md":           "",
+		"chapter 3/notes.txt":      "",
+		"chapter 3/drafts/old.md":  "",
+		"chapter 4/1.md":           "",
+		"chapter 4/.hidden/one.md": "",
+	})
+	inDir(t, dir)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "chapter 3/*.md", want: []string{"chapter 3/2.md", "chapter 3/10.md"}},
+		{pattern: "**/*.md", want: []string{"chapter 3/2.md", "chapter 3/10.md", "chapter 3/drafts/old.md", "chapter 4/1.md"}},
+		{pattern: "chapter */1*.md", want: []string{"chapter 3/10.md", "chapter 4/1.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			c := &InkwellConfig{}
+			files, err := c.expand([]string{tt.pattern}, "", "")
+			if err != nil {
+				t.Fatalf("expand() error = %v", err)
+			}
+			var got []string
+			for _, file := range files {
+				got = append(got, filepath.ToSlash(file))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expand(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+	for _, pattern := range []string{"missing/*.md", "chapter 3/*.markdown"} {
+		c := &InkwellConfig{}
+		if _, err := c.expand([]string{pattern}, "", ""); err == nil {
+			t.Errorf("expand(%q) should fail for a pattern which matches no files", pattern)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"front/preface.md":             "",
+		"book/01 Arrival/1 dock.md":    "",
+		"book/01 Arrival/2 inn/a.md":   "",
+		"book/01 Arrival/2 inn/b.md":   "",
+		"book/02 - Departure/scene.md": "",
+		"book/10/scene.md":             "",
+		"book/notes.md":                "",
+		"extra/scene 2.md":             "",
+		"extra/scene 1.md":             "",
+		"intro.md":                     "",
+	})
+	inDir(t, dir)
+
+	c := &InkwellConfig{
+		Sections:          []SectionConfig{{Title: "Preface", Directory: "front"}},
+		Chapters:          []ChapterConfig{{Title: "Prologue", Scenes: []SceneConfig{{Files: []string{"intro.md", "extra/*.md"}}}}},
+		ChaptersDirectory: "book",
+	}
+	if err := c.discover(); err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(c.Sections[0].Files, []string{filepath.Join("front", "preface.md")}) {
+		t.Errorf("section files = %v", c.Sections[0].Files)
+	}
+
+	var titles []string
+	for _, chapter := range c.Chapters {
+		titles = append(titles, chapter.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"Prologue", "Arrival", "Departure", "10"}) {
+		t.Errorf("chapter titles = %v", titles)
+	}
+
+	prologue := c.Chapters[0].Scenes[0].Files
+	if !reflect.DeepEqual(prologue, []string{"intro.md", filepath.Join("extra", "scene 1.md"), filepath.Join("extra", "scene 2.md")}) {
+		t.Errorf("prologue files = %v", prologue)
+	}
+
+	arrival := c.Chapters[1].Scenes
+	if len(arrival) != 2 || len(arrival[0].Files) != 1 || len(arrival[1].Files) != 2 {
+		t.Errorf("arrival scenes = %+v, want a scene per file and per subdirectory", arrival)
+	}
+
+	if len(c.Directories()) == 0 {
+		t.Error("Directories() should list the directories which were read")
+	}
+}
+
+func TestDiscoverParts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"part one/01 Arrival/dock.md":  "",
+		"part one/02 Departure/sea.md": "",
+		"part two/interlude.md":        "",
+	})
+	inDir(t, dir)
+
+	c := &InkwellConfig{
+		Parts: []PartConfig{
+			{Title: "Part One", ChaptersDirectory: "part one"},
+			{Title: "Part Two", Parts: []PartConfig{
+				{Title: "Book Three", Sections: []SectionConfig{{Title: "Interlude", Files: []string{"part two/*.md"}}}},
+			}},
+		},
+	}
+	if err := c.discover(); err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+
+	chapters := c.Parts[0].Chapters
+	if len(chapters) != 2 || chapters[0].Title != "Arrival" || len(chapters[1].Scenes) != 1 {
+		t.Errorf("part chapters = %+v, want a chapter per subdirectory of the part", chapters)
+	}
+	if files := c.Parts[1].Parts[0].Sections[0].Files; !reflect.DeepEqual(files, []string{filepath.Join("part two", "interlude.md")}) {
+		t.Errorf("nested part section files = %v", files)
+	}
+	if c.ChapterCount() != 2 || len(c.SourceFiles()) != 3 {
+		t.Errorf("ChapterCount() = %d, SourceFiles() = %v, want the contents of the parts", c.ChapterCount(), c.SourceFiles())
+	}
+}
+
+func TestDiscoverGlossary(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"wiki/Dragons.md":         "",
+		"wiki/places/Harbor.md":   "",
+		"wiki/places/map.png":     "",
+		"wiki/.obsidian/notes.md": "",
+	})
+	inDir(t, dir)
+
+	c := &InkwellConfig{WikiLinks: WikiLinksConfig{Glossary: "wiki"}}
+	if err := c.discover(); err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+
+	expected := []string{filepath.Join("wiki", "Dragons.md"), filepath.Join("wiki", "places", "Harbor.md")}
+	if !reflect.DeepEqual(c.GlossaryFiles(), expected) {
+		t.Errorf("GlossaryFiles() = %v, want %v", c.GlossaryFiles(), expected)
+	}
+	if !reflect.DeepEqual(c.SourceFiles(), expected) {
+		t.Errorf("SourceFiles() = %v, want the glossary notes", c.SourceFiles())
+	}
+}
+
+func TestDiscoverVault(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"vault/Backstory.md":       "",
+		"vault/maps/harbor.png":    "",
+		"vault/.obsidian/app.json": "",
+	})
+	inDir(t, dir)
+
+	c := &InkwellConfig{Vault: "vault"}
+	if err := c.discover(); err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+
+	expected := []string{filepath.Join("vault", "Backstory.md"), filepath.Join("vault", "maps", "harbor.png")}
+	if !reflect.DeepEqual(c.VaultFiles(), expected) {
+		t.Errorf("VaultFiles() = %v, want %v", c.VaultFiles(), expected)
+	}
+	if !reflect.DeepEqual(c.SourceFiles(), expected) {
+		t.Errorf("SourceFiles() = %v, want the files in the vault", c.SourceFiles())
+	}
+}
+
+func TestInherit(t *testing.T) {
+	chapters := []ChapterConfig{{Title: "One"}}
+	parts := []PartConfig{
+		{Title: "Part One", SceneSeparator: "~", Chapters: []ChapterConfig{{Title: "Two"}, {Title: "Three", SceneSeparator: "#"}}},
+		{Title: "Part Two", Parts: []PartConfig{{Title: "Book Three", Chapters: []ChapterConfig{{Title: "Four"}}}}},
+	}
+
+	inherit(chapters, parts, "* * *", "{{title}}")
+
+	separators := []string{
+		chapters[0].SceneSeparator,
+		parts[0].Chapters[0].SceneSeparator,
+		parts[0].Chapters[1].SceneSeparator,
+		parts[1].Parts[0].Chapters[0].SceneSeparator,
+	}
+	if !reflect.DeepEqual(separators, []string{"* * *", "~", "#", "* * *"}) {
+		t.Errorf("scene separators = %v, want each chapter to inherit from the part holding it", separators)
+	}
+	if parts[1].Parts[0].Chapters[0].SceneHeading != "{{title}}" {
+		t.Error("inherit() should fill in the scene heading of nested chapters")
+	}
+}
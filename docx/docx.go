@@ -0,0 +1,600 @@
+package docx
+
+import (
+	"archive/zip"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+	"github.com/nivthefox/inkwell/wordcount"
+)
+
+// run is a struct that represents a span of text sharing the same formatting. A run within a
+// tracked change is marked as an insertion or a deletion, and a comment is a run of its own;
+// both carry their id in the document.
+type run struct {
+	text    string
+	italic  bool
+	bold    bool
+	change  string
+	comment bool
+	id      int
+}
+
+// entry is a struct that represents a single file in the package
+type entry struct {
+	name    string
+	content string
+}
+
+const (
+	// measurements are in twentieths of a point
+	pageWidth   = 12240
+	pageHeight  = 15840
+	margin      = 1440
+	indent      = 720
+	textWidth   = pageWidth - 2*margin
+	lineSpacing = 480
+)
+
+// Write renders the book in Standard Manuscript Format and writes it to the file with the given filename.
+// Insertions, deletions and comments in the book, as kept by a review build, become tracked
+// changes and comments.
+func Write(book *manuscript.Book, options config.DocxConfig, words int, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	book, notes := annotate(book)
+
+	archive := zip.NewWriter(file)
+	entries := []entry{
+		{"[Content_Types].xml", contentTypes(options.TOC, len(notes) > 0)},
+		{"_rels/.rels", packageRelationships()},
+		{"docProps/core.xml", coreProperties(book)},
+		{"word/_rels/document.xml.rels", documentRelationships(options.TOC, len(notes) > 0)},
+		{"word/styles.xml", styles(font(options))},
+		{"word/header1.xml", header(surname(book, options), shortTitle(book, options))},
+		{"word/document.xml", document(book, options, words)},
+	}
+	if options.TOC {
+		// the table of contents is filled in with page numbers when the document is opened
+		entries = append(entries, entry{"word/settings.xml", settings()})
+	}
+	if len(notes) > 0 {
+		entries = append(entries, entry{"word/comments.xml", comments(notes)})
+	}
+
+	for _, e := range entries {
+		w, werr := archive.Create(e.name)
+		if werr != nil {
+			return werr
+		}
+		_, werr = w.Write([]byte(e.content))
+		if werr != nil {
+			return werr
+		}
+	}
+
+	return archive.Close()
+}
+
+// annotate returns a copy of the book in which each insertion, deletion and comment is
+// numbered with its id in the document, which is kept in its level, along with the comments
+// in the order of their ids.
+func annotate(book *manuscript.Book) (*manuscript.Book, []run) {
+	book = book.Clone()
+	var notes []run
+	id := 0
+	for _, entry := range book.Contents() {
+		var docs []*markdown.Node
+		switch {
+		case entry.Section != nil:
+			docs = append(docs, entry.Section.Document)
+		case entry.Chapter != nil:
+			for _, scene := range entry.Chapter.Scenes {
+				docs = append(docs, scene.Document)
+			}
+		}
+		for _, doc := range docs {
+			markdown.Walk(doc, func(n *markdown.Node) bool {
+				switch n.Kind {
+				case markdown.Insertion, markdown.Deletion:
+					id++
+					n.Level = id
+				case markdown.Comment:
+					id++
+					n.Level = id
+					notes = append(notes, run{text: n.Literal, comment: true, id: id})
+				}
+				return true
+			})
+		}
+	}
+	return book, notes
+}
+
+// font returns the name of the typeface selected in the options.
+func font(options config.DocxConfig) string {
+	if strings.EqualFold(options.Font, "times") || strings.EqualFold(options.Font, "times new roman") {
+		return "Times New Roman"
+	}
+	return "Courier New"
+}
+
+// surname returns the author surname to use in the running header.
+func surname(book *manuscript.Book, options config.DocxConfig) string {
+	if options.Surname != "" {
+		return options.Surname
+	}
+	if len(book.Authors) == 0 {
+		return ""
+	}
+	names := strings.Fields(book.Authors[0])
+	if len(names) == 0 {
+		return ""
+	}
+	return names[len(names)-1]
+}
+
+// shortTitle returns the title keyword to use in the running header.
+func shortTitle(book *manuscript.Book, options config.DocxConfig) string {
+	if options.ShortTitle != "" {
+		return options.ShortTitle
+	}
+	return book.Title
+}
+
+// sceneBreak returns the text centered between the scenes of the chapter, or for a break
+// within a section when the chapter is nil. Manuscript format asks for a #, unless the options
+// give other text, or "separator" for the separator of the chapter.
+func sceneBreak(book *manuscript.Book, chapter *manuscript.Chapter, options config.DocxConfig) string {
+	switch options.SceneBreak {
+	case "":
+		return "#"
+	case "separator":
+		return markdown.PlainText(book.SceneBreak(chapter))
+	}
+	return options.SceneBreak
+}
+
+// document returns the contents of word/document.xml.
+func document(book *manuscript.Book, options config.DocxConfig, words int) string {
+	body := &strings.Builder{}
+
+	// First page: contact information on the left, word count on the right
+	author := ""
+	if len(book.Authors) > 0 {
+		author = book.Authors[0]
+	}
+	count := "about " + formatNumber(wordcount.Round(words)) + " words"
+	body.WriteString(`<w:p><w:pPr><w:tabs><w:tab w:val="right" w:pos="` + strconv.Itoa(textWidth) + `"/></w:tabs>` +
+		`<w:spacing w:line="240" w:lineRule="auto"/></w:pPr>` +
+		runs([]run{{text: author + "\t" + count}}) + "</w:p>")
+	for _, line := range options.Contact {
+		body.WriteString(`<w:p><w:pPr><w:spacing w:line="240" w:lineRule="auto"/></w:pPr>` + runs([]run{{text: line}}) + "</w:p>")
+	}
+
+	// Title and byline, about halfway down the page
+	body.WriteString(`<w:p><w:pPr><w:spacing w:before="4320"/><w:jc w:val="center"/></w:pPr>` + runs([]run{{text: book.Title}}) + "</w:p>")
+	if len(book.Authors) > 0 {
+		body.WriteString(centered([]run{{text: "by " + strings.Join(book.Authors, ", ")}}))
+	}
+
+	// with a table of contents, each listed heading and scene carries a field which marks
+	// where its entry points
+	fields := map[any]string{}
+	first := true
+	if options.TOC {
+		entries := book.TableOfContents()
+		for _, entry := range entries {
+			switch {
+			case entry.Scene != nil:
+				fields[entry.Scene] = entryField(entry)
+			case entry.Section != nil:
+				fields[entry.Section] = entryField(entry)
+			case entry.Part != nil:
+				fields[entry.Part] = entryField(entry)
+			default:
+				fields[entry.Chapter] = entryField(entry)
+			}
+		}
+		body.WriteString(tableOfContents(book.TOCTitle(), entries))
+		first = false
+	}
+
+	for _, entry := range book.Contents() {
+		if section := entry.Section; section != nil {
+			body.WriteString(chapterHeading(section.Title, first, fields[section]))
+			first = false
+			writeBlocks(body, section.Document, 0, sceneBreak(book, nil, options))
+			continue
+		}
+		if part := entry.Part; part != nil {
+			// a part is a page of its own with only its title
+			body.WriteString(chapterHeading(part.Title, first, fields[part]))
+			first = false
+			continue
+		}
+
+		chapter := entry.Chapter
+		heading := fields[chapter]
+		if len(chapter.Scenes) > 0 {
+			// the first scene starts at the chapter heading
+			heading += fields[chapter.Scenes[0]]
+		}
+		body.WriteString(chapterHeading(chapter.Title, first, heading))
+		first = false
+		separator := sceneBreak(book, chapter, options)
+		for idx, scene := range chapter.Scenes {
+			if idx > 0 {
+				body.WriteString(centeredWith(fields[scene], []run{{text: separator}}))
+			}
+			writeBlocks(body, scene.Document, 0, separator)
+		}
+	}
+
+	body.WriteString(centered([]run{{text: "END"}}))
+
+	builder := &strings.Builder{}
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	builder.WriteString(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	builder.WriteString("<w:body>")
+	builder.WriteString(body.String())
+	builder.WriteString(`<w:sectPr><w:headerReference w:type="default" r:id="rIdHeader"/>` +
+		`<w:pgSz w:w="` + strconv.Itoa(pageWidth) + `" w:h="` + strconv.Itoa(pageHeight) + `"/>` +
+		`<w:pgMar w:top="` + strconv.Itoa(margin) + `" w:right="` + strconv.Itoa(margin) + `" w:bottom="` + strconv.Itoa(margin) +
+		`" w:left="` + strconv.Itoa(margin) + `" w:header="720" w:footer="720" w:gutter="0"/>` +
+		`<w:titlePg/></w:sectPr>`)
+	builder.WriteString("</w:body></w:document>")
+
+	return builder.String()
+}
+
+// chapterHeading returns a centered chapter title which starts a new page, a third of the way down.
+// The fields are placed ahead of the title.
+func chapterHeading(title string, first bool, fields string) string {
+	properties := `<w:pPr><w:pageBreakBefore/><w:spacing w:before="2880"/><w:jc w:val="center"/></w:pPr>`
+	if first {
+		// the first chapter follows the title on the first page
+		properties = `<w:pPr><w:spacing w:before="480"/><w:jc w:val="center"/></w:pPr>`
+	}
+	return "<w:p>" + properties + fields + runs([]run{{text: title}}) + "</w:p>"
+}
+
+// tableOfContents returns the table of contents on a page of its own: a TOC field which
+// collects the entry fields of the document, filled in with the titles of the entries so
+// they show before the field is updated with page numbers.
+func tableOfContents(title string, entries []manuscript.TOCEntry) string {
+	builder := &strings.Builder{}
+	builder.WriteString(`<w:p><w:pPr><w:pageBreakBefore/><w:spacing w:before="2880" w:after="480"/><w:jc w:val="center"/></w:pPr>` +
+		runs([]run{{text: title}}) + "</w:p>")
+
+	begin := `<w:r><w:fldChar w:fldCharType="begin" w:dirty="true"/></w:r>` +
+		`<w:r><w:instrText xml:space="preserve"> TOC \f \l "1-9" \h \z </w:instrText></w:r>` +
+		`<w:r><w:fldChar w:fldCharType="separate"/></w:r>`
+	end := `<w:r><w:fldChar w:fldCharType="end"/></w:r>`
+	if len(entries) == 0 {
+		return builder.String() + "<w:p>" + begin + end + "</w:p>"
+	}
+
+	for idx, entry := range entries {
+		builder.WriteString(`<w:p><w:pPr><w:ind w:left="` + strconv.Itoa((entry.Level-1)*indent) + `"/></w:pPr>`)
+		if idx == 0 {
+			builder.WriteString(begin)
+		}
+		builder.WriteString(runs([]run{{text: entry.Title}}))
+		if idx == len(entries)-1 {
+			builder.WriteString(end)
+		}
+		builder.WriteString("</w:p>")
+	}
+	return builder.String()
+}
+
+// entryField returns a TC field which marks where the entry of the table of contents points.
+func entryField(entry manuscript.TOCEntry) string {
+	title := strings.ReplaceAll(entry.Title, `"`, `\"`)
+	return `<w:r><w:fldChar w:fldCharType="begin"/></w:r>` +
+		`<w:r><w:instrText xml:space="preserve"> TC "` + escape(title) + `" \l ` + strconv.Itoa(entry.Level) + ` </w:instrText></w:r>` +
+		`<w:r><w:fldChar w:fldCharType="end"/></w:r>`
+}
+
+// writeBlocks writes the block nodes of the document as manuscript paragraphs, with the
+// separator centered in place of a thematic or scene break.
+func writeBlocks(builder *strings.Builder, n *markdown.Node, level int, separator string) {
+	for _, child := range n.Children {
+		switch child.Kind {
+		case markdown.Paragraph:
+			builder.WriteString(paragraph(inlines(child, run{}), level))
+		case markdown.Heading:
+			builder.WriteString(centered(inlines(child, run{})))
+		case markdown.BlockQuote:
+			writeBlocks(builder, child, level+1, separator)
+		case markdown.List:
+			for idx, item := range child.Children {
+				marker := "• "
+				if child.Ordered {
+					marker = strconv.Itoa(child.Start+idx) + ". "
+				}
+				rest := item
+				if len(item.Children) > 0 && item.Children[0].Kind == markdown.Paragraph {
+					builder.WriteString(paragraph(append([]run{{text: marker}}, inlines(item.Children[0], run{})...), level+1))
+					rest = &markdown.Node{Kind: markdown.ListItem, Children: item.Children[1:]}
+				} else {
+					builder.WriteString(paragraph([]run{{text: marker}}, level+1))
+				}
+				writeBlocks(builder, rest, level+1, separator)
+			}
+		case markdown.CodeBlock:
+			for _, line := range strings.Split(strings.TrimSuffix(child.Literal, "\n"), "\n") {
+				builder.WriteString(paragraph([]run{{text: line}}, level+1))
+			}
+		case markdown.ThematicBreak, markdown.SceneBreak:
+			builder.WriteString(centered([]run{{text: separator}}))
+		}
+	}
+}
+
+// inlines flattens the inline children of the node into formatted runs.
+func inlines(n *markdown.Node, format run) []run {
+	var result []run
+	for _, child := range n.Children {
+		switch child.Kind {
+		case markdown.Text, markdown.Code, markdown.WikiLink:
+			result = append(result, format.with(child.Literal))
+		case markdown.SoftBreak:
+			result = append(result, format.with(" "))
+		case markdown.HardBreak:
+			result = append(result, format.with("\n"))
+		case markdown.Image, markdown.HTMLInline:
+			// images and raw HTML have no place in a manuscript
+		case markdown.Emphasis:
+			f := format
+			f.italic = true
+			result = append(result, inlines(child, f)...)
+		case markdown.Strong:
+			f := format
+			f.bold = true
+			result = append(result, inlines(child, f)...)
+		case markdown.Insertion, markdown.Deletion:
+			f := format
+			f.change, f.id = "ins", child.Level
+			if child.Kind == markdown.Deletion {
+				f.change = "del"
+			}
+			result = append(result, inlines(child, f)...)
+		case markdown.Comment:
+			result = append(result, run{text: child.Literal, comment: true, id: child.Level})
+		default:
+			result = append(result, inlines(child, format)...)
+		}
+	}
+	return result
+}
+
+// with returns a run of the text in the same format.
+func (r run) with(text string) run {
+	r.text = text
+	return r
+}
+
+// paragraph returns a body paragraph with a first-line indent, indented further by the given level.
+func paragraph(content []run, level int) string {
+	properties := `<w:pPr><w:ind w:firstLine="` + strconv.Itoa(indent) + `"/></w:pPr>`
+	if level > 0 {
+		properties = `<w:pPr><w:ind w:left="` + strconv.Itoa(level*indent) + `"/></w:pPr>`
+	}
+	return "<w:p>" + properties + runs(content) + "</w:p>"
+}
+
+// centered returns a centered paragraph without an indent.
+func centered(content []run) string {
+	return centeredWith("", content)
+}
+
+// centeredWith returns a centered paragraph without an indent, with the fields placed ahead
+// of its content.
+func centeredWith(fields string, content []run) string {
+	return `<w:p><w:pPr><w:jc w:val="center"/></w:pPr>` + fields + runs(content) + "</w:p>"
+}
+
+// runs returns the WordprocessingML for the formatted runs. The runs of a tracked change are
+// wrapped together in the change, and a comment is anchored where it stands.
+func runs(content []run) string {
+	builder := &strings.Builder{}
+	open := ""
+	for idx, r := range content {
+		if r.comment {
+			id := strconv.Itoa(r.id)
+			builder.WriteString(`<w:commentRangeStart w:id="` + id + `"/><w:commentRangeEnd w:id="` + id + `"/>` +
+				`<w:r><w:commentReference w:id="` + id + `"/></w:r>`)
+			continue
+		}
+		if r.change != "" && (idx == 0 || content[idx-1].change != r.change || content[idx-1].id != r.id) {
+			open = "</w:" + r.change + ">"
+			builder.WriteString("<w:" + r.change + ` w:id="` + strconv.Itoa(r.id) + `" w:author="inkwell">`)
+		}
+
+		builder.WriteString("<w:r>")
+		if r.italic || r.bold {
+			builder.WriteString("<w:rPr>")
+			if r.bold {
+				builder.WriteString("<w:b/>")
+			}
+			if r.italic {
+				builder.WriteString("<w:i/>")
+			}
+			builder.WriteString("</w:rPr>")
+		}
+		element := "w:t"
+		if r.change == "del" {
+			element = "w:delText"
+		}
+		text := &strings.Builder{}
+		flush := func() {
+			if text.Len() > 0 {
+				builder.WriteString("<" + element + ` xml:space="preserve">` + escape(text.String()) + "</" + element + ">")
+				text.Reset()
+			}
+		}
+		for _, c := range r.text {
+			switch c {
+			case '\t':
+				flush()
+				builder.WriteString("<w:tab/>")
+			case '\n':
+				flush()
+				builder.WriteString("<w:br/>")
+			default:
+				text.WriteRune(c)
+			}
+		}
+		flush()
+		builder.WriteString("</w:r>")
+
+		if r.change != "" && (idx == len(content)-1 || content[idx+1].change != r.change || content[idx+1].id != r.id) {
+			builder.WriteString(open)
+		}
+	}
+	return builder.String()
+}
+
+// comments returns the contents of word/comments.xml, which holds the text of each comment.
+func comments(notes []run) string {
+	builder := &strings.Builder{}
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	builder.WriteString(`<w:comments xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">`)
+	for _, note := range notes {
+		builder.WriteString(`<w:comment w:id="` + strconv.Itoa(note.id) + `" w:author="inkwell" w:initials="ink">` +
+			"<w:p>" + runs([]run{{text: note.text}}) + "</w:p></w:comment>")
+	}
+	builder.WriteString("</w:comments>")
+	return builder.String()
+}
+
+// header returns the contents of the running header: surname, title and page number.
+func header(surname string, title string) string {
+	text := title
+	if surname != "" {
+		text = surname + " / " + title
+	}
+
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+		`<w:p><w:pPr><w:jc w:val="right"/><w:spacing w:line="240" w:lineRule="auto"/></w:pPr>` +
+		runs([]run{{text: text + " / "}}) +
+		`<w:fldSimple w:instr=" PAGE "><w:r><w:t>1</w:t></w:r></w:fldSimple>` +
+		"</w:p></w:hdr>"
+}
+
+// styles returns the contents of word/styles.xml, which sets the manuscript typeface and spacing.
+func styles(typeface string) string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+		`<w:docDefaults>` +
+		`<w:rPrDefault><w:rPr><w:rFonts w:ascii="` + typeface + `" w:hAnsi="` + typeface + `" w:cs="` + typeface + `" w:eastAsia="` + typeface + `"/>` +
+		`<w:sz w:val="24"/><w:szCs w:val="24"/><w:lang w:val="en-US"/></w:rPr></w:rPrDefault>` +
+		`<w:pPrDefault><w:pPr><w:spacing w:before="0" w:after="0" w:line="` + strconv.Itoa(lineSpacing) + `" w:lineRule="auto"/></w:pPr></w:pPrDefault>` +
+		`</w:docDefaults>` +
+		`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+		`</w:styles>`
+}
+
+// contentTypes returns the contents of [Content_Types].xml, listing the settings and comments
+// parts when the document has them.
+func contentTypes(settings bool, comments bool) string {
+	override := ""
+	if settings {
+		override = `<Override PartName="/word/settings.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"/>`
+	}
+	if comments {
+		override += `<Override PartName="/word/comments.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"/>`
+	}
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+		`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+		`<Override PartName="/word/header1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml"/>` +
+		`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+		override +
+		`</Types>`
+}
+
+// packageRelationships returns the contents of _rels/.rels.
+func packageRelationships() string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+		`</Relationships>`
+}
+
+// documentRelationships returns the contents of word/_rels/document.xml.rels, relating the
+// settings and comments parts when the document has them.
+func documentRelationships(settings bool, comments bool) string {
+	relationship := ""
+	if settings {
+		relationship = `<Relationship Id="rIdSettings" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings" Target="settings.xml"/>`
+	}
+	if comments {
+		relationship += `<Relationship Id="rIdComments" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"/>`
+	}
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`<Relationship Id="rIdHeader" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/header" Target="header1.xml"/>` +
+		relationship +
+		`</Relationships>`
+}
+
+// settings returns the contents of word/settings.xml, which asks Word to update the fields
+// of the document when it is opened.
+func settings() string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<w:settings xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+		`<w:updateFields w:val="true"/>` +
+		`</w:settings>`
+}
+
+// coreProperties returns the contents of docProps/core.xml.
+func coreProperties(book *manuscript.Book) string {
+	now := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		"<dc:title>" + escape(book.Title) + "</dc:title>" +
+		"<dc:creator>" + escape(strings.Join(book.Authors, ", ")) + "</dc:creator>" +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + now + "</dcterms:created>" +
+		`<dcterms:modified xsi:type="dcterms:W3CDTF">` + now + "</dcterms:modified>" +
+		"</cp:coreProperties>"
+}
+
+// formatNumber formats the number with thousands separators.
+func formatNumber(n int) string {
+	digits := strconv.Itoa(n)
+	builder := &strings.Builder{}
+	for idx, d := range digits {
+		if idx > 0 && (len(digits)-idx)%3 == 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteRune(d)
+	}
+	return builder.String()
+}
+
+// escape escapes the text for use in XML content and attributes.
+func escape(text string) string {
+	return html.EscapeString(text)
+}
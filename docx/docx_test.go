@@ -0,0 +1,239 @@
+package docx
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := map[int]string{
+		0:       "0",
+		999:     "999",
+		1000:    "1,000",
+		81000:   "81,000",
+		1234567: "1,234,567",
+	}
+
+	for n, expected := range tests {
+		if result := formatNumber(n); result != expected {
+			t.Errorf("formatNumber(%d) = %q, want %q", n, result, expected)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	book := &manuscript.Book{
+		Title:          "Test Book",
+		Authors:        []string{"Jane Q. Author"},
+		SceneSeparator: "#",
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("First scene with *emphasis* & more.")},
+				{Document: markdown.Parse("Second scene.")},
+			}},
+			{Title: "Chapter 2", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("Third scene.")},
+			}},
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "book.docx")
+	err := Write(book, config.DocxConfig{Font: "times", Contact: []string{"jane@example.com"}}, 81234, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatalf("Failed to open written archive: %v", err)
+	}
+	defer archive.Close()
+
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(r)
+		_ = r.Close()
+		contents[f.Name] = string(data)
+	}
+
+	expected := map[string][]string{
+		"[Content_Types].xml": {"/word/document.xml", "/word/header1.xml"},
+		"word/styles.xml":     {`w:ascii="Times New Roman"`, `<w:sz w:val="24"/>`, `w:line="480"`},
+		"word/header1.xml":    {"Author / Test Book / ", `w:instr=" PAGE "`},
+		"word/document.xml": {
+			"Jane Q. Author", "about 81,000 words", "jane@example.com",
+			"by Jane Q. Author",
+			"Chapter 1", "<w:i/>", "emphasis", "&amp; more.",
+			`<w:jc w:val="center"/></w:pPr><w:r><w:t xml:space="preserve">#</w:t></w:r>`,
+			"<w:pageBreakBefore/>",
+			`w:top="1440"`, "<w:titlePg/>",
+		},
+	}
+	for name, parts := range expected {
+		content, ok := contents[name]
+		if !ok {
+			t.Errorf("archive missing %s", name)
+			continue
+		}
+		for _, part := range parts {
+			if !strings.Contains(content, part) {
+				t.Errorf("%s missing expected part: %q", name, part)
+			}
+		}
+	}
+}
+
+func TestDocumentTableOfContents(t *testing.T) {
+	book := &manuscript.Book{
+		Title:   "Test Book",
+		Authors: []string{"Author One"},
+		TOC:     manuscript.TOCOptions{Scenes: true},
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Title: "Landfall", Document: markdown.Parse("First scene.")},
+				{Title: "Nightfall", Document: markdown.Parse("Second scene.")},
+			}},
+			{Title: "Notes", ExcludeFromTOC: true, Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("Unlisted.")},
+			}},
+		},
+	}
+
+	output := document(book, config.DocxConfig{TOC: true}, 100)
+	expected := []string{
+		`<w:instrText xml:space="preserve"> TOC \f \l "1-9" \h \z </w:instrText>`,
+		`<w:instrText xml:space="preserve"> TC "Chapter 1" \l 1 </w:instrText>`,
+		`<w:instrText xml:space="preserve"> TC "Landfall" \l 2 </w:instrText>`,
+		`<w:instrText xml:space="preserve"> TC "Nightfall" \l 2 </w:instrText></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r><w:r><w:t xml:space="preserve">#</w:t>`,
+		`<w:ind w:left="720"/></w:pPr><w:r><w:t xml:space="preserve">Landfall</w:t>`,
+	}
+	for _, part := range expected {
+		if !strings.Contains(output, part) {
+			t.Errorf("document() should contain %q", part)
+		}
+	}
+	if strings.Contains(output, `TC "Notes"`) {
+		t.Error("document() should not mark an excluded chapter")
+	}
+	if strings.Count(output, "<w:pageBreakBefore/>") != 3 {
+		t.Error("the table of contents and every chapter should start a new page")
+	}
+
+	if output := document(book, config.DocxConfig{}, 100); strings.Contains(output, "instrText") {
+		t.Error("document() should only add fields when the table of contents is enabled")
+	}
+}
+
+func TestDocumentSceneBreaks(t *testing.T) {
+	book := &manuscript.Book{
+		Title:          "Test Book",
+		SceneSeparator: "* * *",
+		Sections: []*manuscript.Section{
+			{Title: "Foreword", Document: markdown.Parse("Before.\n\n---\n\nAfter.")},
+		},
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", SceneBreak: markdown.NewSceneBreak("~"), Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("Dawn.\n\n***\n\nDusk.")},
+			}},
+		},
+	}
+	centered := func(text string) string {
+		return `<w:jc w:val="center"/></w:pPr><w:r><w:t xml:space="preserve">` + text + `</w:t></w:r>`
+	}
+
+	tests := []struct {
+		sceneBreak string
+		expected   []string
+	}{
+		{sceneBreak: "", expected: []string{centered("#")}},
+		{sceneBreak: "separator", expected: []string{centered("* * *"), centered("~")}},
+		{sceneBreak: "§", expected: []string{centered("§")}},
+	}
+
+	for _, tt := range tests {
+		output := document(book, config.DocxConfig{SceneBreak: tt.sceneBreak}, 100)
+		for _, part := range tt.expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("document() with scene break %q should contain %q", tt.sceneBreak, part)
+			}
+		}
+		if tt.sceneBreak != "" && strings.Contains(output, centered("#")) {
+			t.Errorf("document() with scene break %q should not center a #", tt.sceneBreak)
+		}
+	}
+}
+
+func TestWriteReview(t *testing.T) {
+	book := &manuscript.Book{
+		Title: "Test Book",
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("She {~~ran~>*walked*~~} home.{>>Too slow?<<}")},
+			}},
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "book.docx")
+	if err := Write(book, config.DocxConfig{}, 100, filename); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatalf("Failed to open written archive: %v", err)
+	}
+	defer archive.Close()
+
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(r)
+		_ = r.Close()
+		contents[f.Name] = string(data)
+	}
+
+	expected := map[string][]string{
+		"[Content_Types].xml":          {"/word/comments.xml"},
+		"word/_rels/document.xml.rels": {`Target="comments.xml"`},
+		"word/comments.xml":            {`<w:comment w:id="3" w:author="inkwell" w:initials="ink"><w:p><w:r><w:t xml:space="preserve">Too slow?</w:t>`},
+		"word/document.xml": {
+			`<w:del w:id="1" w:author="inkwell"><w:r><w:delText xml:space="preserve">ran</w:delText></w:r></w:del>`,
+			`<w:ins w:id="2" w:author="inkwell"><w:r><w:rPr><w:i/></w:rPr><w:t xml:space="preserve">walked</w:t></w:r></w:ins>`,
+			`<w:commentRangeStart w:id="3"/><w:commentRangeEnd w:id="3"/><w:r><w:commentReference w:id="3"/></w:r>`,
+		},
+	}
+	for name, parts := range expected {
+		for _, part := range parts {
+			if !strings.Contains(contents[name], part) {
+				t.Errorf("%s missing expected part: %q", name, part)
+			}
+		}
+	}
+
+	if strings.Contains(markdown.RenderMarkdown(book.Chapters[0].Scenes[0].Document), "w:") || book.Chapters[0].Scenes[0].Document.Children[0].Children[1].Level != 0 {
+		t.Error("Write() should not change the book")
+	}
+}
+
+func TestSurname(t *testing.T) {
+	book := &manuscript.Book{Authors: []string{"Jane Q. Author"}}
+	if result := surname(book, config.DocxConfig{}); result != "Author" {
+		t.Errorf("surname() = %q, want %q", result, "Author")
+	}
+	if result := surname(book, config.DocxConfig{Surname: "Pseudonym"}); result != "Pseudonym" {
+		t.Errorf("surname() with override = %q, want %q", result, "Pseudonym")
+	}
+}
@@ -0,0 +1,472 @@
+package epub
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// document is a struct that represents a single XHTML content document in the package. The
+// target is the section, chapter or part which the document holds.
+type document struct {
+	id     string
+	href   string
+	title  string
+	body   string
+	target any
+}
+
+// navEntry is a struct that represents an entry of the table of contents, with the href of
+// the document it links to
+type navEntry struct {
+	title string
+	level int
+	href  string
+}
+
+// entry is a struct that represents a single file in the container
+type entry struct {
+	name    string
+	content string
+}
+
+// imageTypes are the media types of the images which may be used as an ornament or
+// embedded in the text
+var imageTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+const stylesheet = `body { font-family: serif; line-height: 1.4; margin: 0 5%; }
+h1, h2 { text-align: center; margin: 2em 0 1em; }
+p { margin: 0; text-indent: 1.5em; }
+h1 + p, h2 + p, p.scene-break + p { text-indent: 0; }
+p.scene-break { text-align: center; text-indent: 0; margin: 1em 0; }
+p.scene-break img { max-width: 30%; }
+section.title-page { text-align: center; margin-top: 30%; }
+section.title-page p { text-indent: 0; }
+section.dedication { text-align: center; font-style: italic; margin-top: 30%; }
+section.dedication p { text-indent: 0; }
+blockquote { margin: 1em 2em; }
+del { text-decoration: line-through; }
+span.comment { display: block; margin: 0.5em 0 0.5em 2em; font-size: 0.8em; font-style: italic; text-indent: 0; }
+`
+
+// Write packages the book as an EPUB 3 container and writes it to the file with the given filename.
+// If an ornament is configured, the image is drawn at each scene break in place of the separator.
+func Write(book *manuscript.Book, options config.EpubConfig, filename string) error {
+	var ornament *entry
+	if options.Ornament != "" {
+		ext := strings.ToLower(filepath.Ext(options.Ornament))
+		if imageTypes[ext] == "" {
+			return fmt.Errorf("unsupported ornament image %q", options.Ornament)
+		}
+		data, err := os.ReadFile(options.Ornament)
+		if err != nil {
+			return err
+		}
+		ornament = &entry{"images/ornament" + ext, string(data)}
+	}
+
+	docs, images := documents(book, ornament)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+
+	// The mimetype must be the first entry in the archive, and must not be compressed
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = mimetype.Write([]byte("application/epub+zip"))
+	if err != nil {
+		return err
+	}
+
+	entries := []entry{
+		{"META-INF/container.xml", container()},
+		{"OEBPS/content.opf", packageDocument(book, docs, ornament, images)},
+		{"OEBPS/nav.xhtml", navDocument(book, docs)},
+		{"OEBPS/toc.ncx", ncx(book, docs)},
+		{"OEBPS/style.css", stylesheet},
+	}
+	for _, doc := range docs {
+		entries = append(entries, entry{"OEBPS/" + doc.href, xhtml(doc.title, doc.body, book.Language)})
+	}
+	if ornament != nil {
+		entries = append(entries, entry{"OEBPS/" + ornament.name, ornament.content})
+	}
+	for _, image := range images {
+		entries = append(entries, entry{"OEBPS/" + image.name, image.content})
+	}
+
+	for _, e := range entries {
+		w, werr := archive.Create(e.name)
+		if werr != nil {
+			return werr
+		}
+		_, werr = w.Write([]byte(e.content))
+		if werr != nil {
+			return werr
+		}
+	}
+
+	return archive.Close()
+}
+
+// documents builds the content documents of the book in reading order, along with the local
+// images they show, which are packaged with them. Wiki links to a section, chapter or part of
+// the book link to its document.
+func documents(book *manuscript.Book, ornament *entry) ([]document, []entry) {
+	var docs []document
+	var images []entry
+	packaged := map[string]string{}
+
+	if book.Title != "" {
+		body := &strings.Builder{}
+		body.WriteString(`<section class="title-page" epub:type="titlepage">` + "\n")
+		body.WriteString("<h1>" + escape(book.Title) + "</h1>\n")
+		if len(book.Authors) > 0 {
+			body.WriteString("<p>By " + escape(strings.Join(book.Authors, ", ")) + "</p>\n")
+		}
+		body.WriteString("</section>\n")
+		docs = append(docs, document{id: "title", href: "title.xhtml", title: book.Title, body: body.String()})
+	}
+
+	if book.Dedication != nil {
+		body := `<section class="dedication" epub:type="dedication">` + "\n" +
+			markdown.RenderXHTML(book.Dedication) + "</section>\n"
+		docs = append(docs, document{id: "dedication", href: "dedication.xhtml", title: "Dedication", body: body})
+	}
+
+	entries := book.Contents()
+	ids := documentIDs(entries)
+	link := func(idx int) string {
+		return ids[idx] + ".xhtml"
+	}
+	render := func(doc *markdown.Node, idx int) string {
+		resolved := book.ResolveLinks(doc, idx, link)
+		markdown.Walk(resolved, func(n *markdown.Node) bool {
+			if n.Kind != markdown.Image {
+				return true
+			}
+			if name, ok := packaged[n.Destination]; ok {
+				n.Destination = name
+				return false
+			}
+			image := packageImage(n.Destination, len(images)+1)
+			if image != nil {
+				images = append(images, *image)
+				packaged[n.Destination] = image.name
+				n.Destination = image.name
+			}
+			return false
+		})
+		return markdown.RenderXHTML(resolved)
+	}
+
+	for idx, entry := range entries {
+		id := ids[idx]
+		if section := entry.Section; section != nil {
+			body := "<h1>" + escape(section.Title) + "</h1>\n" + render(section.Document, idx)
+			docs = append(docs, document{id: id, href: id + ".xhtml", title: section.Title, body: body, target: section})
+			continue
+		}
+		if part := entry.Part; part != nil {
+			body := `<section class="part" epub:type="part">` + "\n<h1>" + escape(part.Title) + "</h1>\n</section>\n"
+			docs = append(docs, document{id: id, href: id + ".xhtml", title: part.Title, body: body, target: part})
+			continue
+		}
+
+		chapter := entry.Chapter
+		body := &strings.Builder{}
+		body.WriteString("<h2>" + escape(chapter.Title) + "</h2>\n")
+		sceneBreak := book.SceneBreak(chapter)
+		for sidx, scene := range chapter.Scenes {
+			// when scenes are listed in the table of contents, the scene break carries the
+			// anchor of the scene which follows it
+			anchor := `<p class="scene-break"`
+			if book.TOC.Scenes {
+				anchor += ` id="` + sceneID(id, sidx) + `"`
+			}
+			if sidx > 0 && ornament != nil {
+				body.WriteString(anchor + `><img src="` + ornament.name + `" alt="` + escape(markdown.PlainText(sceneBreak)) + `"/></p>` + "\n")
+			} else if sidx > 0 {
+				body.WriteString(strings.Replace(markdown.RenderXHTML(sceneBreak), `<p class="scene-break"`, anchor, 1))
+			}
+			body.WriteString(render(scene.Document, idx))
+		}
+		docs = append(docs, document{id: id, href: id + ".xhtml", title: chapter.Title, body: body.String(), target: chapter})
+	}
+
+	return docs, images
+}
+
+// packageImage returns the entry which packages the local image at the given path as the
+// image with the given number, or nil if the image cannot be read or its type is not known.
+func packageImage(path string, number int) *entry {
+	ext := strings.ToLower(filepath.Ext(path))
+	if imageTypes[ext] == "" || strings.Contains(path, "://") || strings.HasPrefix(path, "data:") {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.FromSlash(path))
+	if err != nil {
+		return nil
+	}
+	return &entry{"images/image-" + strconv.Itoa(number) + ext, string(data)}
+}
+
+// documentIDs returns the id of the document of each section, chapter and part, which are
+// numbered apart from one another.
+func documentIDs(entries []manuscript.Entry) []string {
+	ids := make([]string, len(entries))
+	sections, chapters, parts := 0, 0, 0
+	for idx, entry := range entries {
+		switch {
+		case entry.Section != nil:
+			sections++
+			ids[idx] = "section-" + strconv.Itoa(sections)
+		case entry.Chapter != nil:
+			chapters++
+			ids[idx] = "chapter-" + strconv.Itoa(chapters)
+		default:
+			parts++
+			ids[idx] = "part-" + strconv.Itoa(parts)
+		}
+	}
+	return ids
+}
+
+// container returns the contents of META-INF/container.xml.
+func container() string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+}
+
+// identifier returns a stable identifier for the book, derived from its title and authors.
+func identifier(book *manuscript.Book) string {
+	sum := sha1.Sum([]byte(book.Title + "\x00" + strings.Join(book.Authors, "\x00")))
+	// format as a name-based (version 5) UUID
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// packageDocument returns the contents of the OPF package document.
+func packageDocument(book *manuscript.Book, docs []document, ornament *entry, images []entry) string {
+	builder := &strings.Builder{}
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	builder.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + escape(book.Language) + `">` + "\n")
+
+	builder.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	builder.WriteString(`    <dc:identifier id="book-id">` + identifier(book) + "</dc:identifier>\n")
+	builder.WriteString("    <dc:title>" + escape(book.Title) + "</dc:title>\n")
+	for _, author := range book.Authors {
+		builder.WriteString("    <dc:creator>" + escape(author) + "</dc:creator>\n")
+	}
+	builder.WriteString("    <dc:language>" + escape(book.Language) + "</dc:language>\n")
+	if book.Summary != "" {
+		builder.WriteString("    <dc:description>" + escape(book.Summary) + "</dc:description>\n")
+	}
+	builder.WriteString(`    <meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + "</meta>\n")
+	builder.WriteString("  </metadata>\n")
+
+	builder.WriteString("  <manifest>\n")
+	builder.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	builder.WriteString(`    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>` + "\n")
+	builder.WriteString(`    <item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	if ornament != nil {
+		builder.WriteString(`    <item id="ornament" href="` + ornament.name + `" media-type="` + imageTypes[filepath.Ext(ornament.name)] + `"/>` + "\n")
+	}
+	for idx, image := range images {
+		builder.WriteString(`    <item id="image-` + strconv.Itoa(idx+1) + `" href="` + image.name + `" media-type="` + imageTypes[filepath.Ext(image.name)] + `"/>` + "\n")
+	}
+	for _, doc := range docs {
+		builder.WriteString(`    <item id="` + doc.id + `" href="` + doc.href + `" media-type="application/xhtml+xml"/>` + "\n")
+	}
+	builder.WriteString("  </manifest>\n")
+
+	builder.WriteString(`  <spine toc="ncx">` + "\n")
+	for _, doc := range docs {
+		builder.WriteString(`    <itemref idref="` + doc.id + `"/>` + "\n")
+	}
+	builder.WriteString("  </spine>\n")
+	builder.WriteString("</package>\n")
+
+	return builder.String()
+}
+
+// navDocument returns the contents of the EPUB 3 navigation document, which lists the
+// entries of the table of contents of the book.
+func navDocument(book *manuscript.Book, docs []document) string {
+	body := &strings.Builder{}
+	body.WriteString(`<nav epub:type="toc" id="toc">` + "\n")
+	body.WriteString("<h1>" + escape(book.TOCTitle()) + "</h1>\n<ol>\n")
+
+	// entries deeper than the one before them open a list within it
+	level := 0
+	for idx, entry := range navEntries(book, docs) {
+		entryLevel := min(entry.level, level+1)
+		switch {
+		case idx == 0:
+			entryLevel = 1
+		case entryLevel > level:
+			body.WriteString("\n<ol>\n")
+		default:
+			body.WriteString("</li>\n")
+			for ; level > entryLevel; level-- {
+				body.WriteString("</ol>\n</li>\n")
+			}
+		}
+		level = entryLevel
+		body.WriteString(`<li><a href="` + entry.href + `">` + escape(entry.title) + "</a>")
+	}
+	if level > 0 {
+		body.WriteString("</li>\n")
+	}
+	for ; level > 1; level-- {
+		body.WriteString("</ol>\n</li>\n")
+	}
+	body.WriteString("</ol>\n</nav>\n")
+
+	return xhtml(book.TOCTitle(), body.String(), book.Language)
+}
+
+// ncx returns the contents of the NCX document, for reading systems which do not support EPUB 3.
+func ncx(book *manuscript.Book, docs []document) string {
+	entries := navEntries(book, docs)
+	depth := 1
+	for _, entry := range entries {
+		depth = max(depth, entry.level)
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	builder.WriteString(`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">` + "\n")
+	builder.WriteString("  <head>\n")
+	builder.WriteString(`    <meta name="dtb:uid" content="` + identifier(book) + `"/>` + "\n")
+	builder.WriteString(`    <meta name="dtb:depth" content="` + strconv.Itoa(depth) + `"/>` + "\n")
+	builder.WriteString(`    <meta name="dtb:totalPageCount" content="0"/>` + "\n")
+	builder.WriteString(`    <meta name="dtb:maxPageNumber" content="0"/>` + "\n")
+	builder.WriteString("  </head>\n")
+	builder.WriteString("  <docTitle><text>" + escape(book.Title) + "</text></docTitle>\n")
+	builder.WriteString("  <navMap>\n")
+
+	// each nav point stays open until an entry at the same level or above it
+	level := 0
+	indent := func() string { return strings.Repeat("  ", level+1) }
+	for idx, entry := range entries {
+		entryLevel := min(entry.level, level+1)
+		for ; level >= entryLevel; level-- {
+			builder.WriteString(indent() + "</navPoint>\n")
+		}
+		level = entryLevel
+		order := strconv.Itoa(idx + 1)
+		builder.WriteString(indent() + `<navPoint id="nav-` + order + `" playOrder="` + order + `">` + "\n")
+		builder.WriteString(indent() + "  <navLabel><text>" + escape(entry.title) + "</text></navLabel>\n")
+		builder.WriteString(indent() + `  <content src="` + entry.href + `"/>` + "\n")
+	}
+	for ; level > 0; level-- {
+		builder.WriteString(indent() + "</navPoint>\n")
+	}
+	builder.WriteString("  </navMap>\n")
+	builder.WriteString("</ncx>\n")
+
+	return builder.String()
+}
+
+// navEntries returns the entries of the table of contents with the documents they link to.
+// The navigation document and the NCX must list at least one entry, so when the table of
+// contents is empty the first document of the book is listed instead.
+func navEntries(book *manuscript.Book, docs []document) []navEntry {
+	var entries []navEntry
+	for _, entry := range book.TableOfContents() {
+		entries = append(entries, navEntry{title: entry.Title, level: entry.Level, href: href(entry, docs)})
+	}
+	if len(entries) == 0 && len(docs) > 0 {
+		title := docs[0].title
+		if title == "" {
+			title = book.TOCTitle()
+		}
+		entries = append(entries, navEntry{title: title, level: 1, href: docs[0].href})
+	}
+	return entries
+}
+
+// href returns the href of the document which holds the entry of the table of contents. A
+// scene after the first of its chapter links to the scene break which opens it.
+func href(entry manuscript.TOCEntry, docs []document) string {
+	var target any = entry.Chapter
+	if entry.Section != nil {
+		target = entry.Section
+	} else if entry.Part != nil {
+		target = entry.Part
+	}
+
+	for _, doc := range docs {
+		if doc.target != target {
+			continue
+		}
+		if entry.Scene == nil {
+			return doc.href
+		}
+		for idx, scene := range entry.Chapter.Scenes {
+			if entry.Scene == scene && idx > 0 {
+				return doc.href + "#" + sceneID(doc.id, idx)
+			}
+		}
+		return doc.href
+	}
+	return ""
+}
+
+// sceneID returns the id of the scene break which opens the scene with the given index.
+func sceneID(chapter string, idx int) string {
+	return chapter + "-scene-" + strconv.Itoa(idx+1)
+}
+
+// xhtml wraps the body in a complete XHTML content document.
+func xhtml(title string, body string, language string) string {
+	builder := &strings.Builder{}
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	builder.WriteString("<!DOCTYPE html>\n")
+	builder.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + escape(language) + `" lang="` + escape(language) + `">` + "\n")
+	builder.WriteString("<head>\n")
+	builder.WriteString("<title>" + escape(title) + "</title>\n")
+	builder.WriteString(`<link rel="stylesheet" type="text/css" href="style.css"/>` + "\n")
+	builder.WriteString("</head>\n")
+	builder.WriteString("<body>\n")
+	builder.WriteString(body)
+	builder.WriteString("</body>\n")
+	builder.WriteString("</html>\n")
+	return builder.String()
+}
+
+// escape escapes the text for use in XML content and attributes.
+func escape(text string) string {
+	return html.EscapeString(text)
+}
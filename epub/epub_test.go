@@ -0,0 +1,292 @@
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func TestWrite(t *testing.T) {
+	book := &manuscript.Book{
+		Title:          "Test Book",
+		Summary:        "A test summary",
+		Authors:        []string{"Author One"},
+		Language:       "en",
+		SceneSeparator: "\\* \\* \\*",
+		Dedication:     markdown.Parse("To my family"),
+		Sections: []*manuscript.Section{
+			{Title: "Prologue", Document: markdown.Parse("Before it all began.")},
+		},
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("First scene with *emphasis*.")},
+				{Document: markdown.Parse("Second scene.")},
+				{Document: markdown.Parse("A line<br>break, <img src=\"x.png\"> and <stuff>.\n\n<div class=note>\nFish&nbsp;&amp; chips <b>bold\n</div>")},
+			}},
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "book.epub")
+	err := Write(book, config.EpubConfig{}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatalf("Failed to open written archive: %v", err)
+	}
+	defer archive.Close()
+
+	first := archive.File[0]
+	if first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("first entry = %q (method %d), want uncompressed mimetype", first.Name, first.Method)
+	}
+
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(r)
+		_ = r.Close()
+		contents[f.Name] = string(data)
+	}
+
+	if contents["mimetype"] != "application/epub+zip" {
+		t.Errorf("mimetype = %q, want application/epub+zip", contents["mimetype"])
+	}
+
+	expected := map[string][]string{
+		"META-INF/container.xml": {"OEBPS/content.opf"},
+		"OEBPS/content.opf":      {"<dc:title>Test Book</dc:title>", "<dc:creator>Author One</dc:creator>", `properties="nav"`, `<itemref idref="chapter-1"/>`},
+		"OEBPS/nav.xhtml":        {`epub:type="toc"`, `<a href="chapter-1.xhtml">Chapter 1</a>`, `<a href="section-1.xhtml">Prologue</a>`},
+		"OEBPS/toc.ncx":          {"<navMap>", `<content src="chapter-1.xhtml"/>`},
+		"OEBPS/title.xhtml":      {"<h1>Test Book</h1>", "By Author One"},
+		"OEBPS/dedication.xhtml": {"To my family"},
+		"OEBPS/section-1.xhtml":  {"<h1>Prologue</h1>", "Before it all began."},
+		"OEBPS/chapter-1.xhtml":  {"<h2>Chapter 1</h2>", "<em>emphasis</em>", `<p class="scene-break">* * *</p>`, "Second scene."},
+		"OEBPS/style.css":        {"scene-break"},
+	}
+	for name, content := range contents {
+		if !strings.HasSuffix(name, ".xhtml") && !strings.HasSuffix(name, ".opf") && !strings.HasSuffix(name, ".ncx") && !strings.HasSuffix(name, ".xml") {
+			continue
+		}
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s is not well-formed XML: %v", name, err)
+				break
+			}
+		}
+	}
+
+	for name, parts := range expected {
+		content, ok := contents[name]
+		if !ok {
+			t.Errorf("archive missing %s", name)
+			continue
+		}
+		for _, part := range parts {
+			if !strings.Contains(content, part) {
+				t.Errorf("%s missing expected part: %q", name, part)
+			}
+		}
+	}
+}
+
+func TestWriteSceneBreaks(t *testing.T) {
+	dir := t.TempDir()
+	ornament := filepath.Join(dir, "fleuron.svg")
+	err := os.WriteFile(ornament, []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create ornament: %v", err)
+	}
+
+	book := &manuscript.Book{
+		Title:          "Test Book",
+		SceneSeparator: "\\* \\* \\*",
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", SceneBreak: markdown.NewSceneBreak("~"), Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("First scene.")},
+				{Document: markdown.Parse("Second scene.")},
+			}},
+		},
+	}
+
+	filename := filepath.Join(dir, "book.epub")
+	err = Write(book, config.EpubConfig{Ornament: ornament}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatalf("Failed to open written archive: %v", err)
+	}
+	defer archive.Close()
+
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(r)
+		_ = r.Close()
+		contents[f.Name] = string(data)
+	}
+
+	if _, ok := contents["OEBPS/images/ornament.svg"]; !ok {
+		t.Error("archive should contain the ornament image")
+	}
+	if !strings.Contains(contents["OEBPS/content.opf"], `href="images/ornament.svg" media-type="image/svg+xml"`) {
+		t.Error("manifest should list the ornament image")
+	}
+	if !strings.Contains(contents["OEBPS/chapter-1.xhtml"], `<img src="images/ornament.svg" alt="~"/>`) {
+		t.Errorf("chapter should draw the ornament with the chapter separator as its text, got %q", contents["OEBPS/chapter-1.xhtml"])
+	}
+
+	err = Write(book, config.EpubConfig{Ornament: filepath.Join(dir, "fleuron.bmp")}, filename)
+	if err == nil {
+		t.Error("Write() should reject an ornament which is not a supported image")
+	}
+}
+
+func TestNavDocument(t *testing.T) {
+	book := &manuscript.Book{
+		Title: "Test Book",
+		TOC:   manuscript.TOCOptions{Title: "Table of Contents", Scenes: true},
+		Sections: []*manuscript.Section{
+			{Title: "Prologue", Document: markdown.Parse("Before.")},
+			{Title: "Notes", Placement: manuscript.Back, ExcludeFromTOC: true, Document: markdown.Parse("Unlisted.")},
+		},
+		Parts: []*manuscript.Part{
+			{Title: "Part One", Chapters: []*manuscript.Chapter{
+				{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+					{Title: "Landfall", Document: markdown.Parse("First scene.")},
+					{Number: "2", Document: markdown.Parse("Second scene.")},
+				}},
+			}},
+		},
+	}
+
+	docs, _ := documents(book, nil)
+	nav := navDocument(book, docs)
+	expected := "<h1>Table of Contents</h1>\n<ol>\n" +
+		`<li><a href="section-1.xhtml">Prologue</a></li>` + "\n" +
+		`<li><a href="part-1.xhtml">Part One</a>` + "\n<ol>\n" +
+		`<li><a href="chapter-1.xhtml">Chapter 1</a>` + "\n<ol>\n" +
+		`<li><a href="chapter-1.xhtml">Landfall</a></li>` + "\n" +
+		`<li><a href="chapter-1.xhtml#chapter-1-scene-2">Scene 2</a></li>` + "\n" +
+		"</ol>\n</li>\n</ol>\n</li>\n</ol>\n"
+	if !strings.Contains(nav, expected) {
+		t.Errorf("navDocument() = %q, want nested lists of the entries", nav)
+	}
+	if !strings.Contains(docs[3].body, `<p class="scene-break" id="chapter-1-scene-2">`) {
+		t.Errorf("chapter should anchor its second scene, got %q", docs[3].body)
+	}
+
+	toc := ncx(book, docs)
+	if !strings.Contains(toc, `<meta name="dtb:depth" content="3"/>`) || strings.Count(toc, "<navPoint") != strings.Count(toc, "</navPoint>") {
+		t.Errorf("ncx() = %q, want balanced nav points three levels deep", toc)
+	}
+
+	book.TOC.Depth = 1
+	if nav := navDocument(book, docs); strings.Contains(nav, "Chapter 1") || !strings.Contains(nav, "Part One") {
+		t.Errorf("navDocument() = %q, want only the first level", nav)
+	}
+}
+
+func TestNavDocumentEmpty(t *testing.T) {
+	book := &manuscript.Book{
+		Title: "Test Book",
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", ExcludeFromTOC: true, Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("Unlisted.")},
+			}},
+		},
+	}
+
+	docs, _ := documents(book, nil)
+	nav := navDocument(book, docs)
+	expected := "<ol>\n" + `<li><a href="title.xhtml">Test Book</a></li>` + "\n</ol>\n"
+	if !strings.Contains(nav, expected) {
+		t.Errorf("navDocument() = %q, want the first document listed", nav)
+	}
+
+	toc := ncx(book, docs)
+	if strings.Count(toc, "<navPoint") != 1 || !strings.Contains(toc, `<content src="title.xhtml"/>`) {
+		t.Errorf("ncx() = %q, want the first document listed", toc)
+	}
+}
+
+func TestDocumentsWikiLinks(t *testing.T) {
+	book := &manuscript.Book{
+		Links: map[string]int{"prologue": 0},
+		Sections: []*manuscript.Section{
+			{Title: "Prologue", Document: markdown.Parse("Before.")},
+		},
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("As told in [[Prologue#Before|the prologue]], see [[Elsewhere]].")},
+			}},
+		},
+	}
+
+	docs, _ := documents(book, nil)
+	if !strings.Contains(docs[1].body, `As told in <a href="section-1.xhtml">the prologue</a>, see Elsewhere.`) {
+		t.Errorf("documents() should link to the document of the section, got %q", docs[1].body)
+	}
+}
+
+func TestDocumentsImages(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "map.png")
+	if err := os.WriteFile(image, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.ToSlash(image)
+	book := &manuscript.Book{
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("![The bay](" + src + ")\n\n![Again](" + src + ")\n\n![Lost](missing.png)")},
+			}},
+		},
+	}
+
+	docs, images := documents(book, nil)
+	if len(images) != 1 || images[0].name != "images/image-1.png" || images[0].content != "png" {
+		t.Fatalf("documents() should package the image once, got %+v", images)
+	}
+	if strings.Count(docs[0].body, `src="images/image-1.png"`) != 2 || !strings.Contains(docs[0].body, `src="missing.png"`) {
+		t.Errorf("documents() should point the images at the package, got %q", docs[0].body)
+	}
+	if !strings.Contains(packageDocument(book, docs, nil, images), `<item id="image-1" href="images/image-1.png" media-type="image/png"/>`) {
+		t.Error("package document should list the image in the manifest")
+	}
+}
+
+func TestIdentifierIsStable(t *testing.T) {
+	book := &manuscript.Book{Title: "Test Book", Authors: []string{"Author One"}}
+	first := identifier(book)
+	second := identifier(book)
+	if first != second {
+		t.Errorf("identifier() = %q then %q, want stable identifier", first, second)
+	}
+	if !strings.HasPrefix(first, "urn:uuid:") {
+		t.Errorf("identifier() = %q, want urn:uuid prefix", first)
+	}
+}
@@ -0,0 +1,307 @@
+package feedback
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nivthefox/inkwell/processor"
+)
+
+var (
+	// listComment matches a comment on a line of its own, after the paragraph it is about
+	listComment = regexp.MustCompile(`(?m)^[ \t]*<([0-9a-z]+)>[ \t]+(\S.*?)[ \t]*\r?$`)
+	// annotation matches a comment added to an annotated copy of a numbered output
+	annotation = regexp.MustCompile(`(?s)\{>>(.*?)<<\}|%%(.*?)%%|<!--(.*?)-->`)
+	// paragraphLabel matches the number or id at the end of a paragraph of a numbered output
+	paragraphLabel = regexp.MustCompile(`(?m)<([0-9a-z]+)>[ \t]*\r?$`)
+	// paragraphEnd matches the blank line which ends a paragraph
+	paragraphEnd = regexp.MustCompile(`\n[ \t]*\r?\n`)
+)
+
+// Comment is a struct that represents a comment from a reader on a paragraph of an output,
+// which is given by its number or id as the output shows it
+type Comment struct {
+	Paragraph string `yaml:"paragraph"`
+	Text      string `yaml:"comment"`
+	Reviewer  string `yaml:"reviewer,omitempty"`
+}
+
+// Resolved is a struct that represents a comment which has been traced to the source file
+// and lines of its paragraph
+type Resolved struct {
+	Comment
+	Source string
+	Lines  [2]int
+}
+
+// Read reads the comments in the file at the given path. A CSV file holds a comment on each
+// row, with the paragraph, the comment and optionally the reviewer in its columns, or in the
+// columns named by a header row. A YAML file holds a list of comments. Any other file is read
+// as text, which holds comments on lines of their own such as "<57> this feels rushed", or is
+// an annotated copy of the numbered output, whose CriticMarkup, Obsidian and HTML comments
+// are taken as comments on the paragraph they are in or follow.
+func Read(path string) ([]Comment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []Comment
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		comments, err = readCSV(string(data))
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &comments)
+	default:
+		comments = readText(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for idx := range comments {
+		comments[idx].Paragraph = label(comments[idx].Paragraph)
+		comments[idx].Text = strings.Join(strings.Fields(comments[idx].Text), " ")
+	}
+	return comments, nil
+}
+
+// readCSV reads the comments on the rows of a CSV file.
+func readCSV(data string) ([]Comment, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{"paragraph": 0, "comment": 1, "reviewer": 2}
+	first := 1
+	if len(rows) > 0 {
+		header := map[string]int{}
+		for idx, name := range rows[0] {
+			header[strings.ToLower(strings.TrimSpace(name))] = idx
+		}
+		if _, ok := header["paragraph"]; ok {
+			columns = header
+			rows = rows[1:]
+			first++
+		}
+	}
+	field := func(row []string, name string) string {
+		if idx, ok := columns[name]; ok && idx < len(row) {
+			return strings.TrimSpace(row[idx])
+		}
+		return ""
+	}
+
+	var comments []Comment
+	for idx, row := range rows {
+		comment := Comment{Paragraph: field(row, "paragraph"), Text: field(row, "comment"), Reviewer: field(row, "reviewer")}
+		if comment.Paragraph == "" && comment.Text == "" {
+			continue
+		}
+		if comment.Paragraph == "" || comment.Text == "" {
+			return nil, fmt.Errorf("row %d: want a paragraph and a comment", idx+first)
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+// readText reads the comments on lines of their own and the comments of an annotated copy of
+// a numbered output, in the order they appear.
+func readText(data string) []Comment {
+	type found struct {
+		offset  int
+		comment Comment
+	}
+	var comments []found
+
+	for _, match := range listComment.FindAllStringSubmatchIndex(data, -1) {
+		comments = append(comments, found{match[0], Comment{Paragraph: data[match[2]:match[3]], Text: data[match[4]:match[5]]}})
+	}
+	// the lists are blanked out so that their numbers are not taken for those of paragraphs,
+	// and the comments so that the text they hold is not
+	blank := func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return ' '
+		}, text)
+	}
+	text := listComment.ReplaceAllStringFunc(data, blank)
+	annotations := annotation.FindAllStringSubmatchIndex(text, -1)
+	text = annotation.ReplaceAllStringFunc(text, blank)
+
+	labels := paragraphLabel.FindAllStringSubmatchIndex(text, -1)
+	for _, match := range annotations {
+		// a comment belongs to the paragraph it is in, or else the paragraph before it
+		paragraph := ""
+		end := len(text)
+		if next := paragraphEnd.FindStringIndex(text[match[1]:]); next != nil {
+			end = match[1] + next[0]
+		}
+		for _, l := range labels {
+			if l[0] >= match[1] && l[0] < end {
+				paragraph = text[l[2]:l[3]]
+				break
+			}
+			if l[1] <= match[0] {
+				paragraph = text[l[2]:l[3]]
+			}
+		}
+		if paragraph == "" {
+			continue
+		}
+
+		for group := 2; group < len(match); group += 2 {
+			if match[group] >= 0 {
+				comments = append(comments, found{match[0], Comment{Paragraph: paragraph, Text: data[match[group]:match[group+1]]}})
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].offset < comments[j].offset })
+	var result []Comment
+	for _, comment := range comments {
+		result = append(result, comment.comment)
+	}
+	return result
+}
+
+// label returns the number or id of a paragraph without the angle brackets around it.
+func label(paragraph string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(paragraph), "<>"))
+}
+
+// Resolve traces each comment to the source file and lines of its paragraph in the output,
+// and returns those which were traced along with a warning for each of those which were not.
+func Resolve(comments []Comment, sources *processor.SourceMap, output string) ([]Resolved, []string) {
+	var resolved []Resolved
+	var warnings []string
+	for _, comment := range comments {
+		paragraph, err := sources.Locate(output, "<"+comment.Paragraph+">")
+		if err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		resolved = append(resolved, Resolved{Comment: comment, Source: paragraph.Source, Lines: paragraph.SourceLines})
+	}
+	return resolved, warnings
+}
+
+// Insert writes the comments into their source files, after the last line of the paragraph
+// each is about: as a CriticMarkup comment at the end of the line, or as an Obsidian comment
+// callout below it. Both are comments to inkwell, which are left out of the book unless it
+// is built for review.
+func Insert(resolved []Resolved, callout bool) error {
+	files := map[string][]Resolved{}
+	var order []string
+	for _, comment := range resolved {
+		if _, ok := files[comment.Source]; !ok {
+			order = append(order, comment.Source)
+		}
+		files[comment.Source] = append(files[comment.Source], comment)
+	}
+
+	for _, path := range order {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(string(data), "\n")
+
+		after := map[int][]Resolved{}
+		for _, comment := range files[path] {
+			line := min(max(comment.Lines[1], 1), len(lines))
+			after[line] = append(after[line], comment)
+		}
+
+		builder := &strings.Builder{}
+		for idx, line := range lines {
+			comments := after[idx+1]
+			ending := ""
+			if strings.HasSuffix(line, "\r") {
+				line, ending = strings.TrimSuffix(line, "\r"), "\r"
+			}
+
+			if !callout {
+				for _, comment := range comments {
+					line += " {>>" + commentText(comment.Comment) + "<<}"
+				}
+			}
+			builder.WriteString(line + ending)
+
+			if callout && len(comments) > 0 {
+				for _, comment := range comments {
+					builder.WriteString("\n" + ending)
+					builder.WriteString("\n" + strings.TrimSpace("> [!comment] "+comment.Reviewer) + ending)
+					builder.WriteString("\n> " + comment.Text + ending)
+				}
+				if idx+1 < len(lines) && strings.TrimSpace(lines[idx+1]) != "" {
+					builder.WriteString("\n" + ending)
+				}
+			}
+			if idx+1 < len(lines) {
+				builder.WriteString("\n")
+			}
+		}
+
+		err = os.WriteFile(path, []byte(builder.String()), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commentText returns the text of the comment, after the name of its reviewer if it has one.
+func commentText(comment Comment) string {
+	if comment.Reviewer == "" {
+		return comment.Text
+	}
+	return comment.Reviewer + ": " + comment.Text
+}
+
+// Report returns a report of the comments, listed under their source files in the order of
+// their lines.
+func Report(resolved []Resolved) string {
+	files := map[string][]Resolved{}
+	for _, comment := range resolved {
+		files[comment.Source] = append(files[comment.Source], comment)
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	builder := &strings.Builder{}
+	builder.WriteString("# Feedback\n")
+	for _, path := range paths {
+		comments := files[path]
+		sort.SliceStable(comments, func(i, j int) bool { return comments[i].Lines[0] < comments[j].Lines[0] })
+
+		builder.WriteString("\n## " + path + "\n\n")
+		for _, comment := range comments {
+			lines := "Line " + strconv.Itoa(comment.Lines[0])
+			if comment.Lines[1] != comment.Lines[0] {
+				lines = "Lines " + strconv.Itoa(comment.Lines[0]) + "-" + strconv.Itoa(comment.Lines[1])
+			}
+			builder.WriteString("- " + lines + " `<" + comment.Paragraph + ">` " + commentText(comment.Comment) + "\n")
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,169 @@
+package feedback
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nivthefox/inkwell/processor"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected []Comment
+	}{
+		{
+			name:    "csv",
+			file:    "notes.csv",
+			content: "57,this feels rushed\n<58>, \"Nice, but long\", Sam\n",
+			expected: []Comment{
+				{Paragraph: "57", Text: "this feels rushed"},
+				{Paragraph: "58", Text: "Nice, but long", Reviewer: "Sam"},
+			},
+		},
+		{
+			name:    "csv with a header",
+			file:    "notes.csv",
+			content: "reviewer,paragraph,comment\nSam,k3f9,Who is she?\n",
+			expected: []Comment{
+				{Paragraph: "k3f9", Text: "Who is she?", Reviewer: "Sam"},
+			},
+		},
+		{
+			name:    "yaml",
+			file:    "notes.yaml",
+			content: "- paragraph: 57\n  comment: this feels rushed\n  reviewer: Sam\n- paragraph: <k3f9>\n  comment: Who is she?\n",
+			expected: []Comment{
+				{Paragraph: "57", Text: "this feels rushed", Reviewer: "Sam"},
+				{Paragraph: "k3f9", Text: "Who is she?"},
+			},
+		},
+		{
+			name:    "list",
+			file:    "notes.txt",
+			content: "Notes on the second draft\n\n<57> this feels rushed\n<k3f9>   Who is she?\n",
+			expected: []Comment{
+				{Paragraph: "57", Text: "this feels rushed"},
+				{Paragraph: "k3f9", Text: "Who is she?"},
+			},
+		},
+		{
+			name:    "annotated copy",
+			file:    "annotated.md",
+			content: "%% before any paragraph %%\n\nMara waited{>>why?<<} in the rain. <56>\n\nThe ship <!-- which one? -->\nwas late. <57>\n\n%% too slow\nhere %%\n\nNobody came. <58>",
+			expected: []Comment{
+				{Paragraph: "56", Text: "why?"},
+				{Paragraph: "57", Text: "which one?"},
+				{Paragraph: "57", Text: "too slow here"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			comments, err := Read(path)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(comments, tt.expected) {
+				t.Errorf("Read() = %+v, want %+v", comments, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadInvalidCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.csv")
+	if err := os.WriteFile(path, []byte("57,this feels rushed\n58\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := Read(path); err == nil {
+		t.Errorf("Read() should fail for a row without a comment")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	sources := &processor.SourceMap{Outputs: []processor.SourceOutput{{
+		File: "book.md",
+		Paragraphs: []processor.SourceParagraph{
+			{Paragraph: 57, Lines: [2]int{120, 121}, Source: "one.md", SourceLines: [2]int{4, 5}},
+		},
+	}}}
+	comments := []Comment{{Paragraph: "57", Text: "this feels rushed"}, {Paragraph: "58", Text: "Who is she?"}}
+
+	resolved, warnings := Resolve(comments, sources, "book.md")
+	expected := []Resolved{{Comment: comments[0], Source: "one.md", Lines: [2]int{4, 5}}}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("Resolve() = %+v, want %+v", resolved, expected)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Resolve() warnings = %v, want a warning for paragraph 58", warnings)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	source := "---\ntitle: Rain\n---\nMara waited\nin the rain.\n\nThe ship was late.\nNobody came.\n"
+	resolved := func(path string) []Resolved {
+		return []Resolved{
+			{Comment: Comment{Paragraph: "57", Text: "this feels rushed", Reviewer: "Sam"}, Source: path, Lines: [2]int{4, 5}},
+			{Comment: Comment{Paragraph: "58", Text: "Who is she?"}, Source: path, Lines: [2]int{7, 8}},
+			{Comment: Comment{Paragraph: "57", Text: "Why?"}, Source: path, Lines: [2]int{4, 5}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		callout  bool
+		expected string
+	}{
+		{
+			name:     "critic",
+			expected: "---\ntitle: Rain\n---\nMara waited\nin the rain. {>>Sam: this feels rushed<<} {>>Why?<<}\n\nThe ship was late.\nNobody came. {>>Who is she?<<}\n",
+		},
+		{
+			name:     "callout",
+			callout:  true,
+			expected: "---\ntitle: Rain\n---\nMara waited\nin the rain.\n\n> [!comment] Sam\n> this feels rushed\n\n> [!comment]\n> Why?\n\nThe ship was late.\nNobody came.\n\n> [!comment]\n> Who is she?\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "one.md")
+			if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			if err := Insert(resolved(path), tt.callout); err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read source: %v", err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("Insert() wrote %q, want %q", content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	resolved := []Resolved{
+		{Comment: Comment{Paragraph: "58", Text: "Who is she?"}, Source: "two.md", Lines: [2]int{7, 7}},
+		{Comment: Comment{Paragraph: "57", Text: "this feels rushed", Reviewer: "Sam"}, Source: "one.md", Lines: [2]int{4, 5}},
+		{Comment: Comment{Paragraph: "56", Text: "Why?"}, Source: "one.md", Lines: [2]int{1, 1}},
+	}
+
+	expected := "# Feedback\n\n## one.md\n\n- Line 1 `<56>` Why?\n- Lines 4-5 `<57>` Sam: this feels rushed\n\n## two.md\n\n- Line 7 `<58>` Who is she?\n"
+	if result := Report(resolved); result != expected {
+		t.Errorf("Report() = %q, want %q", result, expected)
+	}
+}
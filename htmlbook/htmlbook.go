@@ -0,0 +1,407 @@
+package htmlbook
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// page is a struct that represents a single page of the book; in single file
+// output, each page becomes a section of the one document. The target is the
+// section, chapter or part which the page holds.
+type page struct {
+	id     string
+	href   string
+	title  string
+	body   string
+	target any
+}
+
+// imageTypes are the media types of the images which are embedded in the pages
+var imageTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+const base = `*, *::before, *::after { box-sizing: border-box; }
+html { -webkit-text-size-adjust: 100%; text-size-adjust: 100%; }
+body { margin: 0 auto; max-width: 38em; padding: 1em 1.25em 3em; line-height: 1.6; font-size: 1.125rem; }
+h1, h2 { text-align: center; line-height: 1.25; margin: 2em 0 1em; }
+h1.part { margin: 6em 0; font-size: 2.25em; }
+p { margin: 0; text-indent: 1.5em; }
+h1 + p, h2 + p, p.scene-break + p, blockquote p { text-indent: 0; }
+p.scene-break { text-align: center; text-indent: 0; margin: 1.5em 0; }
+blockquote { margin: 1em 1.5em; }
+pre { white-space: pre-wrap; }
+img { max-width: 100%; }
+section.title-page { text-align: center; margin: 4em 0; }
+section.title-page p, section.dedication p { text-indent: 0; }
+section.dedication { text-align: center; font-style: italic; margin: 4em 0; }
+nav.toc ol { list-style: none; padding: 0; }
+nav.toc li { margin: 0.5em 0; }
+nav.pages { display: flex; justify-content: space-between; gap: 1em; margin: 3em 0 1em; padding-top: 1em; border-top: 1px solid; }
+nav.pages a.next { margin-left: auto; text-align: right; }
+ins { text-decoration: underline; }
+del { text-decoration: line-through; opacity: 0.6; }
+span.comment { float: right; clear: right; width: 10em; margin: 0 -11em 0.5em 1em; font-size: 0.8em; line-height: 1.3; text-indent: 0; }
+`
+
+// themes are the built-in color schemes which can be selected in the config
+var themes = map[string]string{
+	"default": `body { font-family: Georgia, serif; color: #222; background: #fff; }
+a { color: #2a5db0; }
+nav.pages { border-color: #ddd; }
+`,
+	"sepia": `body { font-family: Georgia, serif; color: #5b4636; background: #f4ecd8; }
+a { color: #8a4b14; }
+nav.pages { border-color: #d8c8a8; }
+`,
+	"night": `body { font-family: Georgia, serif; color: #d0d0d0; background: #121212; }
+a { color: #8ab4f8; }
+nav.pages { border-color: #333; }
+`,
+}
+
+// File is a struct that represents a single page of a multi-page site
+type File struct {
+	Name    string
+	Content string
+}
+
+// Write renders the book as HTML. By default the whole book is written to the file with the
+// given filename; when multiple pages are enabled, the filename is a directory which receives
+// an index page and one page per section and chapter.
+func Write(book *manuscript.Book, options config.HTMLConfig, filename string) error {
+	css, err := Stylesheet(options)
+	if err != nil {
+		return err
+	}
+
+	if !options.MultiPage {
+		return os.WriteFile(filename, []byte(singlePage(book, contents(book, true), css)), 0644)
+	}
+
+	err = os.MkdirAll(filename, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range Site(book, css) {
+		err = os.WriteFile(filepath.Join(filename, file.Name), []byte(file.Content), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Site renders the book as a multi-page site with the given stylesheet: an index page with
+// the table of contents, followed by one page per section and chapter.
+func Site(book *manuscript.Book, css string) []File {
+	pages := contents(book, false)
+
+	index := frontMatter(book) + tableOfContents(book, pages, false)
+	if len(pages) > 0 {
+		index += navigation(nil, &pages[0])
+	}
+	files := []File{{Name: "index.html", Content: document(book.Title, index, book.Language, css)}}
+
+	for idx, p := range pages {
+		previous := &page{href: "index.html", title: "Contents"}
+		if idx > 0 {
+			previous = &pages[idx-1]
+		}
+		var next *page
+		if idx < len(pages)-1 {
+			next = &pages[idx+1]
+		}
+
+		body := p.body + navigation(previous, next)
+		files = append(files, File{Name: p.href, Content: document(p.title+" - "+book.Title, body, book.Language, css)})
+	}
+
+	return files
+}
+
+// Stylesheet returns the CSS for the selected theme, followed by the contents of the
+// author's own stylesheet if one is configured.
+func Stylesheet(options config.HTMLConfig) (string, error) {
+	name := options.Theme
+	if name == "" {
+		name = "default"
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return "", fmt.Errorf("unknown html theme %q", options.Theme)
+	}
+
+	css := base + theme
+	if options.Stylesheet != "" {
+		custom, err := os.ReadFile(options.Stylesheet)
+		if err != nil {
+			return "", err
+		}
+		css += string(custom)
+	}
+
+	return css, nil
+}
+
+// contents builds the sections, chapters and parts of the book in reading order; each part
+// has a page of its own ahead of its contents. Wiki links to another page link to its anchor
+// in the same document when the book is written as a single page, and local images are
+// embedded in the pages so that they need no other files.
+func contents(book *manuscript.Book, anchors bool) []page {
+	var pages []page
+
+	entries := book.Contents()
+	ids := pageIDs(entries)
+	link := func(idx int) string {
+		if anchors {
+			return "#" + ids[idx]
+		}
+		return ids[idx] + ".html"
+	}
+	render := func(doc *markdown.Node, idx int) string {
+		return markdown.RenderXHTML(embedImages(book.ResolveLinks(doc, idx, link)))
+	}
+
+	for idx, entry := range entries {
+		id := ids[idx]
+		if section := entry.Section; section != nil {
+			body := "<h1>" + escape(section.Title) + "</h1>\n" + render(section.Document, idx)
+			pages = append(pages, page{id: id, href: id + ".html", title: section.Title, body: body, target: section})
+			continue
+		}
+		if part := entry.Part; part != nil {
+			body := `<h1 class="part">` + escape(part.Title) + "</h1>\n"
+			pages = append(pages, page{id: id, href: id + ".html", title: part.Title, body: body, target: part})
+			continue
+		}
+
+		chapter := entry.Chapter
+		body := &strings.Builder{}
+		body.WriteString("<h2>" + escape(chapter.Title) + "</h2>\n")
+		for sidx, scene := range chapter.Scenes {
+			if sidx > 0 {
+				sceneBreak := markdown.RenderXHTML(book.SceneBreak(chapter))
+				// when scenes are listed in the table of contents, the scene break carries the
+				// anchor of the scene which follows it
+				if book.TOC.Scenes {
+					sceneBreak = strings.Replace(sceneBreak, `<p class="scene-break"`, `<p class="scene-break" id="`+sceneID(id, sidx)+`"`, 1)
+				}
+				body.WriteString(sceneBreak)
+			}
+			body.WriteString(render(scene.Document, idx))
+		}
+		pages = append(pages, page{id: id, href: id + ".html", title: chapter.Title, body: body.String(), target: chapter})
+	}
+
+	return pages
+}
+
+// embedImages replaces the source of each local image in the document with a data URI which
+// holds the image. Images which cannot be read, or whose type is not known, are left as they are.
+func embedImages(doc *markdown.Node) *markdown.Node {
+	markdown.Walk(doc, func(n *markdown.Node) bool {
+		if n.Kind != markdown.Image || strings.Contains(n.Destination, "://") || strings.HasPrefix(n.Destination, "data:") {
+			return true
+		}
+		media := imageTypes[strings.ToLower(filepath.Ext(n.Destination))]
+		if media == "" {
+			return false
+		}
+		data, err := os.ReadFile(filepath.FromSlash(n.Destination))
+		if err != nil {
+			return false
+		}
+		n.Destination = "data:" + media + ";base64," + base64.StdEncoding.EncodeToString(data)
+		return false
+	})
+	return doc
+}
+
+// pageIDs returns the id of the page of each section, chapter and part, which are numbered
+// apart from one another.
+func pageIDs(entries []manuscript.Entry) []string {
+	ids := make([]string, len(entries))
+	sections, chapters, parts := 0, 0, 0
+	for idx, entry := range entries {
+		switch {
+		case entry.Section != nil:
+			sections++
+			ids[idx] = "section-" + strconv.Itoa(sections)
+		case entry.Chapter != nil:
+			chapters++
+			ids[idx] = "chapter-" + strconv.Itoa(chapters)
+		default:
+			parts++
+			ids[idx] = "part-" + strconv.Itoa(parts)
+		}
+	}
+	return ids
+}
+
+// frontMatter returns the title page and dedication of the book.
+func frontMatter(book *manuscript.Book) string {
+	builder := &strings.Builder{}
+
+	if book.Title != "" {
+		builder.WriteString(`<section class="title-page">` + "\n")
+		builder.WriteString("<h1>" + escape(book.Title) + "</h1>\n")
+		if len(book.Authors) > 0 {
+			builder.WriteString("<p>By " + escape(strings.Join(book.Authors, ", ")) + "</p>\n")
+		}
+		builder.WriteString("</section>\n")
+	}
+
+	if book.Dedication != nil {
+		builder.WriteString(`<section class="dedication">` + "\n")
+		builder.WriteString(markdown.RenderXHTML(book.Dedication))
+		builder.WriteString("</section>\n")
+	}
+
+	return builder.String()
+}
+
+// tableOfContents returns the table of contents of the book, linking to anchors in the same
+// document when the book is written as a single page.
+func tableOfContents(book *manuscript.Book, pages []page, anchors bool) string {
+	entries := book.TableOfContents()
+	if len(entries) == 0 {
+		return ""
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString(`<nav class="toc" id="contents">` + "\n")
+	builder.WriteString("<h2>" + escape(book.TOCTitle()) + "</h2>\n<ol>\n")
+
+	// entries deeper than the one before them open a list within it
+	level := 0
+	for idx, entry := range entries {
+		entryLevel := min(entry.Level, level+1)
+		switch {
+		case idx == 0:
+			entryLevel = 1
+		case entryLevel > level:
+			builder.WriteString("\n<ol>\n")
+		default:
+			builder.WriteString("</li>\n")
+			for ; level > entryLevel; level-- {
+				builder.WriteString("</ol>\n</li>\n")
+			}
+		}
+		level = entryLevel
+		builder.WriteString(`<li><a href="` + href(entry, pages, anchors) + `">` + escape(entry.Title) + "</a>")
+	}
+	builder.WriteString("</li>\n")
+	for ; level > 1; level-- {
+		builder.WriteString("</ol>\n</li>\n")
+	}
+	builder.WriteString("</ol>\n</nav>\n")
+
+	return builder.String()
+}
+
+// href returns the link to the page which holds the entry of the table of contents, or to its
+// anchor when the book is written as a single page. A scene after the first of its chapter
+// links to the scene break which opens it.
+func href(entry manuscript.TOCEntry, pages []page, anchors bool) string {
+	var target any = entry.Chapter
+	if entry.Section != nil {
+		target = entry.Section
+	} else if entry.Part != nil {
+		target = entry.Part
+	}
+
+	for _, p := range pages {
+		if p.target != target {
+			continue
+		}
+		id := p.id
+		if entry.Scene != nil {
+			for idx, scene := range entry.Chapter.Scenes {
+				if entry.Scene == scene && idx > 0 {
+					id = sceneID(p.id, idx)
+				}
+			}
+		}
+		switch {
+		case anchors:
+			return "#" + id
+		case id != p.id:
+			return p.href + "#" + id
+		default:
+			return p.href
+		}
+	}
+	return ""
+}
+
+// sceneID returns the id of the scene break which opens the scene with the given index.
+func sceneID(chapter string, idx int) string {
+	return chapter + "-scene-" + strconv.Itoa(idx+1)
+}
+
+// navigation returns the links to the previous and next pages.
+func navigation(previous *page, next *page) string {
+	builder := &strings.Builder{}
+	builder.WriteString(`<nav class="pages">` + "\n")
+	if previous != nil {
+		builder.WriteString(`<a class="previous" rel="prev" href="` + previous.href + `">&larr; ` + escape(previous.title) + "</a>\n")
+	}
+	if next != nil {
+		builder.WriteString(`<a class="next" rel="next" href="` + next.href + `">` + escape(next.title) + " &rarr;</a>\n")
+	}
+	builder.WriteString("</nav>\n")
+	return builder.String()
+}
+
+// singlePage returns the whole book as one self-contained document.
+func singlePage(book *manuscript.Book, pages []page, css string) string {
+	body := &strings.Builder{}
+	body.WriteString(frontMatter(book))
+	body.WriteString(tableOfContents(book, pages, true))
+	for _, p := range pages {
+		body.WriteString(`<section id="` + p.id + `">` + "\n")
+		body.WriteString(p.body)
+		body.WriteString("</section>\n")
+	}
+	return document(book.Title, body.String(), book.Language, css)
+}
+
+// document wraps the body in a complete HTML document with the stylesheet embedded.
+func document(title string, body string, language string, css string) string {
+	builder := &strings.Builder{}
+	builder.WriteString("<!DOCTYPE html>\n")
+	builder.WriteString(`<html lang="` + escape(language) + `">` + "\n")
+	builder.WriteString("<head>\n")
+	builder.WriteString(`<meta charset="utf-8">` + "\n")
+	builder.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1">` + "\n")
+	builder.WriteString("<title>" + escape(title) + "</title>\n")
+	builder.WriteString("<style>\n" + css + "</style>\n")
+	builder.WriteString("</head>\n")
+	builder.WriteString("<body>\n")
+	builder.WriteString(body)
+	builder.WriteString("</body>\n")
+	builder.WriteString("</html>\n")
+	return builder.String()
+}
+
+// escape escapes the text for use in HTML content and attributes.
+func escape(text string) string {
+	return html.EscapeString(text)
+}
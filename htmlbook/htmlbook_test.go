@@ -0,0 +1,207 @@
+package htmlbook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func testBook() *manuscript.Book {
+	return &manuscript.Book{
+		Title:          "Test Book",
+		Authors:        []string{"Author One"},
+		Language:       "en",
+		SceneSeparator: "\\* \\* \\*",
+		Dedication:     markdown.Parse("To my family"),
+		Sections: []*manuscript.Section{
+			{Title: "Prologue", Document: markdown.Parse("Before it all began.")},
+		},
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("First scene with *emphasis*.")},
+				{Document: markdown.Parse("Second scene.")},
+			}},
+			{Title: "Chapter 2", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("The end.")},
+			}},
+		},
+	}
+}
+
+func TestWriteSinglePage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "book.html")
+	err := Write(testBook(), config.HTMLConfig{Theme: "sepia"}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+
+	expected := []string{
+		`<html lang="en">`,
+		`<meta name="viewport"`,
+		"<style>",
+		"#f4ecd8",
+		"<h1>Test Book</h1>",
+		"To my family",
+		`<a href="#section-1">Prologue</a>`,
+		`<a href="#chapter-2">Chapter 2</a>`,
+		`<section id="chapter-1">`,
+		"<em>emphasis</em>",
+		`<p class="scene-break">* * *</p>`,
+	}
+	for _, part := range expected {
+		if !strings.Contains(string(content), part) {
+			t.Errorf("output should contain %q", part)
+		}
+	}
+}
+
+func TestWriteMultiPage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "site")
+	err := Write(testBook(), config.HTMLConfig{MultiPage: true}, dir)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	expected := map[string][]string{
+		"index.html":     {"<h1>Test Book</h1>", `<a href="chapter-1.html">Chapter 1</a>`, `rel="next" href="section-1.html"`},
+		"section-1.html": {"<h1>Prologue</h1>", `rel="prev" href="index.html"`, `rel="next" href="chapter-1.html"`},
+		"chapter-1.html": {"<h2>Chapter 1</h2>", `rel="prev" href="section-1.html"`, `rel="next" href="chapter-2.html"`},
+		"chapter-2.html": {"The end.", `rel="prev" href="chapter-1.html"`},
+	}
+	for name, parts := range expected {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("Failed to read %s: %v", name, err)
+			continue
+		}
+		for _, part := range parts {
+			if !strings.Contains(string(content), part) {
+				t.Errorf("%s should contain %q", name, part)
+			}
+		}
+	}
+
+	last, _ := os.ReadFile(filepath.Join(dir, "chapter-2.html"))
+	if strings.Contains(string(last), `rel="next"`) {
+		t.Error("last page should not link to a next page")
+	}
+}
+
+func TestSiteParts(t *testing.T) {
+	book := testBook()
+	book.Parts = []*manuscript.Part{
+		{Title: "Part Two", Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 3", Scenes: []*manuscript.Scene{{Document: markdown.Parse("Later.")}}},
+		}},
+	}
+
+	files := Site(book, "")
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	expected := []string{"index.html", "section-1.html", "chapter-1.html", "chapter-2.html", "part-1.html", "chapter-3.html"}
+	if strings.Join(names, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Site() pages = %v, want %v", names, expected)
+	}
+	if !strings.Contains(files[4].Content, `<h1 class="part">Part Two</h1>`) {
+		t.Errorf("part page should show the title of the part, got %q", files[4].Content)
+	}
+}
+
+func TestWikiLinks(t *testing.T) {
+	book := testBook()
+	book.Links = map[string]int{"chapter 2": 2}
+	book.Chapters[0].Scenes[1].Document = markdown.Parse("Until [[Chapter 2|the end]], or [[Nowhere]].")
+
+	single := singlePage(book, contents(book, true), "")
+	if !strings.Contains(single, `Until <a href="#chapter-2">the end</a>, or Nowhere.`) {
+		t.Errorf("single page should link to the anchor of the chapter, got %q", single)
+	}
+
+	files := Site(book, "")
+	if !strings.Contains(files[2].Content, `Until <a href="chapter-2.html">the end</a>`) {
+		t.Errorf("multi-page site should link to the page of the chapter, got %q", files[2].Content)
+	}
+}
+
+func TestEmbedImages(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "map.png")
+	if err := os.WriteFile(image, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	book := testBook()
+	book.Chapters[1].Scenes[0].Document = markdown.Parse("![The bay](" + filepath.ToSlash(image) + ") ![Remote](https://example.com/a.png)")
+
+	single := singlePage(book, contents(book, true), "")
+	if !strings.Contains(single, `<img src="data:image/png;base64,cG5n" alt="The bay"/>`) {
+		t.Errorf("local images should be embedded in the page, got %q", single)
+	}
+	if !strings.Contains(single, `<img src="https://example.com/a.png" alt="Remote"/>`) {
+		t.Errorf("remote images should be left as they are, got %q", single)
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	book := testBook()
+	book.TOC = manuscript.TOCOptions{Title: "In This Book", Scenes: true}
+	book.Chapters[1].ExcludeFromTOC = true
+	book.Chapters[0].Scenes[0].Number = "1"
+	book.Chapters[0].Scenes[1].Title = "Nightfall"
+
+	pages := contents(book, true)
+	single := tableOfContents(book, pages, true)
+	expected := []string{
+		"<h2>In This Book</h2>",
+		`<li><a href="#section-1">Prologue</a></li>`,
+		`<li><a href="#chapter-1">Chapter 1</a>` + "\n<ol>\n" + `<li><a href="#chapter-1">Scene 1</a></li>`,
+		`<li><a href="#chapter-1-scene-2">Nightfall</a></li>`,
+	}
+	for _, part := range expected {
+		if !strings.Contains(single, part) {
+			t.Errorf("tableOfContents() = %q, want %q", single, part)
+		}
+	}
+	if strings.Contains(single, "Chapter 2") {
+		t.Error("tableOfContents() should leave out an excluded chapter")
+	}
+	if !strings.Contains(pages[1].body, `<p class="scene-break" id="chapter-1-scene-2">`) {
+		t.Errorf("chapter should anchor its second scene, got %q", pages[1].body)
+	}
+
+	multi := tableOfContents(book, pages, false)
+	if !strings.Contains(multi, `<a href="chapter-1.html#chapter-1-scene-2">Nightfall</a>`) {
+		t.Errorf("tableOfContents() = %q, want links to the anchors on other pages", multi)
+	}
+}
+
+func TestStylesheet(t *testing.T) {
+	custom := filepath.Join(t.TempDir(), "custom.css")
+	err := os.WriteFile(custom, []byte("body { font-size: 2em; }\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write stylesheet: %v", err)
+	}
+
+	css, err := Stylesheet(config.HTMLConfig{Theme: "night", Stylesheet: custom})
+	if err != nil {
+		t.Fatalf("Stylesheet() error = %v", err)
+	}
+	if !strings.Contains(css, "#121212") || !strings.HasSuffix(css, "body { font-size: 2em; }\n") {
+		t.Errorf("Stylesheet() should contain the theme followed by the custom stylesheet, got %q", css)
+	}
+
+	_, err = Stylesheet(config.HTMLConfig{Theme: "missing"})
+	if err == nil {
+		t.Error("Stylesheet() should return an error for an unknown theme")
+	}
+}
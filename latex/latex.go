@@ -0,0 +1,243 @@
+package latex
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+const defaultPreamble = `\documentclass[12pt]{book}
+\usepackage[T1]{fontenc}
+\usepackage[utf8]{inputenc}
+\usepackage{lmodern}
+\usepackage{graphicx}
+\usepackage{hyperref}
+\hypersetup{pdftitle={$title$}, pdfauthor={$author$}}
+`
+
+// escapes are the replacements for the characters which are special to LaTeX
+var escapes = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+	"\t", " ",
+)
+
+// Write renders the book as LaTeX source and writes it to the file with the given filename.
+func Write(book *manuscript.Book, options config.LatexConfig, filename string) error {
+	preamble := defaultPreamble
+	if options.Preamble != "" {
+		template, err := os.ReadFile(options.Preamble)
+		if err != nil {
+			return err
+		}
+		preamble = string(template)
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString(strings.NewReplacer(
+		"$title$", Escape(book.Title),
+		"$author$", Escape(strings.Join(book.Authors, ", ")),
+		"$summary$", Escape(book.Summary),
+		"$language$", Escape(book.Language),
+	).Replace(preamble))
+	if !strings.HasSuffix(preamble, "\n") {
+		builder.WriteString("\n")
+	}
+
+	// the preamble may define these itself
+	sceneBreak := options.SceneBreak
+	if sceneBreak == "" {
+		sceneBreak = centeredBreak(markdown.NewSceneBreak(book.SceneSeparator))
+	}
+	builder.WriteString(`\providecommand{\scenebreak}{` + sceneBreak + "}\n")
+	builder.WriteString(`\ifcsname dedication\endcsname\else` + "\n")
+	builder.WriteString(`\newenvironment{dedication}{\cleardoublepage\thispagestyle{empty}\vspace*{\stretch{1}}\begin{center}\itshape}{\end{center}\vspace*{\stretch{3}}\cleardoublepage}` + "\n")
+	builder.WriteString(`\fi` + "\n")
+	builder.WriteString(`\providecommand{\href}[2]{#2}` + "\n")
+	builder.WriteString(`\providecommand{\includegraphics}[2][]{}` + "\n\n")
+
+	builder.WriteString(`\title{` + Escape(book.Title) + "}\n")
+	authors := make([]string, len(book.Authors))
+	for idx, author := range book.Authors {
+		authors[idx] = Escape(author)
+	}
+	builder.WriteString(`\author{` + strings.Join(authors, ` \and `) + "}\n")
+	builder.WriteString(`\date{}` + "\n\n")
+
+	builder.WriteString(`\begin{document}` + "\n")
+	builder.WriteString(`\frontmatter` + "\n")
+	if book.Title != "" {
+		builder.WriteString(`\maketitle` + "\n")
+	}
+	if book.Dedication != nil {
+		builder.WriteString(`\begin{dedication}` + "\n")
+		builder.WriteString(blocks(book.Dedication.Children))
+		builder.WriteString(`\end{dedication}` + "\n")
+	}
+	builder.WriteString(`\mainmatter` + "\n")
+
+	backmatter := false
+	for _, entry := range book.Contents() {
+		if part := entry.Part; part != nil {
+			builder.WriteString("\n" + `\part{` + Escape(part.Title) + "}\n")
+			continue
+		}
+		if section := entry.Section; section != nil {
+			// only the back matter of the book itself starts the back matter; a part keeps its own
+			if section.Placement == manuscript.Back && entry.Depth == 0 && !backmatter {
+				builder.WriteString("\n" + `\backmatter` + "\n")
+				backmatter = true
+			}
+			// sections are unnumbered chapters, listed in the table of contents
+			title := Escape(section.Title)
+			builder.WriteString("\n" + `\chapter*{` + title + "}\n")
+			builder.WriteString(`\addcontentsline{toc}{chapter}{` + title + "}\n")
+			builder.WriteString(`\markboth{` + title + "}{" + title + "}\n\n")
+			builder.WriteString(blocks(section.Document.Children))
+			continue
+		}
+
+		chapter := entry.Chapter
+		builder.WriteString("\n" + `\chapter{` + Escape(chapter.Title) + "}\n\n")
+		// a chapter with its own separator draws it in place of the macro, unless the macro is configured
+		sceneBreak := `\scenebreak`
+		if options.SceneBreak == "" && chapter.SceneBreak != nil && chapter.SceneBreak.Literal != book.SceneSeparator {
+			sceneBreak = centeredBreak(chapter.SceneBreak)
+		}
+		for idx, scene := range chapter.Scenes {
+			if idx > 0 {
+				builder.WriteString(sceneBreak + "\n\n")
+			}
+			builder.WriteString(blocks(scene.Document.Children))
+		}
+	}
+
+	builder.WriteString("\n" + `\end{document}` + "\n")
+
+	return os.WriteFile(filename, []byte(builder.String()), 0644)
+}
+
+// centeredBreak returns the LaTeX for a scene break which sets its text centered between two skips.
+func centeredBreak(n *markdown.Node) string {
+	return `\par\bigskip{\centering ` + Escape(markdown.PlainText(n)) + `\par}\bigskip`
+}
+
+// Escape escapes the characters of the text which are special to LaTeX, and keeps apart the
+// characters which TeX would join into a dash, an inverted mark or an opening quote.
+func Escape(text string) string {
+	builder := &strings.Builder{}
+	var previous rune
+	for _, r := range escapes.Replace(text) {
+		if isLigature(previous, r) {
+			builder.WriteString("{}")
+		}
+		builder.WriteRune(r)
+		previous = r
+	}
+	return builder.String()
+}
+
+// isLigature reports whether TeX joins the two characters into one, as it does -- and ---
+// into dashes, ?` and !` into inverted marks, and two backquotes into an opening quote.
+func isLigature(previous rune, r rune) bool {
+	switch r {
+	case '-':
+		return previous == '-'
+	case '`':
+		return previous == '?' || previous == '!' || previous == '`'
+	}
+	return false
+}
+
+// blocks renders a list of block nodes, each followed by a blank line.
+func blocks(nodes []*markdown.Node) string {
+	builder := &strings.Builder{}
+	for _, n := range nodes {
+		switch n.Kind {
+		case markdown.Paragraph:
+			builder.WriteString(inlines(n.Children) + "\n\n")
+
+		case markdown.Heading:
+			command := `\paragraph*`
+			switch n.Level {
+			case 1:
+				command = `\section*`
+			case 2:
+				command = `\subsection*`
+			case 3:
+				command = `\subsubsection*`
+			}
+			builder.WriteString(command + "{" + inlines(n.Children) + "}\n\n")
+
+		case markdown.BlockQuote:
+			builder.WriteString(`\begin{quote}` + "\n" + blocks(n.Children) + `\end{quote}` + "\n\n")
+
+		case markdown.List:
+			environment := "itemize"
+			if n.Ordered {
+				environment = "enumerate"
+			}
+			builder.WriteString(`\begin{` + environment + "}\n")
+			if n.Ordered && n.Start != 1 {
+				builder.WriteString(`\setcounter{enumi}{` + strconv.Itoa(n.Start-1) + "}\n")
+			}
+			for _, item := range n.Children {
+				// the empty group keeps text which opens with [ from being read as the label
+				builder.WriteString(`\item{} ` + strings.TrimRight(blocks(item.Children), "\n") + "\n")
+			}
+			builder.WriteString(`\end{` + environment + "}\n\n")
+
+		case markdown.CodeBlock:
+			builder.WriteString(`\begin{verbatim}` + "\n" + n.Literal + `\end{verbatim}` + "\n\n")
+
+		case markdown.ThematicBreak, markdown.SceneBreak:
+			builder.WriteString(`\scenebreak` + "\n\n")
+		}
+	}
+	return builder.String()
+}
+
+// inlines renders a list of inline nodes.
+func inlines(nodes []*markdown.Node) string {
+	builder := &strings.Builder{}
+	for _, n := range nodes {
+		switch n.Kind {
+		case markdown.Text, markdown.WikiLink:
+			builder.WriteString(Escape(n.Literal))
+		case markdown.SoftBreak:
+			builder.WriteString("\n")
+		case markdown.HardBreak:
+			builder.WriteString(`\\` + "\n")
+		case markdown.Emphasis:
+			builder.WriteString(`\emph{` + inlines(n.Children) + "}")
+		case markdown.Strong:
+			builder.WriteString(`\textbf{` + inlines(n.Children) + "}")
+		case markdown.Code:
+			builder.WriteString(`\texttt{` + Escape(n.Literal) + "}")
+		case markdown.Link:
+			destination := strings.NewReplacer(`\`, `\\`, `#`, `\#`, `%`, `\%`).Replace(n.Destination)
+			builder.WriteString(`\href{` + destination + "}{" + inlines(n.Children) + "}")
+		case markdown.Image:
+			destination := strings.NewReplacer(`\`, `/`, `#`, `\#`, `%`, `\%`).Replace(n.Destination)
+			builder.WriteString(`\includegraphics[width=\linewidth]{` + destination + "}")
+		case markdown.Comment:
+			builder.WriteString(`\marginpar{\footnotesize ` + Escape(n.Literal) + "}")
+		case markdown.HTMLInline, markdown.Deletion:
+		default:
+			builder.WriteString(inlines(n.Children))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,183 @@
+package latex
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func testBook() *manuscript.Book {
+	return &manuscript.Book{
+		Title:          "Tom & Jerry",
+		Authors:        []string{"Author One", "Author Two"},
+		Language:       "en",
+		SceneSeparator: "\\* \\* \\*",
+		Dedication:     markdown.Parse("To my family"),
+		Sections: []*manuscript.Section{
+			{Title: "Prologue", Document: markdown.Parse("Before it all began.")},
+			{Title: "Afterword", Placement: manuscript.Back, Document: markdown.Parse("After it all ended.")},
+		},
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse("It cost $5 -- 100% of it_all.")},
+				{Document: markdown.Parse("Some *emphasis* and **strong** text.")},
+				{Document: markdown.Parse("![The bay](maps/bay-100%.png)\n\nThe bay{-- was--}.{>>100% sure?<<}")},
+			}},
+		},
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "plain text", expected: "plain text"},
+		{input: "100% & $5", expected: `100\% \& \$5`},
+		{input: "a_b #1", expected: `a\_b \#1`},
+		{input: `{\}`, expected: `\{\textbackslash{}\}`},
+		{input: "~^", expected: `\textasciitilde{}\textasciicircum{}`},
+		{input: "a--b---c", expected: `a-{}-b-{}-{}-c`},
+		{input: "why?` no!` ``quoted", expected: "why?{}` no!{}` `{}`quoted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result := Escape(tt.input)
+			if result != tt.expected {
+				t.Errorf("Escape(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBlocksList(t *testing.T) {
+	result := blocks(markdown.Parse("- [draft] first\n- second").Children)
+	expected := "\\begin{itemize}\n\\item{} [draft] first\n\\item{} second\n\\end{itemize}\n\n"
+	if result != expected {
+		t.Errorf("blocks() = %q, want %q", result, expected)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "book.tex")
+	err := Write(testBook(), config.LatexConfig{}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+
+	expected := []string{
+		`\documentclass[12pt]{book}`,
+		`pdftitle={Tom \& Jerry}`,
+		`\providecommand{\scenebreak}{\par\bigskip{\centering * * *\par}\bigskip}`,
+		`\author{Author One \and Author Two}`,
+		`\begin{dedication}` + "\nTo my family\n\n" + `\end{dedication}`,
+		`\chapter*{Prologue}` + "\n" + `\addcontentsline{toc}{chapter}{Prologue}`,
+		`\chapter{Chapter 1}`,
+		`It cost \$5 -{}- 100\% of it\_all.`,
+		`\scenebreak`,
+		`Some \emph{emphasis} and \textbf{strong} text.`,
+		`\includegraphics[width=\linewidth]{maps/bay-100\%.png}`,
+		`The bay.\marginpar{\footnotesize 100\% sure?}`,
+		`\end{document}`,
+	}
+	for _, part := range expected {
+		if !strings.Contains(string(content), part) {
+			t.Errorf("output should contain %q", part)
+		}
+	}
+
+	chapter := strings.Index(string(content), `\chapter{Chapter 1}`)
+	backmatter := strings.Index(string(content), `\backmatter`)
+	afterword := strings.Index(string(content), `\chapter*{Afterword}`)
+	if !(strings.Index(string(content), `\chapter*{Prologue}`) < chapter && chapter < backmatter && backmatter < afterword) {
+		t.Error("front sections should precede the chapters, and back sections follow them in the back matter")
+	}
+}
+
+func TestWriteChapterSeparator(t *testing.T) {
+	book := testBook()
+	book.Chapters[0].SceneBreak = markdown.NewSceneBreak("~")
+
+	filename := filepath.Join(t.TempDir(), "book.tex")
+	err := Write(book, config.LatexConfig{}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+	if !strings.Contains(string(content), `\par\bigskip{\centering \textasciitilde{}\par}\bigskip`) {
+		t.Errorf("output should draw the separator of the chapter, got %q", content)
+	}
+}
+
+func TestWriteParts(t *testing.T) {
+	book := testBook()
+	book.Parts = []*manuscript.Part{
+		{Title: "Part Two", Sections: []*manuscript.Section{
+			{Title: "Interlude", Placement: manuscript.Back, Document: markdown.Parse("A pause.")},
+		}, Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 2", Scenes: []*manuscript.Scene{{Document: markdown.Parse("Later.")}}},
+		}},
+	}
+
+	filename := filepath.Join(t.TempDir(), "book.tex")
+	err := Write(book, config.LatexConfig{}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+	output := string(content)
+	part := strings.Index(output, `\part{Part Two}`)
+	if part < 0 || part > strings.Index(output, `\chapter{Chapter 2}`) {
+		t.Errorf("output should open the part before its chapters, got %q", output)
+	}
+	if strings.Index(output, `\backmatter`) < strings.Index(output, `\chapter*{Interlude}`) {
+		t.Errorf("the back matter of a part should not start the back matter of the book, got %q", output)
+	}
+}
+
+func TestWritePreamble(t *testing.T) {
+	dir := t.TempDir()
+	preamble := filepath.Join(dir, "preamble.tex")
+	err := os.WriteFile(preamble, []byte(`\documentclass{memoir}`+"\n"+`% $title$ by $author$`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write preamble: %v", err)
+	}
+
+	filename := filepath.Join(dir, "book.tex")
+	err = Write(testBook(), config.LatexConfig{Preamble: preamble, SceneBreak: `\fancybreak`}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	content, _ := os.ReadFile(filename)
+	if !strings.HasPrefix(string(content), `\documentclass{memoir}`+"\n"+`% Tom \& Jerry by Author One, Author Two`+"\n") {
+		t.Errorf("output should start with the filled in preamble, got %q", string(content)[:80])
+	}
+	if !strings.Contains(string(content), `\providecommand{\scenebreak}{\fancybreak}`) {
+		t.Error("output should define the configured scene break")
+	}
+
+	err = Write(testBook(), config.LatexConfig{Preamble: filepath.Join(dir, "missing.tex")}, filename)
+	if err == nil {
+		t.Error("Write() should return an error for a missing preamble")
+	}
+}
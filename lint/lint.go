@@ -0,0 +1,169 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+// Problem is a struct that represents a single issue found in the configuration or its files
+type Problem struct {
+	File    string
+	Message string
+}
+
+// String formats the problem for display, prefixed with its file if it has one.
+func (p Problem) String() string {
+	if p.File == "" {
+		return p.Message
+	}
+	return p.File + ": " + p.Message
+}
+
+// Check inspects the configuration and the files it lists, and returns the problems found
+// in the order they appear in the configuration.
+func Check(cfg config.InkwellConfig) []Problem {
+	var problems []Problem
+	report := func(file string, format string, args ...any) {
+		problems = append(problems, Problem{File: file, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(cfg.Title) == "" {
+		report("", "title is not set")
+	}
+	if len(cfg.Authors) == 0 {
+		report("", "no authors are listed")
+	}
+	if cfg.ChapterCount() == 0 {
+		report("", "no chapters are configured")
+	}
+	switch cfg.ChapterNumbering.Style {
+	case "", "arabic", "roman", "words", "none":
+	default:
+		report("", "chapter numbering has an unknown style %q", cfg.ChapterNumbering.Style)
+	}
+	switch cfg.SummaryFormat {
+	case "", "yaml", "json", "csv", "markdown":
+	default:
+		report("", "summary format %q is not yaml, json, csv or markdown", cfg.SummaryFormat)
+	}
+	switch cfg.WordCount.Rule {
+	case "", "word", "scrivener", "publisher", "cjk":
+	default:
+		report("", "word count rule %q is not word, scrivener, publisher or cjk", cfg.WordCount.Rule)
+	}
+	switch cfg.WordCount.Characters {
+	case "", "graphemes", "runes":
+	default:
+		report("", "word count characters %q are not graphemes or runes", cfg.WordCount.Characters)
+	}
+
+	var sources []string
+	if cfg.DedicationFilename != "" {
+		sources = append(sources, cfg.DedicationFilename)
+	}
+	outputs := map[string]string{}
+	addOutput := func(filename config.OutputFilename, key string) {
+		if filename != "" {
+			outputs[filepath.Clean(string(filename))] = key
+		}
+	}
+	addOutput(cfg.OutputFilename, "output_filename")
+	addOutput(cfg.SummaryFilename, "summary_filename")
+	addOutput(cfg.OutputEpub, "output_epub")
+	addOutput(cfg.OutputDocx, "output_docx")
+	addOutput(cfg.OutputPDF, "output_pdf")
+	addOutput(cfg.OutputLatex, "output_latex")
+	if !cfg.HTML.MultiPage {
+		addOutput(cfg.OutputHTML, "output_html")
+	}
+
+	// the sections and chapters of the book and of each part are checked in turn; a section
+	// placed before a chapter refers to the chapters beside it
+	var contents func(holder string, sections []config.SectionConfig, chapters []config.ChapterConfig, parts []config.PartConfig)
+	contents = func(holder string, sections []config.SectionConfig, chapters []config.ChapterConfig, parts []config.PartConfig) {
+		if len(chapters) > 0 && len(parts) > 0 {
+			report("", "%s holds both chapters and parts, whose order cannot be kept", holder)
+		}
+		for _, section := range sections {
+			if len(section.Files) == 0 {
+				report("", "section %q has no files", section.Title)
+			}
+			switch number, ok := strings.CutPrefix(section.Placement, "before_chapter:"); {
+			case section.Placement == "" || section.Placement == "front" || section.Placement == "back":
+			case !ok:
+				report("", "section %q has an unknown placement %q", section.Title, section.Placement)
+			default:
+				chapter, err := strconv.Atoi(strings.TrimSpace(number))
+				if err != nil || chapter < 1 {
+					report("", "section %q has an unknown placement %q", section.Title, section.Placement)
+				} else if chapter > len(chapters) {
+					report("", "section %q is placed before chapter %d, but there are only %d chapters", section.Title, chapter, len(chapters))
+				}
+			}
+			sources = append(sources, section.Files...)
+			addOutput(section.OutputFilename, "output_filename")
+		}
+
+		for _, chapter := range chapters {
+			if len(chapter.Scenes) == 0 {
+				report("", "chapter %q has no scenes", chapter.Title)
+			}
+			addOutput(chapter.OutputFilename, "output_filename")
+			for idx, scene := range chapter.Scenes {
+				if len(scene.Files) == 0 {
+					report("", "scene %d of chapter %q has no files", idx+1, chapter.Title)
+				}
+				sources = append(sources, scene.Files...)
+				addOutput(scene.OutputFilename, "output_filename")
+			}
+		}
+
+		for _, part := range parts {
+			if len(part.Sections) == 0 && len(part.Chapters) == 0 && len(part.Parts) == 0 {
+				report("", "part %q is empty", part.Title)
+			}
+			addOutput(part.OutputFilename, "output_filename")
+			contents(fmt.Sprintf("part %q", part.Title), part.Sections, part.Chapters, part.Parts)
+		}
+	}
+	contents("the book", cfg.Sections, cfg.Chapters, cfg.Parts)
+
+	seen := map[string]int{}
+	for _, source := range sources {
+		seen[filepath.Clean(source)]++
+	}
+
+	for _, source := range sources {
+		clean := filepath.Clean(source)
+		count := seen[clean]
+		if count == 0 {
+			// already reported
+			continue
+		}
+		seen[clean] = 0
+
+		if count > 1 {
+			report(source, "file is included %d times", count)
+		}
+		if key, ok := outputs[clean]; ok {
+			report(source, "file is overwritten by %s", key)
+		}
+
+		info, err := os.Stat(source)
+		switch {
+		case err != nil:
+			report(source, "file cannot be read: %v", err)
+		case info.IsDir():
+			report(source, "file is a directory")
+		case info.Size() == 0:
+			report(source, "file is empty")
+		}
+	}
+
+	return problems
+}
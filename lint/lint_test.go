@@ -0,0 +1,189 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.md")
+	empty := filepath.Join(dir, "empty.md")
+	missing := filepath.Join(dir, "missing.md")
+	_ = os.WriteFile(good, []byte("Some text."), 0644)
+	_ = os.WriteFile(empty, []byte{}, 0644)
+
+	cfg := config.InkwellConfig{
+		Title:   "Test Book",
+		Authors: []string{"Author One"},
+		Chapters: []config.ChapterConfig{
+			{Title: "Chapter 1", Scenes: []config.SceneConfig{
+				{Files: []string{good, empty}},
+				{Files: []string{}},
+			}},
+			{Title: "Chapter 2", OutputFilename: config.OutputFilename(good), Scenes: []config.SceneConfig{
+				{Files: []string{good, missing}},
+			}},
+			{Title: "Chapter 3"},
+		},
+	}
+
+	expected := []Problem{
+		{Message: `scene 2 of chapter "Chapter 1" has no files`},
+		{Message: `chapter "Chapter 3" has no scenes`},
+		{File: good, Message: "file is included 2 times"},
+		{File: good, Message: "file is overwritten by output_filename"},
+		{File: empty, Message: "file is empty"},
+	}
+
+	problems := Check(cfg)
+	if len(problems) != len(expected)+1 {
+		t.Fatalf("Check() returned %d problems, want %d: %v", len(problems), len(expected)+1, problems)
+	}
+	for idx, problem := range expected {
+		if problems[idx] != problem {
+			t.Errorf("problem %d = %q, want %q", idx, problems[idx], problem)
+		}
+	}
+	if problems[len(expected)].File != missing {
+		t.Errorf("last problem = %q, want a problem with %s", problems[len(expected)], missing)
+	}
+}
+
+func TestCheckBookSettings(t *testing.T) {
+	problems := Check(config.InkwellConfig{})
+	expected := []string{"title is not set", "no authors are listed", "no chapters are configured"}
+
+	if len(problems) != len(expected) {
+		t.Fatalf("Check() returned %d problems, want %d: %v", len(problems), len(expected), problems)
+	}
+	for idx, message := range expected {
+		if problems[idx].String() != message {
+			t.Errorf("problem %d = %q, want %q", idx, problems[idx], message)
+		}
+	}
+}
+
+func TestCheckNumberingStyle(t *testing.T) {
+	cfg := config.InkwellConfig{
+		Title:            "Test Book",
+		Authors:          []string{"Author One"},
+		ChapterNumbering: config.NumberingConfig{Style: "greek"},
+	}
+
+	problems := Check(cfg)
+	if len(problems) != 2 || problems[1].Message != `chapter numbering has an unknown style "greek"` {
+		t.Errorf("Check() = %v, want the unknown numbering style reported", problems)
+	}
+}
+
+func TestCheckSummaryFormat(t *testing.T) {
+	cfg := config.InkwellConfig{
+		Title:         "Test Book",
+		Authors:       []string{"Author One"},
+		SummaryFormat: "xml",
+	}
+
+	problems := Check(cfg)
+	if len(problems) != 2 || problems[1].Message != `summary format "xml" is not yaml, json, csv or markdown` {
+		t.Errorf("Check() = %v, want the unknown summary format reported", problems)
+	}
+}
+
+func TestCheckWordCount(t *testing.T) {
+	cfg := config.InkwellConfig{
+		Title:     "Test Book",
+		Authors:   []string{"Author One"},
+		WordCount: config.WordCountConfig{Rule: "pages", Characters: "bytes"},
+	}
+
+	problems := Check(cfg)
+	if len(problems) != 3 || problems[1].Message != `word count rule "pages" is not word, scrivener, publisher or cjk` ||
+		problems[2].Message != `word count characters "bytes" are not graphemes or runes` {
+		t.Errorf("Check() = %v, want the unknown word count rules reported", problems)
+	}
+}
+
+func TestCheckPlacement(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.md")
+	_ = os.WriteFile(good, []byte("Some text."), 0644)
+
+	cfg := config.InkwellConfig{
+		Title:   "Test Book",
+		Authors: []string{"Author One"},
+		Sections: []config.SectionConfig{
+			{Title: "Prologue", Placement: "before_chapter:1", Files: []string{good}},
+			{Title: "Interlude", Placement: "before_chapter:3", Files: []string{good}},
+			{Title: "Appendix", Placement: "end", Files: []string{good}},
+		},
+		Chapters: []config.ChapterConfig{
+			{Title: "Chapter 1", Scenes: []config.SceneConfig{{Files: []string{good}}}},
+		},
+	}
+
+	var messages []string
+	for _, problem := range Check(cfg) {
+		if problem.File == "" {
+			messages = append(messages, problem.Message)
+		}
+	}
+	expected := []string{
+		`section "Interlude" is placed before chapter 3, but there are only 1 chapters`,
+		`section "Appendix" has an unknown placement "end"`,
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("Check() reported %v, want %v", messages, expected)
+	}
+	for idx, message := range expected {
+		if messages[idx] != message {
+			t.Errorf("problem %d = %q, want %q", idx, messages[idx], message)
+		}
+	}
+}
+
+func TestCheckParts(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.md")
+	_ = os.WriteFile(good, []byte("Some text."), 0644)
+	prelude := filepath.Join(dir, "prelude.md")
+	_ = os.WriteFile(prelude, []byte("Some text."), 0644)
+
+	cfg := config.InkwellConfig{
+		Title:   "Test Book",
+		Authors: []string{"Author One"},
+		Chapters: []config.ChapterConfig{
+			{Title: "Prelude", Scenes: []config.SceneConfig{{Files: []string{prelude}}}},
+		},
+		Parts: []config.PartConfig{
+			{Title: "Part One", Sections: []config.SectionConfig{
+				{Title: "Interlude", Placement: "before_chapter:2", Files: []string{good}},
+			}, Chapters: []config.ChapterConfig{
+				{Title: "Chapter 1", Scenes: []config.SceneConfig{{}}},
+			}},
+			{Title: "Part Two"},
+		},
+	}
+
+	var messages []string
+	for _, problem := range Check(cfg) {
+		messages = append(messages, problem.Message)
+	}
+	expected := []string{
+		`the book holds both chapters and parts, whose order cannot be kept`,
+		`section "Interlude" is placed before chapter 2, but there are only 1 chapters`,
+		`scene 1 of chapter "Chapter 1" has no files`,
+		`part "Part Two" is empty`,
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("Check() reported %v, want %v", messages, expected)
+	}
+	for idx, message := range expected {
+		if messages[idx] != message {
+			t.Errorf("problem %d = %q, want %q", idx, messages[idx], message)
+		}
+	}
+}
@@ -1,31 +1,137 @@
 package main
 
 import (
+	"errors"
 	"flag"
-	"github.com/nivthefox/inkwell/config"
-	"github.com/nivthefox/inkwell/processor"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
 )
 
+const defaultConfig = ".inkwell.yaml"
+
+// command is a struct that represents a subcommand of the inkwell tool
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// usageError is a struct that represents an error in the arguments of a command
+type usageError struct {
+	error
+}
+
+var commands = []command{
+	{"build", "compile the book into the outputs listed in the config", runBuild},
+	{"stats", "print word and character counts without writing any files", runStats},
+	{"init", "create a new .inkwell.yaml in the current directory", runInit},
+	{"lint", "check the config and its source files for problems", runLint},
+	{"feedback", "import comments from readers into the source files", runFeedback},
+	{"locate", "find the source file and line of a line or paragraph of an output", runLocate},
+	{"watch", "rebuild the book whenever a source file changes", runWatch},
+	{"serve", "preview the book in a browser, reloading as files change", runServe},
+}
+
 func main() {
-	var err error
+	os.Exit(run(os.Args[1:]))
+}
 
-	path := flag.String("config", "", "path to the config file")
-	flag.Parse()
+// run runs the command named by the first argument and returns the exit code.
+func run(args []string) (code int) {
+	// errors are returned by the commands; a panic is a bug in inkwell, reported with its stack
+	// so that it can be fixed rather than passed off as a problem with the book
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "inkwell: internal error: %v\n", r)
+			fmt.Fprintf(os.Stderr, "This is a bug in inkwell. Please report it at https://github.com/nivthefox/inkwell/issues, with the details below.\n\n%s", debug.Stack())
+			code = 1
+		}
+	}()
 
-	if *path == "" {
-		panic("no config file provided")
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
 	}
 
-	// Load the config file
-	var cfg *config.InkwellConfig
-	cfg, err = config.NewInkwellConfig(*path)
-	if err != nil {
-		panic(err)
+	name := args[0]
+	switch {
+	case name == "help" || name == "-h" || name == "--help" || name == "-help":
+		if len(args) > 1 {
+			return run([]string{args[1], "-h"})
+		}
+		usage(os.Stdout)
+		return 0
+	case strings.HasPrefix(name, "-"):
+		// inkwell --config=path predates the subcommands, and builds the book
+		name = "build"
+	default:
+		args = args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		err := cmd.run(args)
+		var uerr usageError
+		switch {
+		case err == nil || errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.As(err, &uerr):
+			return 2
+		default:
+			fmt.Fprintf(os.Stderr, "inkwell %s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "inkwell: unknown command %q\nRun 'inkwell help' for usage.\n", name)
+	return 2
+}
+
+// usage writes the list of commands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "inkwell compiles a manuscript from the files listed in .inkwell.yaml.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  inkwell <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'inkwell help <command>' for the flags of a command.")
+}
 
-	// Process the config
-	err = processor.ProcessBook(*cfg)
+// newFlagSet returns the flag set of a command, with help text built from its description.
+func newFlagSet(name string, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		w := flags.Output()
+		fmt.Fprintf(w, "Usage: inkwell %s [flags]\n\n%s\n\nFlags:\n", name, description)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the arguments of a command, which takes no positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
 	if err != nil {
-		panic(err)
+		return usageError{err}
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "unexpected argument %q\n", flags.Arg(0))
+		flags.Usage()
+		return usageError{fmt.Errorf("unexpected argument %q", flags.Arg(0))}
 	}
+	return nil
 }
@@ -0,0 +1,57 @@
+package manuscript
+
+import (
+	"path"
+	"strings"
+
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// LinkName returns the name by which a wiki link matches a title or a source file: the name in
+// lower case, without the directories or extension of a file.
+func LinkName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/"))))
+	for _, extension := range []string{".md", ".markdown"} {
+		name = strings.TrimSuffix(name, extension)
+	}
+	return name
+}
+
+// Link returns the position in Contents() of the section, chapter or part which the wiki link
+// links to, and whether it links to one in the book. The current position is that of the
+// section, chapter or part which holds the link, which a link to one of its own headings
+// links to.
+func (b *Book) Link(n *markdown.Node, current int) (int, bool) {
+	note, _, _ := strings.Cut(n.Destination, "#")
+	if strings.TrimSpace(note) == "" {
+		return current, current >= 0
+	}
+
+	// a title may contain a slash, so the whole target is tried before its file name
+	if idx, ok := b.Links[strings.ToLower(strings.TrimSpace(note))]; ok {
+		return idx, true
+	}
+	idx, ok := b.Links[LinkName(note)]
+	return idx, ok
+}
+
+// ResolveLinks returns a copy of the document in which each wiki link to a section, chapter or
+// part of the book is replaced with a link to the href of its position in Contents(). Wiki
+// links outside the book are left as they are.
+func (b *Book) ResolveLinks(doc *markdown.Node, current int, href func(idx int) string) *markdown.Node {
+	resolved := doc.Clone()
+	markdown.Walk(resolved, func(n *markdown.Node) bool {
+		if n.Kind != markdown.WikiLink {
+			return true
+		}
+		if idx, ok := b.Link(n, current); ok {
+			*n = markdown.Node{
+				Kind:        markdown.Link,
+				Destination: href(idx),
+				Children:    []*markdown.Node{{Kind: markdown.Text, Literal: n.Literal}},
+			}
+		}
+		return false
+	})
+	return resolved
+}
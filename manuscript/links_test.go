@@ -0,0 +1,55 @@
+package manuscript
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func TestLinkName(t *testing.T) {
+	tests := map[string]string{
+		"Harbor":                 "harbor",
+		"notes/The Harbor.md":    "the harbor",
+		`drafts\chapter 1\01.md`: "01",
+		"  Mara  ":               "mara",
+	}
+	for name, expected := range tests {
+		if result := LinkName(name); result != expected {
+			t.Errorf("LinkName(%q) = %q, want %q", name, result, expected)
+		}
+	}
+}
+
+func TestResolveLinks(t *testing.T) {
+	book := &Book{Links: map[string]int{"the storm": 1, "either/or": 2, "landfall": 1}}
+	doc := markdown.Parse("See [[The Storm]], [[drafts/Landfall#Dawn|the landing]], [[Either/Or]], [[#Below]] and [[Nowhere]].")
+
+	href := func(idx int) string { return "page-" + strconv.Itoa(idx) }
+	resolved := book.ResolveLinks(doc, 4, href)
+
+	var links []string
+	markdown.Walk(resolved, func(n *markdown.Node) bool {
+		switch n.Kind {
+		case markdown.Link:
+			links = append(links, n.Destination+" "+markdown.PlainText(n))
+		case markdown.WikiLink:
+			links = append(links, "[["+n.Destination+"]]")
+		}
+		return true
+	})
+
+	expected := []string{"page-1 The Storm", "page-1 the landing", "page-2 Either/Or", "page-4 Below", "[[Nowhere]]"}
+	if len(links) != len(expected) {
+		t.Fatalf("ResolveLinks() links = %v, want %v", links, expected)
+	}
+	for idx := range expected {
+		if links[idx] != expected[idx] {
+			t.Errorf("link %d = %q, want %q", idx, links[idx], expected[idx])
+		}
+	}
+
+	if markdown.RenderMarkdown(doc) != "See [[The Storm]], [[drafts/Landfall#Dawn|the landing]], [[Either/Or]], [[#Below]] and [[Nowhere]].\n" {
+		t.Error("ResolveLinks() should not change the document")
+	}
+}
@@ -0,0 +1,206 @@
+package manuscript
+
+import "github.com/nivthefox/inkwell/markdown"
+
+// Book is a struct that represents a compiled book, independent of any output format
+type Book struct {
+	Title          string
+	Summary        string
+	Authors        []string
+	Language       string
+	SceneSeparator string
+	Dedication     *markdown.Node
+	TOC            TOCOptions
+	Sections       []*Section
+	Chapters       []*Chapter
+	Parts          []*Part
+
+	// Links maps the names which wiki links may give, in lower case, to the position in
+	// Contents() of the section, chapter or part they link to
+	Links map[string]int
+}
+
+// Metadata is a type that represents the values in the YAML front matter of the source files
+type Metadata map[string]any
+
+// Placement is a type that represents where a section is placed among the chapters
+type Placement int
+
+const (
+	// Front places the section before the first chapter
+	Front Placement = iota
+	// Back places the section after the last chapter
+	Back
+	// BeforeChapter places the section before the chapter with the number in Section.Chapter
+	BeforeChapter
+)
+
+// Section is a struct that represents a compiled section, such as a prologue or an appendix.
+// The files are the source files which were not cut, each followed by the notes embedded in it.
+type Section struct {
+	Title          string
+	Placement      Placement
+	Chapter        int
+	ExcludeFromTOC bool
+	Files          []string
+	Metadata       Metadata
+	Document       *markdown.Node
+}
+
+// Part is a struct that represents a compiled part, which holds its own sections, chapters
+// and further parts
+type Part struct {
+	Title    string
+	Sections []*Section
+	Chapters []*Chapter
+	Parts    []*Part
+}
+
+// Entry is a struct that represents a section, chapter or part in reading order. Exactly one
+// of the section, chapter and part is set; the depth counts the parts which hold the entry.
+type Entry struct {
+	Section *Section
+	Chapter *Chapter
+	Part    *Part
+	Depth   int
+}
+
+// Chapter is a struct that represents a compiled chapter. The number is empty when the
+// chapter is unnumbered. The scene break is drawn between its scenes; when it is nil, the
+// separator of the book is used.
+type Chapter struct {
+	Title          string
+	Number         string
+	ExcludeFromTOC bool
+	SceneBreak     *markdown.Node
+	Scenes         []*Scene
+}
+
+// Scene is a struct that represents a compiled scene. The number is its position in the
+// chapter unless the front matter of one of its files gives another, and the heading is the
+// text of the scene heading which opens its document, if it has one. The files are the source
+// files which were not cut, each followed by the notes embedded in it.
+type Scene struct {
+	Title    string
+	Number   string
+	Heading  string
+	Files    []string
+	Metadata Metadata
+	Document *markdown.Node
+}
+
+// AddSection appends a section to the book
+func (b *Book) AddSection(s *Section) {
+	b.Sections = append(b.Sections, s)
+}
+
+// AddChapter appends a chapter to the book
+func (b *Book) AddChapter(c *Chapter) {
+	b.Chapters = append(b.Chapters, c)
+}
+
+// AddPart appends a part to the book
+func (b *Book) AddPart(p *Part) {
+	b.Parts = append(b.Parts, p)
+}
+
+// Clone returns a copy of the book in which every section, chapter, part and scene, and the
+// document of each, is copied, so that the copy can be changed without changing the book
+func (b *Book) Clone() *Book {
+	clone := *b
+	clone.Dedication = cloneDocument(b.Dedication)
+	clone.Sections, clone.Chapters, clone.Parts = cloneContents(b.Sections, b.Chapters, b.Parts)
+	return &clone
+}
+
+// cloneContents returns copies of the sections, chapters and parts.
+func cloneContents(sections []*Section, chapters []*Chapter, parts []*Part) ([]*Section, []*Chapter, []*Part) {
+	var clonedSections []*Section
+	for _, section := range sections {
+		clone := *section
+		clone.Document = cloneDocument(section.Document)
+		clonedSections = append(clonedSections, &clone)
+	}
+
+	var clonedChapters []*Chapter
+	for _, chapter := range chapters {
+		clone := *chapter
+		clone.SceneBreak = cloneDocument(chapter.SceneBreak)
+		clone.Scenes = nil
+		for _, scene := range chapter.Scenes {
+			sceneClone := *scene
+			sceneClone.Document = cloneDocument(scene.Document)
+			clone.Scenes = append(clone.Scenes, &sceneClone)
+		}
+		clonedChapters = append(clonedChapters, &clone)
+	}
+
+	var clonedParts []*Part
+	for _, part := range parts {
+		clone := *part
+		clone.Sections, clone.Chapters, clone.Parts = cloneContents(part.Sections, part.Chapters, part.Parts)
+		clonedParts = append(clonedParts, &clone)
+	}
+
+	return clonedSections, clonedChapters, clonedParts
+}
+
+// cloneDocument returns a copy of the document, or nil if there is none.
+func cloneDocument(doc *markdown.Node) *markdown.Node {
+	if doc == nil {
+		return nil
+	}
+	return doc.Clone()
+}
+
+// SceneBreak returns the scene break drawn between the scenes of the chapter, or the scene
+// break of the book when the chapter is nil
+func (b *Book) SceneBreak(c *Chapter) *markdown.Node {
+	if c != nil && c.SceneBreak != nil {
+		return c.SceneBreak
+	}
+	return markdown.NewSceneBreak(b.SceneSeparator)
+}
+
+// Contents returns the sections, chapters and parts of the book in reading order, with the
+// contents of each part following the part itself. Sections placed before a chapter which
+// does not exist follow the last chapter, and parts follow the chapters, ahead of the back
+// matter.
+func (b *Book) Contents() []Entry {
+	return contents(b.Sections, b.Chapters, b.Parts, 0)
+}
+
+// contents returns the entries of the book or of a part at the given depth in reading order.
+func contents(sections []*Section, chapters []*Chapter, parts []*Part, depth int) []Entry {
+	var entries []Entry
+	placed := func(placement Placement, chapter int) {
+		for _, section := range sections {
+			if section.Placement == placement && (placement != BeforeChapter || section.Chapter == chapter) {
+				entries = append(entries, Entry{Section: section, Depth: depth})
+			}
+		}
+	}
+
+	placed(Front, 0)
+	for idx, chapter := range chapters {
+		placed(BeforeChapter, idx+1)
+		entries = append(entries, Entry{Chapter: chapter, Depth: depth})
+	}
+	for _, section := range sections {
+		if section.Placement == BeforeChapter && (section.Chapter < 1 || section.Chapter > len(chapters)) {
+			entries = append(entries, Entry{Section: section, Depth: depth})
+		}
+	}
+	for _, part := range parts {
+		entries = append(entries, Entry{Part: part, Depth: depth})
+		entries = append(entries, contents(part.Sections, part.Chapters, part.Parts, depth+1)...)
+	}
+	placed(Back, 0)
+
+	return entries
+}
+
+// AddScene appends a scene to the chapter
+func (c *Chapter) AddScene(s *Scene) {
+	c.Scenes = append(c.Scenes, s)
+}
@@ -0,0 +1,71 @@
+package manuscript
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContents(t *testing.T) {
+	book := &Book{
+		Sections: []*Section{
+			{Title: "Appendix", Placement: Back},
+			{Title: "Foreword"},
+			{Title: "Interlude", Placement: BeforeChapter, Chapter: 2},
+			{Title: "Coda", Placement: BeforeChapter, Chapter: 5},
+		},
+		Chapters: []*Chapter{{Title: "One"}, {Title: "Two"}},
+	}
+
+	var titles []string
+	for _, entry := range book.Contents() {
+		if entry.Section != nil {
+			titles = append(titles, entry.Section.Title)
+		} else {
+			titles = append(titles, entry.Chapter.Title)
+		}
+	}
+
+	expected := []string{"Foreword", "One", "Interlude", "Two", "Coda", "Appendix"}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Contents() = %v, want %v", titles, expected)
+	}
+}
+
+func TestContentsParts(t *testing.T) {
+	book := &Book{
+		Sections: []*Section{{Title: "Epilogue", Placement: Back}, {Title: "Prologue"}},
+		Parts: []*Part{
+			{
+				Title:    "Part One",
+				Sections: []*Section{{Title: "Interlude", Placement: Back}},
+				Chapters: []*Chapter{{Title: "One"}},
+			},
+			{
+				Title: "Part Two",
+				Parts: []*Part{{Title: "Book Three", Chapters: []*Chapter{{Title: "Two"}}}},
+			},
+		},
+	}
+
+	var titles []string
+	var depths []int
+	for _, entry := range book.Contents() {
+		switch {
+		case entry.Section != nil:
+			titles = append(titles, entry.Section.Title)
+		case entry.Chapter != nil:
+			titles = append(titles, entry.Chapter.Title)
+		default:
+			titles = append(titles, entry.Part.Title)
+		}
+		depths = append(depths, entry.Depth)
+	}
+
+	expected := []string{"Prologue", "Part One", "One", "Interlude", "Part Two", "Book Three", "Two", "Epilogue"}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Contents() = %v, want %v", titles, expected)
+	}
+	if !reflect.DeepEqual(depths, []int{0, 0, 1, 1, 0, 1, 2, 0}) {
+		t.Errorf("Contents() depths = %v", depths)
+	}
+}
@@ -0,0 +1,81 @@
+package manuscript
+
+import "strings"
+
+// TOCOptions is a struct that represents what the table of contents lists. A depth of zero
+// lists every level.
+type TOCOptions struct {
+	Title  string
+	Depth  int
+	Scenes bool
+}
+
+// TOCEntry is a struct that represents an entry in the table of contents. It points at a
+// section, chapter or part, or at a scene of the chapter when the scene is set. The entries
+// of the book itself are at level 1, and each part and chapter adds a level.
+type TOCEntry struct {
+	Title   string
+	Level   int
+	Section *Section
+	Chapter *Chapter
+	Part    *Part
+	Scene   *Scene
+}
+
+// TOCTitle returns the title of the table of contents
+func (b *Book) TOCTitle() string {
+	if b.TOC.Title == "" {
+		return "Contents"
+	}
+	return b.TOC.Title
+}
+
+// TableOfContents returns the entries of the table of contents in reading order. Sections and
+// chapters which are excluded are left out along with their scenes, as is every entry below
+// the depth of the table of contents.
+func (b *Book) TableOfContents() []TOCEntry {
+	var entries []TOCEntry
+	listed := func(level int) bool {
+		return b.TOC.Depth <= 0 || level <= b.TOC.Depth
+	}
+
+	for _, entry := range b.Contents() {
+		level := entry.Depth + 1
+		if !listed(level) {
+			continue
+		}
+
+		switch {
+		case entry.Section != nil:
+			if !entry.Section.ExcludeFromTOC {
+				entries = append(entries, TOCEntry{Title: entry.Section.Title, Level: level, Section: entry.Section})
+			}
+		case entry.Part != nil:
+			entries = append(entries, TOCEntry{Title: entry.Part.Title, Level: level, Part: entry.Part})
+		case !entry.Chapter.ExcludeFromTOC:
+			chapter := entry.Chapter
+			entries = append(entries, TOCEntry{Title: chapter.Title, Level: level, Chapter: chapter})
+			if !b.TOC.Scenes || !listed(level+1) {
+				continue
+			}
+			for _, scene := range chapter.Scenes {
+				entries = append(entries, TOCEntry{Title: scene.TOCTitle(), Level: level + 1, Chapter: chapter, Scene: scene})
+			}
+		}
+	}
+
+	return entries
+}
+
+// TOCTitle returns the title of the scene in the table of contents: its heading, its title
+// or its number.
+func (s *Scene) TOCTitle() string {
+	switch {
+	case s.Heading != "":
+		return s.Heading
+	case s.Title != "":
+		return s.Title
+	default:
+		return strings.TrimSpace("Scene " + s.Number)
+	}
+}
@@ -0,0 +1,41 @@
+package manuscript
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableOfContents(t *testing.T) {
+	book := &Book{
+		Sections: []*Section{{Title: "Prologue"}, {Title: "Notes", Placement: Back, ExcludeFromTOC: true}},
+		Chapters: []*Chapter{{Title: "One", Scenes: []*Scene{{Title: "Landfall"}, {Number: "2", Heading: "2. Nightfall"}, {Number: "3"}}}},
+		Parts: []*Part{
+			{Title: "Part Two", Chapters: []*Chapter{
+				{Title: "Two", Scenes: []*Scene{{Title: "Ashore"}}},
+				{Title: "Hidden", ExcludeFromTOC: true, Scenes: []*Scene{{Title: "Unlisted"}}},
+			}},
+		},
+	}
+
+	list := func() []string {
+		var result []string
+		for _, entry := range book.TableOfContents() {
+			result = append(result, string(rune('0'+entry.Level))+" "+entry.Title)
+		}
+		return result
+	}
+
+	if expected := []string{"1 Prologue", "1 One", "1 Part Two", "2 Two"}; !reflect.DeepEqual(list(), expected) {
+		t.Errorf("TableOfContents() = %v, want %v", list(), expected)
+	}
+
+	book.TOC = TOCOptions{Scenes: true, Depth: 2}
+	expected := []string{"1 Prologue", "1 One", "2 Landfall", "2 2. Nightfall", "2 Scene 3", "1 Part Two", "2 Two"}
+	if !reflect.DeepEqual(list(), expected) {
+		t.Errorf("TableOfContents() = %v, want %v", list(), expected)
+	}
+
+	if book.TOCTitle() != "Contents" {
+		t.Errorf("TOCTitle() = %q, want the default title", book.TOCTitle())
+	}
+}
@@ -0,0 +1,760 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// delimiter is a struct that represents a run of emphasis characters which may open or close emphasis
+type delimiter struct {
+	node     *Node
+	char     byte
+	count    int
+	original int
+	canOpen  bool
+	canClose bool
+}
+
+// bracket is a struct that represents an opening bracket which may start a link or an image
+type bracket struct {
+	node      *Node
+	image     bool
+	active    bool
+	position  int
+	delimiter int
+}
+
+// inlineParser is a struct that represents the state of the inline parser for a single block
+type inlineParser struct {
+	src      string
+	pos      int
+	refs     map[string]*Node
+	nodes    []*Node
+	delims   []*delimiter
+	brackets []*bracket
+
+	text      strings.Builder
+	textStart int
+}
+
+var (
+	entityReference = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	autolinkURI     = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^<>\x00-\x20]*)>`)
+	autolinkEmail   = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	htmlInline      = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^"'=<>` + "`" + `\x00-\x20]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!--(?s:.*?)-->|<\?(?s:.*?)\?>|<![A-Za-z][^>]*>|<!\[CDATA\[(?s:.*?)\]\]>)`)
+)
+
+// parseInlines parses the inline content of every paragraph and heading in the tree.
+func (p *parser) parseInlines(n *Node) {
+	for _, child := range n.Children {
+		switch child.Kind {
+		case Paragraph, Heading:
+			child.Children = parseInline(child.Literal, p.refs)
+			child.Literal = ""
+		default:
+			p.parseInlines(child)
+		}
+	}
+}
+
+// ParseInline parses the markdown source as inline content only, without block structure
+func ParseInline(source string) *Node {
+	return &Node{Kind: Document, Children: parseInline(strings.TrimSpace(source), nil)}
+}
+
+// parseInline parses the inline content of a block into a list of nodes.
+func parseInline(src string, refs map[string]*Node) []*Node {
+	p := &inlineParser{src: src, refs: refs}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '\n':
+			p.lineBreak()
+		case '\\':
+			p.backslash()
+		case '`':
+			p.codeSpan()
+		case '*', '_':
+			p.delimiterRun(c)
+		case '[':
+			if !p.wikiLink() {
+				p.openBracket(false, 1)
+			}
+		case '!':
+			if strings.HasPrefix(p.src[p.pos:], "![") && !strings.HasPrefix(p.src[p.pos:], "![[") {
+				p.openBracket(true, 2)
+			} else {
+				p.literal(1)
+			}
+		case ']':
+			p.closeBracket()
+		case '<':
+			p.angle()
+		case '&':
+			p.entity()
+		case '{':
+			if !p.criticMarkup() {
+				p.literal(1)
+			}
+		default:
+			p.literal(1)
+		}
+	}
+
+	p.flush()
+	nodes := processEmphasis(p.nodes, p.delims)
+	return mergeText(nodes)
+}
+
+// literal adds the next n bytes of the source to the pending text.
+func (p *inlineParser) literal(n int) {
+	if p.text.Len() == 0 && p.textStart < p.pos {
+		p.textStart = p.pos
+	}
+	p.text.WriteString(p.src[p.pos : p.pos+n])
+	p.pos += n
+}
+
+// flush adds the pending text as a text node.
+func (p *inlineParser) flush() {
+	if p.text.Len() > 0 {
+		p.nodes = append(p.nodes, &Node{Kind: Text, Literal: p.text.String(), Raw: p.src[p.textStart:p.pos]})
+		p.text.Reset()
+	}
+	p.textStart = p.pos
+}
+
+// add flushes the pending text, adds the node, and advances past its source.
+func (p *inlineParser) add(n *Node, length int) {
+	p.flush()
+	p.nodes = append(p.nodes, n)
+	p.pos += length
+	p.textStart = p.pos
+}
+
+// lineBreak handles a newline, which is a hard break when preceded by two or more spaces.
+func (p *inlineParser) lineBreak() {
+	pending := p.text.String()
+	trimmed := strings.TrimRight(pending, " ")
+	if pending != "" {
+		p.text.Reset()
+		if trimmed != "" {
+			raw := strings.TrimRight(p.src[p.textStart:p.pos], " ")
+			p.nodes = append(p.nodes, &Node{Kind: Text, Literal: trimmed, Raw: raw})
+		}
+	}
+
+	kind := SoftBreak
+	if len(pending)-len(trimmed) >= 2 {
+		kind = HardBreak
+	}
+	p.add(&Node{Kind: kind}, 1)
+
+	// leading spaces on the next line are not part of the content
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	p.textStart = p.pos
+}
+
+// backslash handles backslash escapes and backslash hard breaks.
+func (p *inlineParser) backslash() {
+	if p.pos+1 < len(p.src) {
+		next := p.src[p.pos+1]
+		if next == '\n' {
+			p.add(&Node{Kind: HardBreak, Marker: "\\"}, 2)
+			for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+				p.pos++
+			}
+			p.textStart = p.pos
+			return
+		}
+		if isASCIIPunctuation(next) {
+			if p.text.Len() == 0 {
+				p.textStart = p.pos
+			}
+			p.text.WriteByte(next)
+			p.pos += 2
+			return
+		}
+	}
+	p.literal(1)
+}
+
+// codeSpan handles a run of backticks, which opens a code span if a matching run follows.
+func (p *inlineParser) codeSpan() {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == '`' {
+		p.pos++
+	}
+	fence := p.src[start:p.pos]
+
+	search := p.pos
+	for search < len(p.src) {
+		idx := strings.IndexByte(p.src[search:], '`')
+		if idx < 0 {
+			break
+		}
+		runStart := search + idx
+		runEnd := runStart
+		for runEnd < len(p.src) && p.src[runEnd] == '`' {
+			runEnd++
+		}
+		if runEnd-runStart == len(fence) {
+			content := strings.ReplaceAll(p.src[p.pos:runStart], "\n", " ")
+			if len(content) >= 2 && content[0] == ' ' && content[len(content)-1] == ' ' && strings.Trim(content, " ") != "" {
+				content = content[1 : len(content)-1]
+			}
+			p.pos = start
+			p.add(&Node{Kind: Code, Literal: content, Marker: fence}, runEnd-start)
+			return
+		}
+		search = runEnd
+	}
+
+	// no matching run, so the backticks are literal
+	p.pos = start
+	p.literal(len(fence))
+}
+
+// delimiterRun handles a run of emphasis characters.
+func (p *inlineParser) delimiterRun(c byte) {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+	}
+	count := p.pos - start
+
+	// characters written as entities count as the characters they stand for, so
+	// that a separator like *&#9;*&#9;* is not read as emphasis
+	before := ' '
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:start])
+		if before == ';' {
+			if amp := strings.LastIndexByte(p.src[:start], '&'); amp >= 0 && entityReference.FindString(p.src[amp:]) == p.src[amp:start] {
+				before, _ = utf8.DecodeLastRuneInString(html.UnescapeString(p.src[amp:start]))
+			}
+		}
+	}
+	after := ' '
+	if p.pos < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[p.pos:])
+		if m := entityReference.FindString(p.src[p.pos:]); m != "" {
+			after, _ = utf8.DecodeRuneInString(html.UnescapeString(m))
+		}
+	}
+
+	leftFlanking := !unicode.IsSpace(after) &&
+		(!isPunctuation(after) || unicode.IsSpace(before) || isPunctuation(before))
+	rightFlanking := !unicode.IsSpace(before) &&
+		(!isPunctuation(before) || unicode.IsSpace(after) || isPunctuation(after))
+
+	canOpen, canClose := leftFlanking, rightFlanking
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || isPunctuation(before))
+		canClose = rightFlanking && (!leftFlanking || isPunctuation(after))
+	}
+
+	p.pos = start
+	text := p.src[start : start+count]
+	node := &Node{Kind: Text, Literal: text, Raw: text}
+	p.add(node, count)
+
+	if canOpen || canClose {
+		p.delims = append(p.delims, &delimiter{
+			node:     node,
+			char:     c,
+			count:    count,
+			original: count,
+			canOpen:  canOpen,
+			canClose: canClose,
+		})
+	}
+}
+
+// wikiLink handles a [[wiki link]], and reports whether one was found. The target of the link
+// may be followed by #heading, and by |alias, which replaces the text of the link.
+func (p *inlineParser) wikiLink() bool {
+	if !strings.HasPrefix(p.src[p.pos:], "[[") {
+		return false
+	}
+	end := strings.Index(p.src[p.pos+2:], "]]")
+	if end <= 0 {
+		return false
+	}
+	inner := p.src[p.pos+2 : p.pos+2+end]
+	if strings.Contains(inner, "]") {
+		return false
+	}
+
+	target, text := inner, inner
+	if before, alias, ok := strings.Cut(inner, "|"); ok {
+		target, text = before, alias
+	} else if note, heading, ok := strings.Cut(inner, "#"); ok {
+		// a link to a heading reads as the note and the heading, or as the heading alone
+		// within the same note
+		text = strings.TrimSpace(note) + " > " + strings.TrimSpace(heading)
+		if strings.TrimSpace(note) == "" {
+			text = strings.TrimSpace(heading)
+		}
+	}
+
+	p.add(&Node{Kind: WikiLink, Literal: strings.TrimSpace(text), Destination: strings.TrimSpace(target), Raw: p.src[p.pos : p.pos+end+4]}, end+4)
+	return true
+}
+
+// criticMarkup handles a CriticMarkup insertion {++text++}, deletion {--text--}, substitution
+// {~~old~>new~~} or comment {>>text<<}, and reports whether one was found. A substitution is
+// a deletion followed by an insertion, both marked with ~~.
+func (p *inlineParser) criticMarkup() bool {
+	rest := p.src[p.pos:]
+	if len(rest) < 3 {
+		return false
+	}
+	closer := map[string]string{"{++": "++}", "{--": "--}", "{~~": "~~}", "{>>": "<<}"}[rest[:3]]
+	if closer == "" {
+		return false
+	}
+	end := strings.Index(rest[3:], closer)
+	if end < 0 {
+		return false
+	}
+	inner := rest[3 : 3+end]
+	length := end + 6
+
+	var nodes []*Node
+	switch rest[:3] {
+	case "{++":
+		nodes = append(nodes, &Node{Kind: Insertion, Children: parseInline(inner, p.refs)})
+	case "{--":
+		nodes = append(nodes, &Node{Kind: Deletion, Children: parseInline(inner, p.refs)})
+	case "{~~":
+		old, replacement, ok := strings.Cut(inner, "~>")
+		if !ok {
+			return false
+		}
+		nodes = append(nodes, &Node{Kind: Deletion, Marker: "~~", Children: parseInline(old, p.refs)})
+		nodes = append(nodes, &Node{Kind: Insertion, Marker: "~~", Children: parseInline(replacement, p.refs)})
+	default:
+		nodes = append(nodes, &Node{Kind: Comment, Literal: strings.TrimSpace(inner)})
+	}
+
+	p.flush()
+	p.nodes = append(p.nodes, nodes...)
+	p.pos += length
+	p.textStart = p.pos
+	return true
+}
+
+// openBracket handles the opening bracket of a link or an image.
+func (p *inlineParser) openBracket(image bool, length int) {
+	text := p.src[p.pos : p.pos+length]
+	node := &Node{Kind: Text, Literal: text, Raw: text}
+	p.add(node, length)
+	p.brackets = append(p.brackets, &bracket{
+		node:      node,
+		image:     image,
+		active:    true,
+		position:  p.pos,
+		delimiter: len(p.delims),
+	})
+}
+
+// closeBracket handles a closing bracket, which completes a link or an image if it
+// matches an opening bracket and is followed by a destination or a known reference.
+func (p *inlineParser) closeBracket() {
+	p.flush()
+	if len(p.brackets) == 0 {
+		p.literal(1)
+		return
+	}
+
+	opener := p.brackets[len(p.brackets)-1]
+	p.brackets = p.brackets[:len(p.brackets)-1]
+	if !opener.active {
+		p.literal(1)
+		return
+	}
+
+	labelEnd := p.pos
+	after := p.pos + 1
+	dest, title, length, ok := p.linkTarget(after, p.src[opener.position:labelEnd])
+	if !ok {
+		p.literal(1)
+		return
+	}
+
+	// the nodes after the opening bracket become the content of the link
+	openerIndex := -1
+	for idx, n := range p.nodes {
+		if n == opener.node {
+			openerIndex = idx
+		}
+	}
+	children := processEmphasis(p.nodes[openerIndex+1:], p.delims[opener.delimiter:])
+	p.delims = p.delims[:opener.delimiter]
+
+	kind := Link
+	if opener.image {
+		kind = Image
+	}
+	link := &Node{Kind: kind, Destination: dest, Title: title, Children: mergeText(children)}
+	p.nodes = append(p.nodes[:openerIndex], link)
+	p.pos = after + length
+	p.textStart = p.pos
+
+	// links may not contain other links
+	if !opener.image {
+		for _, b := range p.brackets {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+}
+
+// linkTarget parses the destination of a link after its closing bracket, either inline or
+// as a reference, and returns the destination, title, and the number of bytes consumed.
+func (p *inlineParser) linkTarget(pos int, label string) (string, string, int, bool) {
+	rest := p.src[pos:]
+
+	// inline link: [text](destination "title")
+	if strings.HasPrefix(rest, "(") {
+		i := skipWhitespace(rest, 1)
+		dest, dn := "", 0
+		if i < len(rest) && rest[i] != ')' {
+			dest, dn = parseLinkDestination(rest[i:])
+		}
+		if dn > 0 || (i < len(rest) && rest[i] == ')') {
+			i += dn
+			j := skipWhitespace(rest, i)
+			title := ""
+			if j > i {
+				t, tn := parseLinkTitle(rest[j:])
+				if tn > 0 {
+					title = t
+					j = skipWhitespace(rest, j+tn)
+				}
+			}
+			if j < len(rest) && rest[j] == ')' {
+				return dest, title, j + 1, true
+			}
+		}
+	}
+
+	// full reference: [text][label]
+	if l, n := parseLinkLabel(rest); n > 0 {
+		if ref, ok := p.refs[normalizeLabel(l)]; ok {
+			return ref.Destination, ref.Title, n, true
+		}
+		return "", "", 0, false
+	}
+
+	// collapsed [label][] and shortcut [label] references
+	length := 0
+	if strings.HasPrefix(rest, "[]") {
+		length = 2
+	}
+	if ref, ok := p.refs[normalizeLabel(label)]; ok && strings.TrimSpace(label) != "" {
+		return ref.Destination, ref.Title, length, true
+	}
+	return "", "", 0, false
+}
+
+// angle handles an autolink or inline HTML.
+func (p *inlineParser) angle() {
+	rest := p.src[p.pos:]
+	if m := autolinkURI.FindStringSubmatch(rest); m != nil {
+		p.add(&Node{Kind: Link, Destination: m[1], Marker: "<", Children: []*Node{NewText(m[1])}}, len(m[0]))
+		return
+	}
+	if m := autolinkEmail.FindStringSubmatch(rest); m != nil {
+		p.add(&Node{Kind: Link, Destination: "mailto:" + m[1], Marker: "<", Children: []*Node{NewText(m[1])}}, len(m[0]))
+		return
+	}
+	if m := htmlInline.FindString(rest); m != "" {
+		p.add(&Node{Kind: HTMLInline, Literal: m}, len(m))
+		return
+	}
+	p.literal(1)
+}
+
+// entity handles an HTML entity or numeric character reference.
+func (p *inlineParser) entity() {
+	m := entityReference.FindString(p.src[p.pos:])
+	decoded := html.UnescapeString(m)
+	if m == "" || decoded == m {
+		p.literal(1)
+		return
+	}
+	if p.text.Len() == 0 {
+		p.textStart = p.pos
+	}
+	p.text.WriteString(decoded)
+	p.pos += len(m)
+}
+
+// processEmphasis matches the openers and closers in the delimiter list, wrapping the
+// nodes between them in emphasis, and returns the resulting list of nodes.
+func processEmphasis(nodes []*Node, delims []*delimiter) []*Node {
+	delims = append([]*delimiter{}, delims...)
+
+	indexOf := func(n *Node) int {
+		for idx, candidate := range nodes {
+			if candidate == n {
+				return idx
+			}
+		}
+		return -1
+	}
+
+	for ci := 0; ci < len(delims); {
+		closer := delims[ci]
+		if !closer.canClose {
+			ci++
+			continue
+		}
+
+		oi := -1
+		for idx := ci - 1; idx >= 0; idx-- {
+			opener := delims[idx]
+			if opener.char != closer.char || !opener.canOpen {
+				continue
+			}
+			// the rule of three: a delimiter which can both open and close cannot match
+			// another unless the combined length is not a multiple of three
+			if (opener.canClose || closer.canOpen) &&
+				(opener.original+closer.original)%3 == 0 &&
+				!(opener.original%3 == 0 && closer.original%3 == 0) {
+				continue
+			}
+			oi = idx
+			break
+		}
+
+		if oi < 0 {
+			if !closer.canOpen {
+				delims = append(delims[:ci], delims[ci+1:]...)
+			} else {
+				ci++
+			}
+			continue
+		}
+
+		opener := delims[oi]
+		use := 1
+		kind := Emphasis
+		if opener.count >= 2 && closer.count >= 2 {
+			use = 2
+			kind = Strong
+		}
+		opener.count -= use
+		closer.count -= use
+		opener.node.Literal = opener.node.Literal[:opener.count]
+		opener.node.Raw = opener.node.Raw[:opener.count]
+		closer.node.Literal = closer.node.Literal[:closer.count]
+		closer.node.Raw = closer.node.Raw[:closer.count]
+
+		start := indexOf(opener.node)
+		end := indexOf(closer.node)
+		emphasis := &Node{
+			Kind:     kind,
+			Marker:   strings.Repeat(string(opener.char), use),
+			Children: append([]*Node{}, nodes[start+1:end]...),
+		}
+		nodes = append(nodes[:start+1], append([]*Node{emphasis}, nodes[end:]...)...)
+
+		// delimiters between the opener and the closer can no longer match
+		delims = append(delims[:oi+1], delims[ci:]...)
+		ci = oi + 1
+
+		if opener.count == 0 {
+			nodes = removeNode(nodes, opener.node)
+			delims = append(delims[:oi], delims[oi+1:]...)
+			ci--
+		}
+		if closer.count == 0 {
+			nodes = removeNode(nodes, closer.node)
+			delims = append(delims[:ci], delims[ci+1:]...)
+		}
+	}
+
+	return nodes
+}
+
+// removeNode returns the list of nodes without the given node.
+func removeNode(nodes []*Node, n *Node) []*Node {
+	for idx, candidate := range nodes {
+		if candidate == n {
+			return append(nodes[:idx], nodes[idx+1:]...)
+		}
+	}
+	return nodes
+}
+
+// mergeText joins adjacent text nodes, and removes empty ones.
+func mergeText(nodes []*Node) []*Node {
+	var result []*Node
+	for _, n := range nodes {
+		if n.Kind == Text {
+			if n.Literal == "" && n.Raw == "" {
+				continue
+			}
+			if len(result) > 0 && result[len(result)-1].Kind == Text {
+				last := result[len(result)-1]
+				last.Literal += n.Literal
+				last.Raw += n.Raw
+				continue
+			}
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// parseLinkLabel parses a link label in brackets, and returns its content and the number
+// of bytes consumed, or zero if there is none.
+func parseLinkLabel(s string) (string, int) {
+	if !strings.HasPrefix(s, "[") {
+		return "", 0
+	}
+	for i := 1; i < len(s) && i <= 1000; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			return "", 0
+		case ']':
+			label := s[1:i]
+			if strings.TrimSpace(label) == "" {
+				return "", 0
+			}
+			return label, i + 1
+		}
+	}
+	return "", 0
+}
+
+// parseLinkDestination parses a link destination, and returns it and the number of bytes
+// consumed, or zero if there is none.
+func parseLinkDestination(s string) (string, int) {
+	if strings.HasPrefix(s, "<") {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '\n', '<':
+				return "", 0
+			case '>':
+				return unescapeString(s[1:i]), i + 1
+			}
+		}
+		return "", 0
+	}
+
+	depth := 0
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && isASCIIPunctuation(s[i+1]) {
+			i++
+			continue
+		}
+		if c <= ' ' {
+			break
+		}
+		if c == '(' {
+			depth++
+			if depth > 32 {
+				return "", 0
+			}
+		}
+		if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+	}
+	if i == 0 || depth != 0 {
+		return "", 0
+	}
+	return unescapeString(s[:i]), i
+}
+
+// parseLinkTitle parses a link title, and returns it and the number of bytes consumed,
+// or zero if there is none.
+func parseLinkTitle(s string) (string, int) {
+	if s == "" {
+		return "", 0
+	}
+	closing := s[0]
+	switch closing {
+	case '"', '\'':
+	case '(':
+		closing = ')'
+	default:
+		return "", 0
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case closing:
+			return unescapeString(s[1:i]), i + 1
+		case '(':
+			if closing == ')' {
+				return "", 0
+			}
+		}
+	}
+	return "", 0
+}
+
+// unescapeString resolves backslash escapes and entities in the string.
+func unescapeString(s string) string {
+	if !strings.ContainsAny(s, "\\&") {
+		return s
+	}
+
+	builder := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunctuation(s[i+1]) {
+			builder.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if s[i] == '&' {
+			if m := entityReference.FindString(s[i:]); m != "" {
+				builder.WriteString(html.UnescapeString(m))
+				i += len(m) - 1
+				continue
+			}
+		}
+		builder.WriteByte(s[i])
+	}
+	return builder.String()
+}
+
+// skipWhitespace returns the position of the next non-whitespace character.
+func skipWhitespace(s string, pos int) int {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t' || s[pos] == '\n') {
+		pos++
+	}
+	return pos
+}
+
+// isASCIIPunctuation reports whether the byte is an ASCII punctuation character.
+func isASCIIPunctuation(c byte) bool {
+	return c >= '!' && c <= '/' || c >= ':' && c <= '@' || c >= '[' && c <= '`' || c >= '{' && c <= '~'
+}
+
+// isPunctuation reports whether the rune is a Unicode punctuation or symbol character.
+func isPunctuation(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
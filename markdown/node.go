@@ -0,0 +1,104 @@
+package markdown
+
+// Kind is a type that represents the kind of a node in a document tree
+type Kind int
+
+const (
+	Document Kind = iota
+	FrontMatter
+	Paragraph
+	Heading
+	BlockQuote
+	List
+	ListItem
+	CodeBlock
+	HTMLBlock
+	ThematicBreak
+	SceneBreak
+	Text
+	SoftBreak
+	HardBreak
+	Emphasis
+	Strong
+	Code
+	Link
+	Image
+	WikiLink
+	HTMLInline
+	Insertion
+	Deletion
+	Comment
+)
+
+// Node is a struct that represents a single block or inline element of a document
+type Node struct {
+	Kind     Kind
+	Children []*Node
+
+	// Literal is the decoded content of text, code, HTML and front matter nodes,
+	// the text of wiki links, whose target is their destination, and the text of
+	// comments
+	Literal string
+
+	// Raw is the source of a text node or wiki link, which is written back verbatim
+	// when the document is rendered as markdown; synthesized nodes leave it empty
+	Raw string
+
+	// Marker is the delimiter used in the source for emphasis, list items,
+	// thematic breaks, code fences and the halves of a CriticMarkup substitution
+	Marker string
+
+	Level       int
+	Ordered     bool
+	Start       int
+	Tight       bool
+	Info        string
+	Destination string
+	Title       string
+
+	// Line and EndLine are the first and last source lines of a block, starting at 1
+	Line    int
+	EndLine int
+}
+
+// IsBlock reports whether the node is a block-level node
+func (n *Node) IsBlock() bool {
+	return n.Kind < Text
+}
+
+// AppendChild adds the node as the last child of n
+func (n *Node) AppendChild(child *Node) {
+	n.Children = append(n.Children, child)
+}
+
+// Clone returns a copy of the node and all of its descendants
+func (n *Node) Clone() *Node {
+	clone := *n
+	clone.Children = make([]*Node, len(n.Children))
+	for idx, child := range n.Children {
+		clone.Children[idx] = child.Clone()
+	}
+	return &clone
+}
+
+// Walk visits the node and all of its descendants in document order. Returning
+// false from fn skips the children of the visited node.
+func Walk(n *Node, fn func(n *Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		Walk(child, fn)
+	}
+}
+
+// NewText returns a text node with the given content
+func NewText(text string) *Node {
+	return &Node{Kind: Text, Literal: text}
+}
+
+// NewSceneBreak returns a scene break node, which marks the break between two scenes. The
+// separator is parsed as inline markdown, and each renderer decides how to draw it.
+func NewSceneBreak(separator string) *Node {
+	return &Node{Kind: SceneBreak, Literal: separator, Children: ParseInline(separator).Children}
+}
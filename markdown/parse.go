@@ -0,0 +1,744 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// block is a struct that represents an open block while the document is being parsed
+type block struct {
+	node  *Node
+	lines []string
+
+	// fenced code blocks
+	fenceChar   byte
+	fenceLength int
+	fenceIndent int
+
+	// HTML blocks end at a line containing this string, or at a blank line when it is empty
+	htmlEnd string
+
+	// list items continue on lines indented by at least this many columns
+	contentOffset int
+}
+
+// parser is a struct that represents the state of the block parser
+type parser struct {
+	doc   *Node
+	stack []*block
+	refs  map[string]*Node
+
+	lineNumber  int
+	line        string
+	offset      int
+	lastMatched int
+	unmatched   bool
+
+	// added is the first block opened on the current line, which is used with
+	// blankPending to find lists that are loose
+	added            *block
+	addedParent      *block
+	addedGrandparent *block
+	lineBlank        bool
+	blankPending     bool
+}
+
+var (
+	atxHeading      = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	atxClosing      = regexp.MustCompile(`(?:^|[ \t]+)#+[ \t]*$`)
+	codeFence       = regexp.MustCompile("^(?:`{3,}|~{3,})")
+	thematicBreak   = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextUnderline = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	bulletMarker    = regexp.MustCompile(`^[-+*]`)
+	orderedMarker   = regexp.MustCompile(`^([0-9]{1,9})([.)])`)
+	htmlBlockOpen   = []struct {
+		start *regexp.Regexp
+		end   string
+	}{
+		{regexp.MustCompile(`(?i)^<(script|pre|style|textarea)(?:\s|>|$)`), ""},
+		{regexp.MustCompile(`^<!--`), "-->"},
+		{regexp.MustCompile(`^<[?]`), "?>"},
+		{regexp.MustCompile(`^<![A-Za-z]`), ">"},
+		{regexp.MustCompile(`^<!\[CDATA\[`), "]]>"},
+		{regexp.MustCompile(`(?i)^</?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[1-6]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:\s|/?>|$)`), ""},
+		{regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^"'=<>` + "`" + `\x00-\x20]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>)[ \t]*$`), ""},
+	}
+)
+
+// Parse parses the markdown source into a document tree
+func Parse(source string) *Node {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	lines := strings.Split(source, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	doc := &Node{Kind: Document, Line: 1, EndLine: len(lines)}
+	p := &parser{
+		doc:   doc,
+		stack: []*block{{node: doc}},
+		refs:  map[string]*Node{},
+	}
+
+	start := parseFrontMatter(doc, lines)
+	for idx := start; idx < len(lines); idx++ {
+		p.lineNumber = idx + 1
+		p.addLine(lines[idx])
+	}
+	for len(p.stack) > 1 {
+		p.close()
+	}
+
+	p.parseInlines(doc)
+	return doc
+}
+
+// parseFrontMatter adds a front matter node to the document if the source starts with a
+// YAML front matter block, and returns the index of the first line after it.
+func parseFrontMatter(doc *Node, lines []string) int {
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t") != "---" {
+		return 0
+	}
+
+	for idx := 1; idx < len(lines); idx++ {
+		trimmed := strings.TrimRight(lines[idx], " \t")
+		if trimmed == "---" || trimmed == "..." {
+			literal := ""
+			if idx > 1 {
+				literal = strings.Join(lines[1:idx], "\n") + "\n"
+			}
+			doc.AppendChild(&Node{Kind: FrontMatter, Literal: literal, Line: 1, EndLine: idx + 1})
+			return idx + 1
+		}
+	}
+
+	return 0
+}
+
+// consume moves the position on by the given number of columns of indentation. A tab within
+// them is replaced by the spaces it stands for, with any columns left over from a tab which is
+// only partly consumed kept as spaces, as CommonMark has it. Only the indentation which decides
+// the block structure is expanded: the tabs in the content of the line are kept, so that the
+// line before the position is always a byte to a column.
+func (p *parser) consume(columns int) {
+	column := p.offset
+	end := p.offset
+	for column < p.offset+columns && end < len(p.line) && (p.line[end] == ' ' || p.line[end] == '\t') {
+		if p.line[end] == '\t' {
+			column += 4 - column%4
+		} else {
+			column++
+		}
+		end++
+	}
+	p.line = p.line[:p.offset] + strings.Repeat(" ", column-p.offset) + p.line[end:]
+	p.offset = min(p.offset+columns, column)
+}
+
+// tip returns the innermost open block.
+func (p *parser) tip() *block {
+	return p.stack[len(p.stack)-1]
+}
+
+// nextNonSpace returns the position of the next character on the line which is not a space
+// or a tab.
+func (p *parser) nextNonSpace() int {
+	idx := p.offset
+	for idx < len(p.line) && (p.line[idx] == ' ' || p.line[idx] == '\t') {
+		idx++
+	}
+	return idx
+}
+
+// indent returns the number of columns of indentation from the current position, with tab
+// stops of four columns.
+func (p *parser) indent() int {
+	return columns(p.line[p.offset:p.nextNonSpace()], p.offset)
+}
+
+// columns returns the number of columns taken by the spaces and tabs starting at the column.
+func columns(whitespace string, column int) int {
+	start := column
+	for _, c := range whitespace {
+		if c == '\t' {
+			column += 4 - column%4
+		} else {
+			column++
+		}
+	}
+	return column - start
+}
+
+// blank reports whether the rest of the line is empty.
+func (p *parser) blank() bool {
+	return strings.TrimSpace(p.line[p.offset:]) == ""
+}
+
+// rest returns the rest of the line from the current position.
+func (p *parser) rest() string {
+	return p.line[p.offset:]
+}
+
+// addLine processes a single line of the source.
+func (p *parser) addLine(line string) {
+	p.line = line
+	p.offset = 0
+	p.added = nil
+	p.addedParent = nil
+	p.addedGrandparent = nil
+	p.lineBlank = false
+
+	// Find the open blocks which the line continues
+	p.lastMatched = 0
+	for p.lastMatched+1 < len(p.stack) {
+		b := p.stack[p.lastMatched+1]
+		switch p.continues(b) {
+		case continueMatched:
+			p.lastMatched++
+			continue
+		case continueDone:
+			p.lineBlank = false
+			p.finishLine()
+			return
+		}
+		break
+	}
+	p.unmatched = p.lastMatched < len(p.stack)-1
+	p.lineBlank = p.blank()
+
+	container := p.stack[p.lastMatched]
+
+	// Open any new blocks which start on the line
+	for container.node.Kind != CodeBlock && container.node.Kind != HTMLBlock {
+		started := p.startBlock(container)
+		if started == startNone {
+			break
+		}
+		if started == startDone {
+			p.finishLine()
+			return
+		}
+		container = p.tip()
+		if started == startLeaf {
+			break
+		}
+	}
+
+	// Add the rest of the line to the innermost block
+	if p.unmatched && !p.blank() && p.tip().node.Kind == Paragraph && container.node.Kind != CodeBlock {
+		// lazy paragraph continuation
+		p.tip().lines = append(p.tip().lines, strings.TrimLeft(p.rest(), " \t"))
+		p.tip().node.EndLine = p.lineNumber
+		p.finishLine()
+		return
+	}
+
+	p.closeUnmatched()
+	container = p.tip()
+
+	switch container.node.Kind {
+	case Paragraph:
+		if p.blank() {
+			p.close()
+		} else {
+			container.lines = append(container.lines, strings.TrimLeft(p.rest(), " \t"))
+			container.node.EndLine = p.lineNumber
+		}
+	case CodeBlock:
+		container.lines = append(container.lines, p.rest())
+		container.node.EndLine = p.lineNumber
+	case HTMLBlock:
+		container.lines = append(container.lines, p.rest())
+		container.node.EndLine = p.lineNumber
+		if container.htmlEnd != "" && strings.Contains(strings.ToLower(p.rest()), container.htmlEnd) {
+			p.close()
+		}
+	default:
+		if !p.blank() {
+			b := p.addChild(Paragraph)
+			b.lines = append(b.lines, strings.TrimLeft(p.rest(), " \t"))
+		}
+	}
+
+	p.finishLine()
+}
+
+// finishLine records the blank lines which decide whether lists are loose.
+func (p *parser) finishLine() {
+	// a list is loose when a blank line separates its items, or the blocks inside one of its items
+	if p.blankPending && p.added != nil && p.addedParent.node.Line < p.lineNumber {
+		switch p.addedParent.node.Kind {
+		case List:
+			p.addedParent.node.Tight = false
+		case ListItem:
+			if p.addedGrandparent != nil {
+				p.addedGrandparent.node.Tight = false
+			}
+		}
+	}
+
+	kind := p.tip().node.Kind
+	if p.lineBlank && kind != CodeBlock && kind != HTMLBlock {
+		p.blankPending = true
+	} else if !p.lineBlank {
+		p.blankPending = false
+	}
+
+	for _, b := range p.stack {
+		b.node.EndLine = max(b.node.EndLine, p.lineNumber)
+	}
+}
+
+const (
+	continueMatched = iota
+	continueFailed
+	continueDone
+)
+
+// continues checks whether the line continues the open block, and consumes its markers.
+func (p *parser) continues(b *block) int {
+	switch b.node.Kind {
+	case BlockQuote:
+		nns := p.nextNonSpace()
+		if p.indent() <= 3 && nns < len(p.line) && p.line[nns] == '>' {
+			p.offset = nns + 1
+			if p.offset < len(p.line) && (p.line[p.offset] == ' ' || p.line[p.offset] == '\t') {
+				p.consume(1)
+			}
+			return continueMatched
+		}
+		return continueFailed
+
+	case ListItem:
+		if p.blank() {
+			if len(b.node.Children) == 0 {
+				return continueFailed
+			}
+			p.offset = p.nextNonSpace()
+			return continueMatched
+		}
+		if p.indent() >= b.contentOffset {
+			p.consume(b.contentOffset)
+			return continueMatched
+		}
+		return continueFailed
+
+	case List:
+		return continueMatched
+
+	case CodeBlock:
+		if b.fenceLength > 0 {
+			nns := p.nextNonSpace()
+			if p.indent() <= 3 && nns < len(p.line) && p.line[nns] == b.fenceChar {
+				run := 0
+				for nns+run < len(p.line) && p.line[nns+run] == b.fenceChar {
+					run++
+				}
+				if run >= b.fenceLength && strings.TrimSpace(p.line[nns+run:]) == "" {
+					b.node.EndLine = p.lineNumber
+					p.closeBlock(b)
+					return continueDone
+				}
+			}
+			p.consume(min(p.indent(), b.fenceIndent))
+			return continueMatched
+		}
+		if p.indent() >= 4 {
+			p.consume(4)
+			return continueMatched
+		}
+		if p.blank() {
+			p.offset = p.nextNonSpace()
+			return continueMatched
+		}
+		return continueFailed
+
+	case HTMLBlock:
+		if p.blank() && b.htmlEnd == "" {
+			return continueFailed
+		}
+		return continueMatched
+
+	case Paragraph:
+		if p.blank() {
+			return continueFailed
+		}
+		return continueMatched
+	}
+
+	return continueFailed
+}
+
+const (
+	startNone = iota
+	startContainer
+	startLeaf
+	startDone
+)
+
+// startBlock opens a new block if one starts at the current position of the line.
+func (p *parser) startBlock(container *block) int {
+	nns := p.nextNonSpace()
+	indent := p.indent()
+
+	if indent >= 4 {
+		if p.blank() || p.tip().node.Kind == Paragraph {
+			return startNone
+		}
+		p.consume(4)
+		p.closeUnmatched()
+		b := p.addChild(CodeBlock)
+		b.node.Line = p.lineNumber
+		return startLeaf
+	}
+
+	rest := p.line[nns:]
+	if rest == "" {
+		return startNone
+	}
+
+	switch {
+	case rest[0] == '>':
+		p.offset = nns + 1
+		if p.offset < len(p.line) && (p.line[p.offset] == ' ' || p.line[p.offset] == '\t') {
+			p.consume(1)
+		}
+		p.closeUnmatched()
+		p.addChild(BlockQuote)
+		return startContainer
+
+	case atxHeading.MatchString(rest):
+		p.closeUnmatched()
+		level := strings.IndexFunc(rest, func(r rune) bool { return r != '#' })
+		if level < 0 {
+			level = len(rest)
+		}
+		content := strings.TrimSpace(rest[level:])
+		content = strings.TrimSpace(atxClosing.ReplaceAllString(content, ""))
+		b := p.addChild(Heading)
+		b.node.Level = level
+		b.node.Literal = content
+		p.close()
+		return startDone
+
+	case codeFence.MatchString(rest):
+		fence := codeFence.FindString(rest)
+		info := strings.TrimSpace(rest[len(fence):])
+		if fence[0] == '`' && strings.Contains(info, "`") {
+			return startNone
+		}
+		p.closeUnmatched()
+		b := p.addChild(CodeBlock)
+		b.fenceChar = fence[0]
+		b.fenceLength = len(fence)
+		b.fenceIndent = indent
+		b.node.Marker = fence
+		b.node.Info = unescapeString(info)
+		return startDone
+
+	case rest[0] == '<':
+		for idx, kind := range htmlBlockOpen {
+			if !kind.start.MatchString(rest) {
+				continue
+			}
+			if idx == len(htmlBlockOpen)-1 && (container.node.Kind == Paragraph || p.unmatched && p.tip().node.Kind == Paragraph) {
+				break
+			}
+			p.closeUnmatched()
+			b := p.addChild(HTMLBlock)
+			b.htmlEnd = kind.end
+			if m := kind.start.FindStringSubmatch(rest); len(m) > 1 {
+				b.htmlEnd = "</" + strings.ToLower(m[1]) + ">"
+			}
+			return startLeaf
+		}
+
+	case container.node.Kind == Paragraph && setextUnderline.MatchString(rest):
+		p.closeUnmatched()
+		heading := container
+		level := 2
+		if rest[0] == '=' {
+			level = 1
+		}
+		content := p.extractReferences(strings.Join(heading.lines, "\n"))
+		if content != "" {
+			heading.node.Kind = Heading
+			heading.node.Level = level
+			heading.node.Marker = rest[:1]
+			heading.node.Literal = strings.TrimSpace(content)
+			heading.node.EndLine = p.lineNumber
+			heading.lines = nil
+			p.pop()
+			return startDone
+		}
+		heading.lines = nil
+		p.close()
+		p.offset = len(p.line)
+		return startDone
+	}
+
+	if thematicBreak.MatchString(rest) {
+		p.closeUnmatched()
+		b := p.addChild(ThematicBreak)
+		b.node.Marker = rest[:1]
+		p.close()
+		return startDone
+	}
+
+	return p.startListItem(container, nns)
+}
+
+// startListItem opens a new list item, and a new list if needed, if one starts on the line.
+func (p *parser) startListItem(container *block, nns int) int {
+	rest := p.line[nns:]
+	interrupting := container.node.Kind == Paragraph
+
+	var marker string
+	var markerLength int
+	ordered := false
+	start := 0
+	if bulletMarker.MatchString(rest) {
+		marker = rest[:1]
+		markerLength = 1
+	} else if m := orderedMarker.FindStringSubmatch(rest); m != nil {
+		ordered = true
+		marker = m[2]
+		markerLength = len(m[0])
+		for _, d := range m[1] {
+			start = start*10 + int(d-'0')
+		}
+		if interrupting && start != 1 {
+			return startNone
+		}
+	} else {
+		return startNone
+	}
+
+	after := rest[markerLength:]
+	if after != "" && after[0] != ' ' && after[0] != '\t' {
+		return startNone
+	}
+	empty := strings.TrimSpace(after) == ""
+	if interrupting && empty {
+		return startNone
+	}
+
+	// content which is indented five or more spaces after the marker is an indented code block
+	whitespace := after[:len(after)-len(strings.TrimLeft(after, " \t"))]
+	spaces := columns(whitespace, nns+markerLength)
+	padding := spaces
+	switch {
+	case empty:
+		padding = 1
+	case spaces >= 5:
+		padding = 1
+		spaces = 1
+	}
+
+	p.closeUnmatched()
+
+	tip := p.tip()
+	if tip.node.Kind != List || tip.node.Ordered != ordered || tip.node.Marker != marker {
+		list := p.addChild(List)
+		list.node.Ordered = ordered
+		list.node.Marker = marker
+		list.node.Start = start
+		list.node.Tight = true
+	}
+	item := p.addChild(ListItem)
+	item.node.Marker = marker
+	item.contentOffset = nns - p.offset + markerLength + padding
+
+	p.offset = nns + markerLength
+	p.consume(spaces)
+	return startContainer
+}
+
+// addChild closes blocks which cannot contain the new block, then opens it.
+func (p *parser) addChild(kind Kind) *block {
+	for !canContain(p.tip().node.Kind, kind) {
+		p.close()
+	}
+
+	parent := p.tip()
+	node := &Node{Kind: kind, Line: p.lineNumber, EndLine: p.lineNumber}
+	parent.node.AppendChild(node)
+
+	b := &block{node: node}
+	if p.added == nil {
+		p.added = b
+		p.addedParent = parent
+		if len(p.stack) > 1 {
+			p.addedGrandparent = p.stack[len(p.stack)-2]
+		}
+	}
+	p.stack = append(p.stack, b)
+	return b
+}
+
+// canContain reports whether a block of the given kind can contain a child of the other kind.
+func canContain(parent Kind, child Kind) bool {
+	switch parent {
+	case Document, BlockQuote, ListItem:
+		return child != ListItem
+	case List:
+		return child == ListItem
+	}
+	return false
+}
+
+// closeUnmatched closes the open blocks which were not continued by the current line.
+func (p *parser) closeUnmatched() {
+	if !p.unmatched {
+		return
+	}
+	for len(p.stack)-1 > p.lastMatched {
+		p.close()
+	}
+	p.unmatched = false
+}
+
+// closeBlock closes the given open block and everything inside it.
+func (p *parser) closeBlock(b *block) {
+	for len(p.stack) > 1 {
+		tip := p.tip()
+		p.close()
+		if tip == b {
+			break
+		}
+	}
+	if p.lastMatched > len(p.stack)-1 {
+		p.lastMatched = len(p.stack) - 1
+	}
+}
+
+// pop removes the innermost open block without finalizing it.
+func (p *parser) pop() {
+	p.stack = p.stack[:len(p.stack)-1]
+	if p.lastMatched > len(p.stack)-1 {
+		p.lastMatched = len(p.stack) - 1
+	}
+}
+
+// close finalizes the innermost open block.
+func (p *parser) close() {
+	b := p.tip()
+	p.pop()
+
+	switch b.node.Kind {
+	case Paragraph:
+		content := p.extractReferences(strings.Join(b.lines, "\n"))
+		content = strings.TrimRight(content, " ")
+		if content == "" {
+			parent := p.tip().node
+			parent.Children = parent.Children[:len(parent.Children)-1]
+			return
+		}
+		b.node.Literal = content
+
+	case CodeBlock:
+		lines := b.lines
+		if b.fenceLength == 0 {
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+		}
+		if len(lines) > 0 {
+			b.node.Literal = strings.Join(lines, "\n") + "\n"
+		}
+
+	case HTMLBlock:
+		b.node.Literal = strings.Join(b.lines, "\n")
+	}
+}
+
+// extractReferences removes link reference definitions from the start of the paragraph content.
+func (p *parser) extractReferences(content string) string {
+	for strings.HasPrefix(content, "[") {
+		label, dest, title, n := parseReference(content)
+		if n == 0 {
+			break
+		}
+		key := normalizeLabel(label)
+		if _, ok := p.refs[key]; !ok {
+			p.refs[key] = &Node{Kind: Link, Destination: dest, Title: title}
+		}
+		content = content[n:]
+	}
+	return content
+}
+
+// parseReference parses a link reference definition at the start of the content, and
+// returns its parts and the number of bytes consumed, or zero if there is none.
+func parseReference(content string) (string, string, string, int) {
+	label, n := parseLinkLabel(content)
+	if n == 0 || n >= len(content) || content[n] != ':' {
+		return "", "", "", 0
+	}
+	pos := skipSpaceAndNewline(content, n+1)
+
+	dest, dn := parseLinkDestination(content[pos:])
+	if dn == 0 {
+		return "", "", "", 0
+	}
+	pos += dn
+	beforeTitle := pos
+
+	// the title is optional, but must be separated from the destination by whitespace
+	titlePos := skipSpaceAndNewline(content, pos)
+	title, tn := "", 0
+	if titlePos > pos {
+		title, tn = parseLinkTitle(content[titlePos:])
+	}
+	if tn > 0 {
+		end := titlePos + tn
+		if eol := lineEnd(content, end); eol >= 0 {
+			return label, dest, title, eol
+		}
+	}
+
+	eol := lineEnd(content, beforeTitle)
+	if eol < 0 {
+		return "", "", "", 0
+	}
+	return label, dest, "", eol
+}
+
+// lineEnd returns the position after the end of the line starting at pos if the rest of
+// the line is blank, or -1 if it is not.
+func lineEnd(content string, pos int) int {
+	for pos < len(content) && (content[pos] == ' ' || content[pos] == '\t') {
+		pos++
+	}
+	if pos == len(content) {
+		return pos
+	}
+	if content[pos] == '\n' {
+		return pos + 1
+	}
+	return -1
+}
+
+// skipSpaceAndNewline skips spaces and at most one newline.
+func skipSpaceAndNewline(content string, pos int) int {
+	newline := false
+	for pos < len(content) {
+		switch content[pos] {
+		case ' ', '\t':
+		case '\n':
+			if newline {
+				return pos
+			}
+			newline = true
+		default:
+			return pos
+		}
+		pos++
+	}
+	return pos
+}
+
+// normalizeLabel returns the key used to match link labels with reference definitions.
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
@@ -0,0 +1,131 @@
+package markdown
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Kind
+	}{
+		{
+			name:     "paragraphs",
+			input:    "First paragraph.\nStill first.\n\nSecond paragraph.",
+			expected: []Kind{Paragraph, Paragraph},
+		},
+		{
+			name:     "front matter",
+			input:    "---\nTitle: Test\n---\nBody text.",
+			expected: []Kind{FrontMatter, Paragraph},
+		},
+		{
+			name:     "headings",
+			input:    "# ATX\n\nSetext\n------",
+			expected: []Kind{Heading, Heading},
+		},
+		{
+			name:     "indented code",
+			input:    "Text.\n\n    code  with   spaces",
+			expected: []Kind{Paragraph, CodeBlock},
+		},
+		{
+			name:     "fenced code",
+			input:    "```go\nfunc main() {}\n```",
+			expected: []Kind{CodeBlock},
+		},
+		{
+			name:     "block quote with lazy continuation",
+			input:    "> Quoted\ncontinued.",
+			expected: []Kind{BlockQuote},
+		},
+		{
+			name:     "list is not a thematic break",
+			input:    "- one\n- two\n\n* * *",
+			expected: []Kind{List, ThematicBreak},
+		},
+		{
+			name:     "html block",
+			input:    "<div>\nraw\n</div>",
+			expected: []Kind{HTMLBlock},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Parse(tt.input)
+			if len(doc.Children) != len(tt.expected) {
+				t.Fatalf("Parse(%q) has %d blocks, want %d", tt.input, len(doc.Children), len(tt.expected))
+			}
+			for idx, kind := range tt.expected {
+				if doc.Children[idx].Kind != kind {
+					t.Errorf("Parse(%q) block %d has kind %d, want %d", tt.input, idx, doc.Children[idx].Kind, kind)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	doc := Parse("# Title\n\nFirst line\nsecond line.\n\nLast.")
+	expected := [][2]int{{1, 1}, {3, 4}, {6, 6}}
+
+	if len(doc.Children) != len(expected) {
+		t.Fatalf("Parse() has %d blocks, want %d", len(doc.Children), len(expected))
+	}
+	for idx, lines := range expected {
+		block := doc.Children[idx]
+		if block.Line != lines[0] || block.EndLine != lines[1] {
+			t.Errorf("block %d spans lines %d-%d, want %d-%d", idx, block.Line, block.EndLine, lines[0], lines[1])
+		}
+	}
+}
+
+func TestParseListTightness(t *testing.T) {
+	tight := Parse("- one\n- two").Children[0]
+	if !tight.Tight {
+		t.Error("expected list without blank lines to be tight")
+	}
+
+	loose := Parse("- one\n\n- two").Children[0]
+	if loose.Tight {
+		t.Error("expected list with blank lines between items to be loose")
+	}
+}
+
+func TestParseHardBreak(t *testing.T) {
+	paragraph := Parse("Roses are red,  \nviolets are blue.").Children[0]
+
+	found := false
+	Walk(paragraph, func(n *Node) bool {
+		if n.Kind == HardBreak {
+			found = true
+		}
+		return true
+	})
+	if !found {
+		t.Error("expected two trailing spaces to produce a hard break")
+	}
+}
+
+func TestParseTabs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "indented code", input: "\tcode\there", expected: "<pre><code>code\there\n</code></pre>\n"},
+		{name: "fenced code", input: "```\nkey:\tvalue\n\tindented\n```", expected: "<pre><code>key:\tvalue\n\tindented\n</code></pre>\n"},
+		{name: "paragraph", input: "Name\tAge", expected: "<p>Name\tAge</p>\n"},
+		{name: "block quote", input: ">\tQuoted\ttext", expected: "<blockquote>\n<p>Quoted\ttext</p>\n</blockquote>\n"},
+		{name: "list item", input: "-\tone\n\n\tmore", expected: "<ul>\n<li>\n<p>one</p>\n<p>more</p>\n</li>\n</ul>\n"},
+		{name: "code in a list item", input: "- item\n\n\t\tcode", expected: "<ul>\n<li>\n<p>item</p>\n<pre><code>  code\n</code></pre>\n</li>\n</ul>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := RenderXHTML(Parse(tt.input)); result != tt.expected {
+				t.Errorf("RenderXHTML(Parse(%q)) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RenderMarkdown renders the node and its children as markdown
+func RenderMarkdown(n *Node) string {
+	if !n.IsBlock() {
+		return renderInlines([]*Node{n})
+	}
+	if n.Kind == Document {
+		// Documents returned by ParseInline hold inline content directly
+		if len(n.Children) > 0 && !n.Children[0].IsBlock() {
+			return renderInlines(n.Children)
+		}
+		out := renderBlocks(n.Children, false)
+		if out == "" {
+			return ""
+		}
+		return out + "\n"
+	}
+	return renderBlock(n) + "\n"
+}
+
+// renderBlocks renders a list of blocks, separated by blank lines unless they are tight.
+func renderBlocks(blocks []*Node, tight bool) string {
+	separator := "\n\n"
+	if tight {
+		separator = "\n"
+	}
+
+	var parts []string
+	for _, b := range blocks {
+		parts = append(parts, renderBlock(b))
+	}
+	return strings.Join(parts, separator)
+}
+
+// renderBlock renders a single block without a trailing newline.
+func renderBlock(n *Node) string {
+	switch n.Kind {
+	case Document:
+		return renderBlocks(n.Children, false)
+
+	case FrontMatter:
+		return "---\n" + n.Literal + "---"
+
+	case Paragraph:
+		return renderInlines(n.Children)
+
+	case Heading:
+		content := renderInlines(n.Children)
+		if content == "" {
+			return strings.Repeat("#", n.Level)
+		}
+		return strings.Repeat("#", n.Level) + " " + content
+
+	case BlockQuote:
+		lines := strings.Split(renderBlocks(n.Children, false), "\n")
+		for idx, line := range lines {
+			if line == "" {
+				lines[idx] = ">"
+			} else {
+				lines[idx] = "> " + line
+			}
+		}
+		return strings.Join(lines, "\n")
+
+	case List:
+		var items []string
+		for idx, item := range n.Children {
+			marker := n.Marker
+			if marker == "" {
+				marker = "-"
+			}
+			if n.Ordered {
+				marker = strconv.Itoa(n.Start+idx) + n.Marker
+			}
+			items = append(items, renderListItem(item, marker, n.Tight))
+		}
+		if n.Tight {
+			return strings.Join(items, "\n")
+		}
+		return strings.Join(items, "\n\n")
+
+	case CodeBlock:
+		if n.Marker != "" {
+			info := ""
+			if n.Info != "" {
+				info = n.Info
+			}
+			return n.Marker + info + "\n" + n.Literal + n.Marker
+		}
+		lines := strings.Split(strings.TrimSuffix(n.Literal, "\n"), "\n")
+		for idx, line := range lines {
+			if line != "" {
+				lines[idx] = "    " + line
+			}
+		}
+		return strings.Join(lines, "\n")
+
+	case HTMLBlock:
+		return n.Literal
+
+	case ThematicBreak:
+		marker := n.Marker
+		if marker == "" {
+			marker = "*"
+		}
+		return strings.Repeat(marker, 3)
+
+	case SceneBreak:
+		content := renderInlines(n.Children)
+		if content == "" {
+			return "\\* \\* \\*"
+		}
+		// escape a separator which would otherwise be read as a heading, list or rule
+		if blocks := Parse(content).Children; len(blocks) > 0 && blocks[0].Kind != Paragraph && isASCIIPunctuation(content[0]) {
+			return "\\" + content
+		}
+		return content
+	}
+
+	return ""
+}
+
+// renderListItem renders a list item, indenting its continuation lines past the marker.
+func renderListItem(item *Node, marker string, tight bool) string {
+	content := renderBlocks(item.Children, tight)
+	if content == "" {
+		return marker
+	}
+
+	indent := strings.Repeat(" ", len(marker)+1)
+	lines := strings.Split(content, "\n")
+	for idx, line := range lines {
+		switch {
+		case idx == 0:
+			lines[idx] = marker + " " + line
+		case line != "":
+			lines[idx] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderInlines renders a list of inline nodes.
+func renderInlines(nodes []*Node) string {
+	builder := &strings.Builder{}
+	for _, n := range nodes {
+		switch n.Kind {
+		case Text:
+			if n.Raw != "" {
+				builder.WriteString(n.Raw)
+			} else {
+				builder.WriteString(escapeText(n.Literal, builder.Len() == 0))
+			}
+		case SoftBreak:
+			builder.WriteString("\n")
+		case HardBreak:
+			if n.Marker == "\\" {
+				builder.WriteString("\\\n")
+			} else {
+				builder.WriteString("  \n")
+			}
+		case Emphasis, Strong:
+			marker := n.Marker
+			if marker == "" {
+				marker = "*"
+				if n.Kind == Strong {
+					marker = "**"
+				}
+			}
+			builder.WriteString(marker + renderInlines(n.Children) + marker)
+		case Code:
+			builder.WriteString(renderCode(n))
+		case Link, Image:
+			if n.Kind == Link && n.Marker == "<" {
+				builder.WriteString("<" + PlainText(n) + ">")
+				continue
+			}
+			if n.Kind == Image {
+				builder.WriteString("!")
+			}
+			builder.WriteString("[" + renderInlines(n.Children) + "](" + renderDestination(n.Destination))
+			if n.Title != "" {
+				builder.WriteString(` "` + strings.ReplaceAll(n.Title, `"`, `\"`) + `"`)
+			}
+			builder.WriteString(")")
+		case WikiLink:
+			switch {
+			case n.Raw != "":
+				builder.WriteString(n.Raw)
+			case n.Destination == "" || n.Destination == n.Literal:
+				builder.WriteString("[[" + n.Literal + "]]")
+			default:
+				builder.WriteString("[[" + n.Destination + "|" + n.Literal + "]]")
+			}
+		case HTMLInline:
+			builder.WriteString(n.Literal)
+		case Insertion:
+			if n.Marker == "~~" {
+				builder.WriteString(renderInlines(n.Children) + "~~}")
+			} else {
+				builder.WriteString("{++" + renderInlines(n.Children) + "++}")
+			}
+		case Deletion:
+			if n.Marker == "~~" {
+				builder.WriteString("{~~" + renderInlines(n.Children) + "~>")
+			} else {
+				builder.WriteString("{--" + renderInlines(n.Children) + "--}")
+			}
+		case Comment:
+			builder.WriteString("{>>" + n.Literal + "<<}")
+		default:
+			builder.WriteString(renderInlines(n.Children))
+		}
+	}
+	return builder.String()
+}
+
+// renderCode renders a code span, using a fence of backticks which does not occur in its content.
+func renderCode(n *Node) string {
+	fence := n.Marker
+	if fence == "" || strings.Contains(n.Literal, fence) {
+		fence = "`"
+		for strings.Contains(n.Literal, fence) {
+			fence += "`"
+		}
+	}
+
+	content := n.Literal
+	if strings.HasPrefix(content, "`") || strings.HasSuffix(content, "`") ||
+		(strings.HasPrefix(content, " ") && strings.HasSuffix(content, " ") && strings.Trim(content, " ") != "") {
+		content = " " + content + " "
+	}
+	return fence + content + fence
+}
+
+// renderDestination renders a link destination, in angle brackets if it contains spaces or parentheses.
+func renderDestination(dest string) string {
+	if strings.ContainsAny(dest, " ()<>") {
+		return "<" + strings.NewReplacer("<", `\<`, ">", `\>`).Replace(dest) + ">"
+	}
+	return dest
+}
+
+// escapeText escapes the characters in synthesized text which would otherwise be read as markup.
+func escapeText(text string, start bool) string {
+	builder := &strings.Builder{}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch c {
+		case '\\', '`', '*', '_', '[', ']', '<':
+			builder.WriteByte('\\')
+		case '&':
+			if entityReference.MatchString(text[i:]) {
+				builder.WriteByte('\\')
+			}
+		case '#', '>', '-', '+':
+			if start && i == 0 {
+				builder.WriteByte('\\')
+			}
+		}
+		builder.WriteByte(c)
+	}
+	return builder.String()
+}
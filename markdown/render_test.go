@@ -0,0 +1,113 @@
+package markdown
+
+import "testing"
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "paragraphs",
+			input:    "First paragraph.\n\nSecond paragraph.",
+			expected: "First paragraph.\n\nSecond paragraph.\n",
+		},
+		{
+			name:     "hard breaks are preserved",
+			input:    "Roses are red,  \nviolets are blue.",
+			expected: "Roses are red,  \nviolets are blue.\n",
+		},
+		{
+			name:     "indented code is preserved",
+			input:    "    code  with   spaces",
+			expected: "    code  with   spaces\n",
+		},
+		{
+			name:     "fenced code is preserved",
+			input:    "~~~\nline  one\n~~~",
+			expected: "~~~\nline  one\n~~~\n",
+		},
+		{
+			name:     "escapes are preserved",
+			input:    "\\* \\* \\*",
+			expected: "\\* \\* \\*\n",
+		},
+		{
+			name:     "wiki links",
+			input:    "See [[Somewhere]].",
+			expected: "See [[Somewhere]].\n",
+		},
+		{
+			name:     "wiki links with aliases",
+			input:    "See [[Somewhere#Else|there]].",
+			expected: "See [[Somewhere#Else|there]].\n",
+		},
+		{
+			name:     "criticmarkup",
+			input:    "It was {++very ++}{--not --}{~~cold~>*warm*~~} out.{>>Check the weather<<}",
+			expected: "It was {++very ++}{--not --}{~~cold~>*warm*~~} out.{>>Check the weather<<}\n",
+		},
+		{
+			name:     "tight list",
+			input:    "- one\n- two",
+			expected: "- one\n- two\n",
+		},
+		{
+			name:     "block quote",
+			input:    "> Quoted\n>\n> Again.",
+			expected: "> Quoted\n>\n> Again.\n",
+		},
+		{
+			name:     "front matter",
+			input:    "---\nTitle: Test\n---\nBody.",
+			expected: "---\nTitle: Test\n---\n\nBody.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderMarkdown(Parse(tt.input))
+			if result != tt.expected {
+				t.Errorf("RenderMarkdown(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownSynthesizedText(t *testing.T) {
+	doc := &Node{Kind: Document}
+	paragraph := &Node{Kind: Paragraph}
+	paragraph.AppendChild(NewText("# not *a* heading"))
+	doc.AppendChild(paragraph)
+
+	expected := "\\# not \\*a\\* heading\n"
+	if result := RenderMarkdown(doc); result != expected {
+		t.Errorf("RenderMarkdown() = %q, want %q", result, expected)
+	}
+}
+
+func TestRenderSceneBreak(t *testing.T) {
+	tests := []struct {
+		separator string
+		markdown  string
+		xhtml     string
+	}{
+		{separator: "\\* \\* \\*", markdown: "\\* \\* \\*\n", xhtml: `<p class="scene-break">* * *</p>` + "\n"},
+		{separator: "#", markdown: "\\#\n", xhtml: `<p class="scene-break">#</p>` + "\n"},
+		{separator: "*&#9;*&#9;*", markdown: "*&#9;*&#9;*\n", xhtml: "<p class=\"scene-break\">*\t*\t*</p>\n"},
+		{separator: "~ *fin* ~", markdown: "~ *fin* ~\n", xhtml: `<p class="scene-break">~ <em>fin</em> ~</p>` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.separator, func(t *testing.T) {
+			node := NewSceneBreak(tt.separator)
+			if result := RenderMarkdown(node); result != tt.markdown {
+				t.Errorf("RenderMarkdown() = %q, want %q", result, tt.markdown)
+			}
+			if result := RenderXHTML(node); result != tt.xhtml {
+				t.Errorf("RenderXHTML() = %q, want %q", result, tt.xhtml)
+			}
+		})
+	}
+}
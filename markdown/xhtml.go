@@ -0,0 +1,299 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// startTag matches an HTML start tag, with its name, its attributes and the slash of a
+	// self-closing tag
+	startTag = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9-]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*)\s*(/?)>`)
+	// endTag matches an HTML end tag, with its name
+	endTag = regexp.MustCompile(`^</([A-Za-z][A-Za-z0-9-]*)\s*>`)
+	// attribute matches an attribute of a start tag, with its name and its value in one of
+	// the ways it may be quoted
+	attribute = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	// xmlName matches a name which XML allows for an attribute
+	xmlName = regexp.MustCompile(`^[A-Za-z_:][-A-Za-z0-9_:.]*$`)
+	// entity matches a character reference
+	entity = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+)
+
+// voidElements are the HTML elements which have no content and no end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// RenderXHTML renders the node and its children as XHTML. Raw HTML in the source is made
+// well-formed, since a single stray tag would make the whole document unreadable as XML.
+func RenderXHTML(n *Node) string {
+	builder := &strings.Builder{}
+	renderXHTML(n, builder, false)
+
+	raw := false
+	Walk(n, func(n *Node) bool {
+		raw = raw || n.Kind == HTMLBlock || n.Kind == HTMLInline
+		return !raw
+	})
+	if raw {
+		return wellFormed(builder.String())
+	}
+	return builder.String()
+}
+
+// renderXHTML writes the XHTML for the node to the builder. Paragraphs in the items of
+// tight lists are written without their enclosing tags.
+func renderXHTML(n *Node, builder *strings.Builder, tight bool) {
+	children := func() {
+		for _, c := range n.Children {
+			renderXHTML(c, builder, false)
+		}
+	}
+
+	switch n.Kind {
+	case Document:
+		children()
+	case FrontMatter:
+		// front matter is metadata, not content
+	case Paragraph:
+		builder.WriteString("<p>")
+		children()
+		builder.WriteString("</p>\n")
+	case Heading:
+		tag := "h" + strconv.Itoa(n.Level)
+		builder.WriteString("<" + tag + ">")
+		children()
+		builder.WriteString("</" + tag + ">\n")
+	case BlockQuote:
+		builder.WriteString("<blockquote>\n")
+		children()
+		builder.WriteString("</blockquote>\n")
+	case List:
+		tag := "ul"
+		attributes := ""
+		if n.Ordered {
+			tag = "ol"
+			if n.Start != 1 {
+				attributes = ` start="` + strconv.Itoa(n.Start) + `"`
+			}
+		}
+		builder.WriteString("<" + tag + attributes + ">\n")
+		for _, c := range n.Children {
+			renderXHTML(c, builder, n.Tight)
+		}
+		builder.WriteString("</" + tag + ">\n")
+	case ListItem:
+		builder.WriteString("<li>")
+		for idx, c := range n.Children {
+			if tight && c.Kind == Paragraph {
+				for _, inline := range c.Children {
+					renderXHTML(inline, builder, false)
+				}
+				if idx < len(n.Children)-1 {
+					builder.WriteString("\n")
+				}
+				continue
+			}
+			if idx == 0 {
+				builder.WriteString("\n")
+			}
+			renderXHTML(c, builder, false)
+		}
+		builder.WriteString("</li>\n")
+	case CodeBlock:
+		attributes := ""
+		if language := strings.Fields(n.Info); len(language) > 0 {
+			attributes = ` class="language-` + html.EscapeString(language[0]) + `"`
+		}
+		builder.WriteString("<pre><code" + attributes + ">" + html.EscapeString(n.Literal) + "</code></pre>\n")
+	case HTMLBlock:
+		builder.WriteString(n.Literal + "\n")
+	case ThematicBreak:
+		builder.WriteString("<hr/>\n")
+	case SceneBreak:
+		builder.WriteString(`<p class="scene-break">`)
+		children()
+		builder.WriteString("</p>\n")
+	case Text:
+		builder.WriteString(html.EscapeString(n.Literal))
+	case SoftBreak:
+		builder.WriteString("\n")
+	case HardBreak:
+		builder.WriteString("<br/>\n")
+	case Emphasis:
+		builder.WriteString("<em>")
+		children()
+		builder.WriteString("</em>")
+	case Strong:
+		builder.WriteString("<strong>")
+		children()
+		builder.WriteString("</strong>")
+	case Code:
+		builder.WriteString("<code>" + html.EscapeString(n.Literal) + "</code>")
+	case Link:
+		builder.WriteString(`<a href="` + html.EscapeString(n.Destination) + `"`)
+		if n.Title != "" {
+			builder.WriteString(` title="` + html.EscapeString(n.Title) + `"`)
+		}
+		builder.WriteString(">")
+		children()
+		builder.WriteString("</a>")
+	case Image:
+		builder.WriteString(`<img src="` + html.EscapeString(n.Destination) + `" alt="` + html.EscapeString(PlainText(n)) + `"`)
+		if n.Title != "" {
+			builder.WriteString(` title="` + html.EscapeString(n.Title) + `"`)
+		}
+		builder.WriteString("/>")
+	case WikiLink:
+		builder.WriteString(html.EscapeString(n.Literal))
+	case HTMLInline:
+		builder.WriteString(n.Literal)
+	case Insertion:
+		builder.WriteString("<ins>")
+		children()
+		builder.WriteString("</ins>")
+	case Deletion:
+		builder.WriteString("<del>")
+		children()
+		builder.WriteString("</del>")
+	case Comment:
+		builder.WriteString(`<span class="comment">` + html.EscapeString(n.Literal) + "</span>")
+	}
+}
+
+// PlainText returns the text content of the node and its children without markup. Deleted
+// text and comments are left out.
+func PlainText(n *Node) string {
+	builder := &strings.Builder{}
+	Walk(n, func(n *Node) bool {
+		switch n.Kind {
+		case Text, Code, WikiLink:
+			builder.WriteString(n.Literal)
+		case SoftBreak, HardBreak:
+			builder.WriteString(" ")
+		case FrontMatter, CodeBlock, HTMLBlock, HTMLInline, Deletion, Comment:
+			return false
+		}
+		if n.IsBlock() && n.Kind != Document && builder.Len() > 0 && !strings.HasSuffix(builder.String(), "\n") {
+			builder.WriteString("\n")
+		}
+		return true
+	})
+	return strings.TrimSpace(builder.String())
+}
+
+// wellFormed returns the XHTML with any HTML which is not well-formed XML mended: void
+// elements are self-closed, an element left open is closed along with the element around it,
+// end tags which close nothing are dropped, and a < or & which does not start a tag or
+// character reference is escaped. HTML's named entities, which XML does not know, are
+// replaced by their characters.
+func wellFormed(xhtml string) string {
+	builder := &strings.Builder{}
+	var open []string
+	for idx := 0; idx < len(xhtml); {
+		rest := xhtml[idx:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				builder.WriteString("&lt;")
+				idx++
+				continue
+			}
+			comment := rest[:end+7]
+			if !strings.Contains(comment[4:end+4], "--") {
+				builder.WriteString(comment)
+			}
+			idx += len(comment)
+		case strings.HasPrefix(rest, "<![CDATA["):
+			end := strings.Index(rest, "]]>")
+			if end < 0 {
+				builder.WriteString("&lt;")
+				idx++
+				continue
+			}
+			builder.WriteString(rest[:end+3])
+			idx += end + 3
+		case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+			// declarations and processing instructions have no place in a fragment
+			end := strings.Index(rest, ">")
+			if end < 0 {
+				builder.WriteString("&lt;")
+				idx++
+				continue
+			}
+			idx += end + 1
+		case endTag.MatchString(rest):
+			match := endTag.FindStringSubmatch(rest)
+			name := strings.ToLower(match[1])
+			for depth := len(open) - 1; depth >= 0; depth-- {
+				if open[depth] == name {
+					for len(open) > depth {
+						builder.WriteString("</" + open[len(open)-1] + ">")
+						open = open[:len(open)-1]
+					}
+					break
+				}
+			}
+			idx += len(match[0])
+		case startTag.MatchString(rest):
+			match := startTag.FindStringSubmatch(rest)
+			name := strings.ToLower(match[1])
+			builder.WriteString("<" + name)
+			seen := map[string]bool{}
+			for _, attr := range attribute.FindAllStringSubmatch(match[2], -1) {
+				key := strings.ToLower(attr[1])
+				if seen[key] || !xmlName.MatchString(key) {
+					continue
+				}
+				seen[key] = true
+				value := key
+				if strings.Contains(attr[0], "=") {
+					value = html.UnescapeString(attr[2] + attr[3] + attr[4])
+				}
+				builder.WriteString(" " + key + `="` + html.EscapeString(value) + `"`)
+			}
+			if voidElements[name] || match[3] == "/" {
+				builder.WriteString("/>")
+			} else {
+				builder.WriteString(">")
+				open = append(open, name)
+			}
+			idx += len(match[0])
+		case rest[0] == '<':
+			builder.WriteString("&lt;")
+			idx++
+		case rest[0] == '&':
+			match := entity.FindStringSubmatch(rest)
+			switch {
+			case match == nil:
+				builder.WriteString("&amp;")
+				idx++
+				continue
+			case strings.HasPrefix(match[1], "#"), match[1] == "amp", match[1] == "lt", match[1] == "gt", match[1] == "quot", match[1] == "apos":
+				builder.WriteString(match[0])
+			case html.UnescapeString(match[0]) != match[0]:
+				builder.WriteString(html.EscapeString(html.UnescapeString(match[0])))
+			default:
+				builder.WriteString("&amp;" + match[0][1:])
+			}
+			idx += len(match[0])
+		default:
+			next := strings.IndexAny(rest, "<&")
+			if next < 0 {
+				next = len(rest)
+			}
+			builder.WriteString(rest[:next])
+			idx += next
+		}
+	}
+	for len(open) > 0 {
+		builder.WriteString("</" + open[len(open)-1] + ">")
+		open = open[:len(open)-1]
+	}
+	return builder.String()
+}
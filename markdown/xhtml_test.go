@@ -0,0 +1,117 @@
+package markdown
+
+import "testing"
+
+func TestRenderXHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "paragraphs",
+			input:    "First paragraph.\n\nSecond paragraph.",
+			expected: "<p>First paragraph.</p>\n<p>Second paragraph.</p>\n",
+		},
+		{
+			name:     "heading",
+			input:    "## A Heading ##",
+			expected: "<h2>A Heading</h2>\n",
+		},
+		{
+			name:     "emphasis and strong",
+			input:    "Some *emphasis* and **strong** text.",
+			expected: "<p>Some <em>emphasis</em> and <strong>strong</strong> text.</p>\n",
+		},
+		{
+			name:     "escaped characters",
+			input:    "\\* \\* \\*",
+			expected: "<p>* * *</p>\n",
+		},
+		{
+			name:     "entities are not emphasis",
+			input:    "*&#9;*&#9;*",
+			expected: "<p>*\t*\t*</p>\n",
+		},
+		{
+			name:     "special characters are escaped",
+			input:    "Fish & chips < 3",
+			expected: "<p>Fish &amp; chips &lt; 3</p>\n",
+		},
+		{
+			name:     "code span",
+			input:    "Use `a < b` here",
+			expected: "<p>Use <code>a &lt; b</code> here</p>\n",
+		},
+		{
+			name:     "link",
+			input:    "A [link](http://example.com) here",
+			expected: "<p>A <a href=\"http://example.com\">link</a> here</p>\n",
+		},
+		{
+			name:     "blockquote",
+			input:    "> Quoted text",
+			expected: "<blockquote>\n<p>Quoted text</p>\n</blockquote>\n",
+		},
+		{
+			name:     "bullet list",
+			input:    "- one\n- two",
+			expected: "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n",
+		},
+		{
+			name:     "criticmarkup",
+			input:    "It was {~~cold~>*warm*~~} out.{>>Check <this><<}",
+			expected: "<p>It was <del>cold</del><ins><em>warm</em></ins> out.<span class=\"comment\">Check &lt;this&gt;</span></p>\n",
+		},
+		{
+			name:     "unclosed criticmarkup",
+			input:    "A {++ brace",
+			expected: "<p>A {++ brace</p>\n",
+		},
+		{
+			name:     "thematic break",
+			input:    "***",
+			expected: "<hr/>\n",
+		},
+		{
+			name:     "void html elements are self-closed",
+			input:    "A line<br>break and <IMG SRC=x.png alt='a &amp; b'> here",
+			expected: "<p>A line<br/>break and <img src=\"x.png\" alt=\"a &amp; b\"/> here</p>\n",
+		},
+		{
+			name:     "unclosed html elements are closed",
+			input:    "Some <stuff> and <b>bold\n\nNext</i> one.",
+			expected: "<p>Some <stuff> and <b>bold</b></stuff></p>\n<p>Next one.</p>\n",
+		},
+		{
+			name:     "html entities and stray characters",
+			input:    "<div>\nA&nbsp;b & c <3 <!DOCTYPE x>\n</div>",
+			expected: "<div>\nA\u00a0b &amp; c &lt;3 \n</div>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RenderXHTML(Parse(tt.input))
+			if result != tt.expected {
+				t.Errorf("RenderXHTML(Parse(%q)) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	result := PlainText(Parse("Some *emphasis*\nand `code`{-- too--}.{>>really?<<}"))
+	expected := "Some emphasis and code."
+	if result != expected {
+		t.Errorf("PlainText() = %q, want %q", result, expected)
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	result := RenderXHTML(ParseInline("# *not* a heading"))
+	expected := "# <em>not</em> a heading"
+	if result != expected {
+		t.Errorf("RenderXHTML(ParseInline()) = %q, want %q", result, expected)
+	}
+}
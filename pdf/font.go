@@ -0,0 +1,517 @@
+package pdf
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+var errInvalidFont = errors.New("invalid or unsupported TrueType font")
+
+// font is a struct that represents a TrueType font and the glyphs the document uses from it
+type font struct {
+	name   string
+	tables map[string][]byte
+
+	unitsPerEm  float64
+	ascent      float64
+	descent     float64
+	capHeight   float64
+	italicAngle float64
+	bbox        [4]int
+
+	advances []uint16
+	cmap     map[rune]uint16
+	used     map[uint16]rune
+}
+
+// family is a struct that represents the paths of the four styles of a typeface
+type family struct {
+	regular    string
+	bold       string
+	italic     string
+	boldItalic string
+}
+
+// bundledRegular and bundledBold are DejaVu Serif, which is embedded when no font is
+// configured and none of the system typefaces is installed. Its license is in fonts/LICENSE.
+var (
+	//go:embed fonts/DejaVuSerif.ttf
+	bundledRegular []byte
+	//go:embed fonts/DejaVuSerif-Bold.ttf
+	bundledBold []byte
+)
+
+// systemFamilies are the typefaces searched for when no font is configured
+var systemFamilies = []family{
+	{
+		"/usr/share/fonts/truetype/dejavu/DejaVuSerif.ttf",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSerif-Bold.ttf",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSerif-Italic.ttf",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSerif-BoldItalic.ttf",
+	},
+	{
+		"/usr/share/fonts/TTF/DejaVuSerif.ttf",
+		"/usr/share/fonts/TTF/DejaVuSerif-Bold.ttf",
+		"/usr/share/fonts/TTF/DejaVuSerif-Italic.ttf",
+		"/usr/share/fonts/TTF/DejaVuSerif-BoldItalic.ttf",
+	},
+	{
+		"/usr/share/fonts/truetype/liberation/LiberationSerif-Regular.ttf",
+		"/usr/share/fonts/truetype/liberation/LiberationSerif-Bold.ttf",
+		"/usr/share/fonts/truetype/liberation/LiberationSerif-Italic.ttf",
+		"/usr/share/fonts/truetype/liberation/LiberationSerif-BoldItalic.ttf",
+	},
+	{
+		"/System/Library/Fonts/Supplemental/Times New Roman.ttf",
+		"/System/Library/Fonts/Supplemental/Times New Roman Bold.ttf",
+		"/System/Library/Fonts/Supplemental/Times New Roman Italic.ttf",
+		"/System/Library/Fonts/Supplemental/Times New Roman Bold Italic.ttf",
+	},
+	{
+		`C:\Windows\Fonts\times.ttf`,
+		`C:\Windows\Fonts\timesbd.ttf`,
+		`C:\Windows\Fonts\timesi.ttf`,
+		`C:\Windows\Fonts\timesbi.ttf`,
+	},
+}
+
+// findFamily returns the first of the system typefaces which is installed.
+func findFamily() (family, bool) {
+	for _, f := range systemFamilies {
+		if _, err := os.Stat(f.regular); err == nil {
+			return f, true
+		}
+	}
+	return family{}, false
+}
+
+// loadFont reads and parses the TrueType font at the given path.
+func loadFont(path string) (*font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFont(data)
+}
+
+// parseFont parses the tables of a TrueType font needed for measuring and embedding it.
+func parseFont(data []byte) (*font, error) {
+	if len(data) < 12 {
+		return nil, errInvalidFont
+	}
+	version := u32(data, 0)
+	if version != 0x00010000 && version != 0x74727565 {
+		return nil, errInvalidFont
+	}
+
+	f := &font{tables: map[string][]byte{}, used: map[uint16]rune{}}
+	count := u16(data, 4)
+	for i := 0; i < count; i++ {
+		record := 12 + i*16
+		if record+16 > len(data) {
+			return nil, errInvalidFont
+		}
+		tag := string(data[record : record+4])
+		offset := int(u32(data, record+8))
+		length := int(u32(data, record+12))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errInvalidFont
+		}
+		f.tables[tag] = data[offset : offset+length]
+	}
+
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "cmap", "loca", "glyf"} {
+		if _, ok := f.tables[tag]; !ok {
+			return nil, errInvalidFont
+		}
+	}
+
+	head := f.tables["head"]
+	hhea := f.tables["hhea"]
+	if len(head) < 54 || len(hhea) < 36 || len(f.tables["maxp"]) < 6 {
+		return nil, errInvalidFont
+	}
+	f.unitsPerEm = float64(u16(head, 18))
+	if f.unitsPerEm == 0 {
+		return nil, errInvalidFont
+	}
+	f.bbox = [4]int{s16(head, 36), s16(head, 38), s16(head, 40), s16(head, 42)}
+	f.ascent = float64(s16(hhea, 4))
+	f.descent = float64(s16(hhea, 6))
+	f.capHeight = f.ascent * 0.7
+	if os2 := f.tables["OS/2"]; len(os2) >= 90 && u16(os2, 0) >= 2 {
+		f.capHeight = float64(s16(os2, 88))
+	}
+	if post := f.tables["post"]; len(post) >= 8 {
+		f.italicAngle = float64(int32(u32(post, 4))) / 65536
+	}
+
+	glyphs := u16(f.tables["maxp"], 4)
+	metrics := u16(hhea, 34)
+	hmtx := f.tables["hmtx"]
+	if metrics == 0 || len(hmtx) < metrics*4 {
+		return nil, errInvalidFont
+	}
+	f.advances = make([]uint16, glyphs)
+	for i := range f.advances {
+		if i < metrics {
+			f.advances[i] = uint16(u16(hmtx, i*4))
+		} else {
+			f.advances[i] = uint16(u16(hmtx, (metrics-1)*4))
+		}
+	}
+
+	cmap, err := parseCmap(f.tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	f.cmap = cmap
+	f.name = postScriptName(f.tables["name"])
+
+	return f, nil
+}
+
+// parseCmap parses the Unicode character to glyph mapping of the font.
+func parseCmap(table []byte) (map[rune]uint16, error) {
+	best, format := -1, 0
+	for i := 0; i < u16(table, 2); i++ {
+		record := 4 + i*8
+		platform, encoding := u16(table, record), u16(table, record+2)
+		offset := int(u32(table, record+4))
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicode || offset+4 > len(table) {
+			continue
+		}
+		switch u16(table, offset) {
+		case 12:
+			best, format = offset, 12
+		case 4:
+			if format != 12 {
+				best, format = offset, 4
+			}
+		}
+	}
+	if best < 0 {
+		return nil, errInvalidFont
+	}
+
+	cmap := map[rune]uint16{}
+	sub := table[best:]
+	if format == 12 {
+		groups := int(u32(sub, 12))
+		for i := 0; i < groups; i++ {
+			group := 16 + i*12
+			if group+12 > len(sub) {
+				return nil, errInvalidFont
+			}
+			start, end, glyph := u32(sub, group), u32(sub, group+4), u32(sub, group+8)
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				cmap[rune(c)] = uint16(glyph + c - start)
+			}
+		}
+		return cmap, nil
+	}
+
+	segments := u16(sub, 6) / 2
+	ends := 14
+	starts := ends + segments*2 + 2
+	deltas := starts + segments*2
+	ranges := deltas + segments*2
+	if ranges+segments*2 > len(sub) {
+		return nil, errInvalidFont
+	}
+	for i := 0; i < segments; i++ {
+		start, end := u16(sub, starts+i*2), u16(sub, ends+i*2)
+		delta := u16(sub, deltas+i*2)
+		rangeOffset := u16(sub, ranges+i*2)
+		for c := start; c <= end && c != 0xffff; c++ {
+			glyph := 0
+			if rangeOffset == 0 {
+				glyph = (c + delta) & 0xffff
+			} else {
+				index := ranges + i*2 + rangeOffset + (c-start)*2
+				glyph = u16(sub, index)
+				if glyph != 0 {
+					glyph = (glyph + delta) & 0xffff
+				}
+			}
+			if glyph != 0 {
+				cmap[rune(c)] = uint16(glyph)
+			}
+		}
+	}
+	return cmap, nil
+}
+
+// postScriptName returns the PostScript name of the font from its name table.
+func postScriptName(table []byte) string {
+	count, storage := u16(table, 2), u16(table, 4)
+	for i := 0; i < count; i++ {
+		record := 6 + i*12
+		platform, id := u16(table, record), u16(table, record+6)
+		length, offset := u16(table, record+8), u16(table, record+10)
+		start := storage + offset
+		if id != 6 || start+length > len(table) {
+			continue
+		}
+		raw := table[start : start+length]
+		name := string(raw)
+		if platform == 3 || platform == 0 {
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(raw[j*2:])
+			}
+			name = string(utf16.Decode(units))
+		}
+		name = strings.Map(func(r rune) rune {
+			if r <= ' ' || r > '~' || strings.ContainsRune("[](){}<>/%", r) {
+				return -1
+			}
+			return r
+		}, name)
+		if name != "" {
+			return name
+		}
+	}
+	return "Font"
+}
+
+// glyph returns the glyph for the rune, recording that the document uses it.
+func (f *font) glyph(r rune) uint16 {
+	g, ok := f.cmap[r]
+	if !ok {
+		return 0
+	}
+	if _, seen := f.used[g]; !seen {
+		f.used[g] = r
+	}
+	return g
+}
+
+// advance returns the advance width of the rune at the given size, in points.
+func (f *font) advance(r rune, size float64) float64 {
+	g := f.cmap[r]
+	if int(g) >= len(f.advances) {
+		return 0
+	}
+	return float64(f.advances[g]) * size / f.unitsPerEm
+}
+
+// width returns the width of the text at the given size, in points.
+func (f *font) width(text string, size float64) float64 {
+	w := 0.0
+	for _, r := range text {
+		w += f.advance(r, size)
+	}
+	return w
+}
+
+// encode returns the text as a hexadecimal string of glyph identifiers.
+func (f *font) encode(text string) string {
+	builder := &strings.Builder{}
+	builder.WriteString("<")
+	for _, r := range text {
+		builder.WriteString(hex4(int(f.glyph(r))))
+	}
+	builder.WriteString(">")
+	return builder.String()
+}
+
+// scale converts a value in font units to the thousandths of an em used by PDF.
+func (f *font) scale(v float64) int {
+	return int(v * 1000 / f.unitsPerEm)
+}
+
+// usedGlyphs returns the glyphs used by the document in ascending order.
+func (f *font) usedGlyphs() []uint16 {
+	glyphs := make([]uint16, 0, len(f.used))
+	for g := range f.used {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// subset returns a copy of the font which only contains the outlines of the used
+// glyphs. Glyph identifiers are unchanged, so unused glyphs are left empty.
+func (f *font) subset() []byte {
+	loca := f.tables["loca"]
+	glyf := f.tables["glyf"]
+	long := s16(f.tables["head"], 50) == 1
+	location := func(g int) (int, int) {
+		if long {
+			return int(u32(loca, g*4)), int(u32(loca, g*4+4))
+		}
+		return u16(loca, g*2) * 2, u16(loca, g*2+2) * 2
+	}
+
+	keep := map[int]bool{0: true}
+	queue := []int{0}
+	for _, g := range f.usedGlyphs() {
+		queue = append(queue, int(g))
+	}
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+		keep[g] = true
+		start, end := location(g)
+		if end <= start || end > len(glyf) || s16(glyf, start) >= 0 {
+			continue
+		}
+		// composite glyphs reference the outlines of other glyphs
+		offset := start + 10
+		for offset+4 <= end {
+			flags, component := u16(glyf, offset), u16(glyf, offset+2)
+			if !keep[component] {
+				keep[component] = true
+				queue = append(queue, component)
+			}
+			offset += 4
+			if flags&0x0001 != 0 {
+				offset += 4
+			} else {
+				offset += 2
+			}
+			switch {
+			case flags&0x0008 != 0:
+				offset += 2
+			case flags&0x0040 != 0:
+				offset += 4
+			case flags&0x0080 != 0:
+				offset += 8
+			}
+			if flags&0x0020 == 0 {
+				break
+			}
+		}
+	}
+
+	newGlyf := &bytes.Buffer{}
+	newLoca := make([]byte, (len(f.advances)+1)*4)
+	for g := range f.advances {
+		binary.BigEndian.PutUint32(newLoca[g*4:], uint32(newGlyf.Len()))
+		start, end := location(g)
+		if keep[g] && start < end && end <= len(glyf) {
+			newGlyf.Write(glyf[start:end])
+			for newGlyf.Len()%4 != 0 {
+				newGlyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[len(f.advances)*4:], uint32(newGlyf.Len()))
+
+	head := append([]byte{}, f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{
+		"head": head,
+		"hhea": f.tables["hhea"],
+		"maxp": f.tables["maxp"],
+		"hmtx": f.tables["hmtx"],
+		"loca": newLoca,
+		"glyf": newGlyf.Bytes(),
+	}
+	for _, tag := range []string{"cmap", "cvt ", "fpgm", "prep"} {
+		if table, ok := f.tables[tag]; ok {
+			tables[tag] = table
+		}
+	}
+
+	return buildFont(tables)
+}
+
+// buildFont assembles the tables into a TrueType font file.
+func buildFont(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	selector := 0
+	for 1<<(selector+1) <= len(tags) {
+		selector++
+	}
+	searchRange := (1 << selector) * 16
+
+	out := &bytes.Buffer{}
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(tags)))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(selector))
+	binary.BigEndian.PutUint16(header[10:], uint16(len(tags)*16-searchRange))
+	out.Write(header)
+
+	offset := 12 + len(tags)*16
+	body := &bytes.Buffer{}
+	for _, tag := range tags {
+		table := tables[tag]
+		record := make([]byte, 16)
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], checksum(table))
+		binary.BigEndian.PutUint32(record[8:], uint32(offset+body.Len()))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+		out.Write(record)
+		body.Write(table)
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+	}
+	out.Write(body.Bytes())
+
+	data := out.Bytes()
+	// the head table records an adjustment which makes the checksum of the whole file a fixed value
+	for i, tag := range tags {
+		if tag == "head" {
+			headOffset := int(binary.BigEndian.Uint32(data[12+i*16+8:]))
+			binary.BigEndian.PutUint32(data[headOffset+8:], 0xB1B0AFBA-checksum(data))
+		}
+	}
+	return data
+}
+
+// checksum returns the TrueType checksum of the table.
+func checksum(table []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(table); i += 4 {
+		var word [4]byte
+		copy(word[:], table[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// u16 reads an unsigned 16-bit value, returning zero past the end of the data.
+func u16(b []byte, offset int) int {
+	if offset < 0 || offset+2 > len(b) {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b[offset:]))
+}
+
+// s16 reads a signed 16-bit value, returning zero past the end of the data.
+func s16(b []byte, offset int) int {
+	return int(int16(u16(b, offset)))
+}
+
+// u32 reads an unsigned 32-bit value, returning zero past the end of the data.
+func u32(b []byte, offset int) uint32 {
+	if offset < 0 || offset+4 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b[offset:])
+}
+
+// hex4 formats the value as four hexadecimal digits.
+func hex4(v int) string {
+	const digits = "0123456789ABCDEF"
+	return string([]byte{digits[v>>12&0xf], digits[v>>8&0xf], digits[v>>4&0xf], digits[v&0xf]})
+}
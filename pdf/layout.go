@@ -0,0 +1,682 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// style is a type that represents the weight and slant of a run of text
+type style int
+
+const (
+	regular style = 0
+	bold    style = 1
+	italic  style = 2
+)
+
+// alignment is a type that represents how a line is placed within the measure
+type alignment int
+
+const (
+	justified alignment = iota
+	left
+	centered
+)
+
+const (
+	// widows and orphans are the fewest lines of a paragraph left alone at the top
+	// or bottom of a page
+	widows  = 2
+	orphans = 2
+
+	// dropLines is the number of lines a drop cap spans
+	dropLines = 3
+)
+
+// face is a struct that represents a font in one style, which may be slanted or
+// emboldened when the typeface has no matching font
+type face struct {
+	font     *font
+	resource string
+	slant    bool
+	embolden bool
+}
+
+// box is a struct that represents a run of text which cannot be broken across lines
+type box struct {
+	text  string
+	style style
+	size  float64
+	width float64
+
+	// space reports whether a breakable space precedes the box
+	space bool
+
+	// hardBreak marks the end of a line within a paragraph
+	hardBreak bool
+}
+
+// line is a struct that represents a single set line of text
+type line struct {
+	boxes   []box
+	indent  float64
+	measure float64
+	align   alignment
+	last    bool
+
+	// marker is drawn in the margin before the line, for list items
+	marker string
+
+	// drop is a drop cap which hangs from the line
+	drop *dropCap
+}
+
+// dropCap is a struct that represents an enlarged initial letter
+type dropCap struct {
+	text string
+	size float64
+}
+
+// page is a struct that represents a single page of the book
+type page struct {
+	content *strings.Builder
+	header  string
+	folio   bool
+}
+
+// block is a struct that represents the state of the block being set
+type block struct {
+	left   float64
+	right  float64
+	style  style
+	align  alignment
+	indent bool
+}
+
+// typesetter is a struct that represents the state of the page layout as the book
+// is set
+type typesetter struct {
+	settings settings
+	faces    [4]*face
+	title    string
+	chapter  string
+
+	pages []*page
+	page  *page
+	y     float64
+
+	indentNext bool
+	dropNext   bool
+	marker     string
+}
+
+// newTypesetter returns a typesetter for the given page settings and faces.
+func newTypesetter(s settings, faces [4]*face, title string) *typesetter {
+	return &typesetter{settings: s, faces: faces, title: title}
+}
+
+// book sets the front matter, sections, chapters and parts of the book.
+func (t *typesetter) book(book *manuscript.Book) {
+	s := t.settings
+
+	if book.Title != "" {
+		t.frontPage()
+		t.y = t.top() - s.textHeight()/4
+		t.setLine(book.Title, regular, s.size*2.2, centered)
+		if len(book.Authors) > 0 {
+			t.space(s.leading * 2)
+			t.setLine(strings.Join(book.Authors, ", "), regular, s.size*1.3, centered)
+		}
+	}
+
+	if book.Dedication != nil {
+		t.frontPage()
+		t.y = t.top() - s.textHeight()/4
+		t.indentNext = false
+		t.blocks(book.Dedication.Children, block{style: italic, align: centered})
+	}
+
+	for _, entry := range book.Contents() {
+		if section := entry.Section; section != nil {
+			t.opening(section.Title)
+			t.blocks(section.Document.Children, block{indent: true})
+			continue
+		}
+		if part := entry.Part; part != nil {
+			// a part title stands alone on a recto page
+			t.frontPage()
+			t.y = t.top() - s.textHeight()/3
+			t.setLine(part.Title, regular, s.size*2.2, centered)
+			continue
+		}
+
+		chapter := entry.Chapter
+		t.opening(chapter.Title)
+		separator := markdown.PlainText(book.SceneBreak(chapter))
+		for idx, scene := range chapter.Scenes {
+			if idx > 0 {
+				t.separator(separator)
+			}
+			t.blocks(scene.Document.Children, block{indent: true})
+		}
+	}
+}
+
+// frontPage starts a recto page without running headers or page numbers.
+func (t *typesetter) frontPage() {
+	t.recto()
+	t.page.folio = false
+	t.page.header = ""
+}
+
+// opening starts a section or chapter on a new recto page, sunk below the top margin.
+func (t *typesetter) opening(title string) {
+	s := t.settings
+	t.chapter = title
+	t.recto()
+	t.page.folio = true
+	t.page.header = ""
+
+	t.y = t.top() - s.textHeight()/5
+	if title != "" {
+		t.setLine(title, regular, s.size*1.8, centered)
+		t.space(s.leading * 2)
+	}
+	t.indentNext = false
+	t.dropNext = s.dropCaps
+}
+
+// recto starts a new right-hand page, leaving the back of the previous page blank.
+func (t *typesetter) recto() {
+	if len(t.pages)%2 == 1 {
+		t.newPage()
+		t.page.header = ""
+		t.page.folio = false
+	}
+	t.newPage()
+}
+
+// newPage starts a new page with the running header for its side of the spread.
+func (t *typesetter) newPage() {
+	p := &page{content: &strings.Builder{}, folio: true}
+	if t.chapter != "" {
+		p.header = t.chapter
+		if len(t.pages)%2 == 1 {
+			p.header = t.title
+		}
+	}
+	t.pages = append(t.pages, p)
+	t.page = p
+	t.y = t.top()
+}
+
+// top returns the top of the text block.
+func (t *typesetter) top() float64 {
+	return t.settings.height - t.settings.top
+}
+
+// atTop reports whether nothing has been set on the current page.
+func (t *typesetter) atTop() bool {
+	return t.page == nil || t.y >= t.top()
+}
+
+// available returns the number of lines which fit on the rest of the page.
+func (t *typesetter) available() int {
+	n := int((t.y-t.settings.bottom)/t.settings.leading + 0.001)
+	return max(n, 0)
+}
+
+// space adds vertical space, which is dropped at the top and bottom of pages.
+func (t *typesetter) space(height float64) {
+	if t.atTop() {
+		return
+	}
+	t.y -= height
+	if t.y < t.settings.bottom {
+		t.newPage()
+	}
+}
+
+// setLine sets a single line of text outside of the flow of paragraphs.
+func (t *typesetter) setLine(text string, st style, size float64, align alignment) {
+	boxes := t.words(text, st, size)
+	lines := t.breakLines(boxes, func(int) (float64, float64) { return 0, t.settings.measure() })
+	for idx := range lines {
+		lines[idx].align = align
+	}
+
+	leading := t.settings.leading * size / t.settings.size
+	for _, l := range lines {
+		if t.y-leading < t.settings.bottom {
+			t.newPage()
+		}
+		t.drawLine(l, t.y-leading*0.8)
+		t.y -= leading
+	}
+}
+
+// separator sets a scene break, keeping it with the start of the next scene.
+func (t *typesetter) separator(text string) {
+	leading := t.settings.leading
+	if t.available() < 2+orphans {
+		t.newPage()
+	} else {
+		t.space(leading / 2)
+	}
+	t.setLine(text, regular, t.settings.size, centered)
+	t.space(leading / 2)
+	t.indentNext = false
+}
+
+// blocks sets a list of block nodes.
+func (t *typesetter) blocks(nodes []*markdown.Node, b block) {
+	s := t.settings
+	for _, n := range nodes {
+		switch n.Kind {
+		case markdown.Paragraph:
+			t.paragraph(n.Children, b)
+			t.indentNext = b.indent
+
+		case markdown.Heading:
+			if t.available() < 2+orphans {
+				t.newPage()
+			} else {
+				t.space(s.leading / 2)
+			}
+			t.paragraph(n.Children, block{left: b.left, right: b.right, style: bold, align: centered})
+			t.space(s.leading / 2)
+			t.indentNext = false
+
+		case markdown.BlockQuote:
+			t.space(s.leading / 2)
+			t.indentNext = false
+			t.blocks(n.Children, block{left: b.left + s.size*1.5, right: b.right + s.size*1.5, style: b.style, align: b.align})
+			t.space(s.leading / 2)
+			t.indentNext = false
+
+		case markdown.List:
+			t.space(s.leading / 2)
+			for idx, item := range n.Children {
+				t.marker = "•"
+				if n.Ordered {
+					t.marker = strconv.Itoa(n.Start+idx) + n.Marker
+				}
+				t.indentNext = false
+				t.blocks(item.Children, block{left: b.left + s.size*1.5, right: b.right, style: b.style, align: b.align})
+			}
+			t.marker = ""
+			t.space(s.leading / 2)
+			t.indentNext = false
+
+		case markdown.CodeBlock:
+			var lines []line
+			for _, text := range strings.Split(strings.TrimSuffix(n.Literal, "\n"), "\n") {
+				lines = append(lines, line{
+					boxes:  []box{t.box(strings.ReplaceAll(text, "\t", "    "), regular, s.size*0.9)},
+					indent: b.left + s.size*1.5,
+					align:  left,
+				})
+			}
+			t.space(s.leading / 2)
+			t.placeLines(lines)
+			t.space(s.leading / 2)
+			t.indentNext = false
+
+		case markdown.ThematicBreak:
+			t.separator("* * *")
+
+		case markdown.SceneBreak:
+			t.separator(markdown.PlainText(n))
+		}
+	}
+}
+
+// paragraph breaks the inline nodes into lines and sets them.
+func (t *typesetter) paragraph(nodes []*markdown.Node, b block) {
+	s := t.settings
+	var boxes []box
+	space := false
+	t.inlines(nodes, b.style, s.size, &boxes, &space)
+	if len(boxes) == 0 {
+		return
+	}
+
+	first := 0.0
+	if t.indentNext && b.align == justified {
+		first = s.size * 1.5
+	}
+
+	var drop *dropCap
+	dropWidth := 0.0
+	if t.dropNext && b.align == justified {
+		t.dropNext = false
+		drop, boxes = t.dropCap(boxes)
+		if drop != nil {
+			first = 0
+			dropWidth = t.faces[regular].font.width(drop.text, drop.size) + s.size*0.3
+		}
+	}
+
+	measure := s.measure() - b.left - b.right
+	lines := t.breakLines(boxes, func(n int) (float64, float64) {
+		indent := b.left
+		if n == 0 {
+			indent += first
+		}
+		if drop != nil && n < dropLines {
+			indent += dropWidth
+		}
+		return indent, measure - (indent - b.left)
+	})
+	if len(lines) == 0 {
+		return
+	}
+
+	for idx := range lines {
+		lines[idx].align = b.align
+	}
+	lines[0].marker = t.marker
+	lines[0].drop = drop
+	t.marker = ""
+
+	if drop != nil && t.available() < dropLines {
+		t.newPage()
+	}
+	t.placeLines(lines)
+	if drop != nil && len(lines) < dropLines {
+		t.y -= float64(dropLines-len(lines)) * s.leading
+	}
+}
+
+// dropCap removes the initial letter, and any punctuation before it, from the boxes
+// of a paragraph and returns it as a drop cap.
+func (t *typesetter) dropCap(boxes []box) (*dropCap, []box) {
+	if len(boxes) == 0 || boxes[0].hardBreak {
+		return nil, boxes
+	}
+
+	runes := []rune(boxes[0].text)
+	end := 0
+	for end < len(runes) && unicode.IsPunct(runes[end]) {
+		end++
+	}
+	if end >= len(runes) || !unicode.IsLetter(runes[end]) && !unicode.IsDigit(runes[end]) {
+		return nil, boxes
+	}
+	end++
+
+	s := t.settings
+	f := t.faces[regular].font
+	ratio := f.capHeight / f.unitsPerEm
+	size := (s.size*ratio + float64(dropLines-1)*s.leading) / ratio
+	drop := &dropCap{text: string(runes[:end]), size: size}
+
+	rest := append([]box{}, boxes...)
+	if end == len(runes) {
+		rest = rest[1:]
+		if len(rest) > 0 {
+			rest[0].space = false
+		}
+	} else {
+		rest[0] = t.box(string(runes[end:]), rest[0].style, rest[0].size)
+	}
+	return drop, rest
+}
+
+// inlines converts inline nodes into boxes in the given style.
+func (t *typesetter) inlines(nodes []*markdown.Node, st style, size float64, boxes *[]box, space *bool) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case markdown.Text, markdown.Code, markdown.WikiLink:
+			t.text(n.Literal, st, size, boxes, space)
+		case markdown.SoftBreak:
+			*space = true
+		case markdown.HardBreak:
+			*boxes = append(*boxes, box{hardBreak: true})
+			*space = false
+		case markdown.Emphasis:
+			t.inlines(n.Children, st^italic, size, boxes, space)
+		case markdown.Strong:
+			t.inlines(n.Children, st|bold, size, boxes, space)
+		case markdown.HTMLInline, markdown.Deletion, markdown.Comment:
+			// a review build shows the text as its changes would leave it
+		default:
+			t.inlines(n.Children, st, size, boxes, space)
+		}
+	}
+}
+
+// text splits the text into boxes at its spaces.
+func (t *typesetter) text(text string, st style, size float64, boxes *[]box, space *bool) {
+	word := &strings.Builder{}
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		b := t.box(word.String(), st, size)
+		b.space = *space
+		*boxes = append(*boxes, b)
+		*space = false
+		word.Reset()
+	}
+
+	for _, r := range text {
+		if r == ' ' || r == '\t' || r == '\n' {
+			flush()
+			*space = true
+			continue
+		}
+		word.WriteRune(r)
+	}
+	flush()
+}
+
+// words splits plain text into boxes.
+func (t *typesetter) words(text string, st style, size float64) []box {
+	var boxes []box
+	space := false
+	t.text(text, st, size, &boxes, &space)
+	return boxes
+}
+
+// box returns a box of text measured in the given style.
+func (t *typesetter) box(text string, st style, size float64) box {
+	return box{text: text, style: st, size: size, width: t.faces[st].font.width(text, size)}
+}
+
+// spaceWidth returns the width of the space before the box.
+func (t *typesetter) spaceWidth(b box) float64 {
+	return t.faces[b.style].font.advance(' ', b.size)
+}
+
+// breakLines breaks the boxes into lines, filling each line before starting the next.
+// The shape function returns the indent and measure of the nth line.
+func (t *typesetter) breakLines(boxes []box, shape func(n int) (float64, float64)) []line {
+	var lines []line
+	current := line{}
+	width := 0.0
+	current.indent, current.measure = shape(0)
+
+	finish := func() {
+		lines = append(lines, current)
+		current = line{}
+		width = 0
+		current.indent, current.measure = shape(len(lines))
+	}
+
+	for idx := 0; idx < len(boxes); {
+		if boxes[idx].hardBreak {
+			current.last = true
+			finish()
+			idx++
+			continue
+		}
+
+		// a word is a run of boxes with no breakable space between them
+		end := idx + 1
+		wordWidth := boxes[idx].width
+		for end < len(boxes) && !boxes[end].space && !boxes[end].hardBreak {
+			wordWidth += boxes[end].width
+			end++
+		}
+
+		space := 0.0
+		if len(current.boxes) > 0 {
+			space = t.spaceWidth(boxes[idx])
+			if width+space+wordWidth > current.measure {
+				finish()
+				space = 0
+			}
+		}
+
+		for j := idx; j < end; j++ {
+			b := boxes[j]
+			if j == idx {
+				b.space = len(current.boxes) > 0
+			}
+			current.boxes = append(current.boxes, b)
+		}
+		width += space + wordWidth
+		idx = end
+	}
+
+	if len(current.boxes) > 0 {
+		current.last = true
+		lines = append(lines, current)
+	} else if len(lines) > 0 {
+		lines[len(lines)-1].last = true
+	}
+	return lines
+}
+
+// placeLines sets the lines of a paragraph, breaking it across pages so that no fewer
+// than the minimum number of lines are left at the bottom or top of a page.
+func (t *typesetter) placeLines(lines []line) {
+	for len(lines) > 0 {
+		available := t.available()
+		if len(lines) <= available {
+			t.setLines(lines)
+			return
+		}
+
+		n := available
+		if len(lines)-n < widows {
+			n = len(lines) - widows
+		}
+		if n < orphans {
+			if !t.atTop() {
+				t.newPage()
+				continue
+			}
+			// the page cannot hold enough lines to honor the rules
+			n = max(available, 1)
+		}
+
+		t.setLines(lines[:n])
+		lines = lines[n:]
+		t.newPage()
+	}
+}
+
+// setLines sets the lines on the current page.
+func (t *typesetter) setLines(lines []line) {
+	for _, l := range lines {
+		t.drawLine(l, t.y-t.settings.leading*0.8)
+		t.y -= t.settings.leading
+	}
+}
+
+// drawLine draws the line at the given baseline.
+func (t *typesetter) drawLine(l line, baseline float64) {
+	natural := 0.0
+	gaps := 0
+	for _, b := range l.boxes {
+		natural += b.width
+		if b.space {
+			natural += t.spaceWidth(b)
+			gaps++
+		}
+	}
+
+	x := t.left(len(t.pages)) + l.indent
+	extra := 0.0
+	switch l.align {
+	case justified:
+		if !l.last && gaps > 0 && natural < l.measure {
+			extra = (l.measure - natural) / float64(gaps)
+		}
+	case centered:
+		x += (l.measure - natural) / 2
+	}
+
+	if l.drop != nil {
+		capBaseline := baseline - float64(dropLines-1)*t.settings.leading
+		t.drawText(l.drop.text, regular, l.drop.size, t.left(len(t.pages)), capBaseline)
+	}
+	if l.marker != "" {
+		width := t.faces[regular].font.width(l.marker, t.settings.size)
+		t.drawText(l.marker, regular, t.settings.size, x-width-t.settings.size*0.5, baseline)
+	}
+
+	for _, b := range l.boxes {
+		if b.space {
+			x += t.spaceWidth(b) + extra
+		}
+		t.drawText(b.text, b.style, b.size, x, baseline)
+		x += b.width
+	}
+}
+
+// drawText draws the text on the current page with its left edge at x.
+func (t *typesetter) drawText(text string, st style, size float64, x float64, y float64) {
+	if text == "" {
+		return
+	}
+	f := t.faces[st]
+	slant := "0"
+	if f.slant {
+		slant = "0.2"
+	}
+
+	content := t.page.content
+	if f.embolden {
+		content.WriteString("q " + number(size*0.03) + " w 2 Tr\n")
+	}
+	content.WriteString("BT /" + f.resource + " " + number(size) + " Tf 1 0 " + slant + " 1 " + number(x) + " " + number(y) + " Tm " + f.font.encode(text) + " Tj ET\n")
+	if f.embolden {
+		content.WriteString("0 Tr Q\n")
+	}
+}
+
+// left returns the left edge of the text block on the nth page; the inside margin is
+// on the left of recto pages and on the right of verso pages.
+func (t *typesetter) left(n int) float64 {
+	if n%2 == 0 {
+		return t.settings.outside
+	}
+	return t.settings.inside
+}
+
+// finish draws the running headers and page numbers of every page.
+func (t *typesetter) finish() {
+	s := t.settings
+	size := s.size * 0.85
+	for idx, p := range t.pages {
+		t.page = p
+		left := t.left(idx + 1)
+
+		if p.header != "" {
+			width := t.faces[italic].font.width(p.header, size)
+			t.drawText(p.header, italic, size, left+(s.measure()-width)/2, s.height-s.top/2)
+		}
+		if p.folio {
+			folio := strconv.Itoa(idx + 1)
+			width := t.faces[regular].font.width(folio, size)
+			t.drawText(folio, regular, size, left+(s.measure()-width)/2, s.bottom/2)
+		}
+	}
+}
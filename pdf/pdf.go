@@ -0,0 +1,240 @@
+package pdf
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+)
+
+// trimSizes are the named page sizes, in inches
+var trimSizes = map[string][2]float64{
+	"letter": {8.5, 11},
+	"a4":     {8.27, 11.69},
+	"a5":     {5.83, 8.27},
+}
+
+// settings is a struct that represents the page geometry and type size of the book, in points
+type settings struct {
+	width   float64
+	height  float64
+	inside  float64
+	outside float64
+	top     float64
+	bottom  float64
+
+	size     float64
+	leading  float64
+	dropCaps bool
+}
+
+// measure returns the width of the text block.
+func (s settings) measure() float64 {
+	return s.width - s.inside - s.outside
+}
+
+// textHeight returns the height of the text block.
+func (s settings) textHeight() float64 {
+	return s.height - s.top - s.bottom
+}
+
+// Write typesets the book and writes it as a PDF to the file with the given filename.
+func Write(book *manuscript.Book, options config.PDFConfig, filename string) error {
+	s, err := newSettings(options)
+	if err != nil {
+		return err
+	}
+
+	faces, err := loadFaces(options)
+	if err != nil {
+		return err
+	}
+
+	t := newTypesetter(s, faces, book.Title)
+	t.book(book)
+	t.finish()
+
+	return os.WriteFile(filename, render(book, t), 0644)
+}
+
+// newSettings converts the options to page settings, filling in the defaults.
+func newSettings(options config.PDFConfig) (settings, error) {
+	width, height, err := parseTrimSize(options.TrimSize)
+	if err != nil {
+		return settings{}, err
+	}
+
+	inch := func(value float64, fallback float64) float64 {
+		if value <= 0 {
+			value = fallback
+		}
+		return value * 72
+	}
+
+	s := settings{
+		width:    width * 72,
+		height:   height * 72,
+		inside:   inch(options.InsideMargin, 0.875),
+		outside:  inch(options.OutsideMargin, 0.625),
+		top:      inch(options.TopMargin, 0.75),
+		bottom:   inch(options.BottomMargin, 0.75),
+		size:     options.FontSize,
+		dropCaps: options.DropCaps,
+	}
+	if s.size <= 0 {
+		s.size = 11
+	}
+	s.leading = s.size * options.LineHeight
+	if options.LineHeight <= 0 {
+		s.leading = s.size * 1.35
+	}
+
+	if s.measure() <= s.size*10 || s.textHeight() <= s.leading*(widows+orphans) {
+		return settings{}, fmt.Errorf("margins leave no room for text on a %q page", options.TrimSize)
+	}
+	return s, nil
+}
+
+// parseTrimSize parses a trim size given as a name or as width by height in inches, such as 6x9.
+func parseTrimSize(trim string) (float64, float64, error) {
+	trim = strings.ToLower(strings.TrimSpace(trim))
+	if trim == "" {
+		trim = "6x9"
+	}
+	if size, ok := trimSizes[trim]; ok {
+		return size[0], size[1], nil
+	}
+
+	parts := strings.Split(strings.TrimSuffix(trim, "in"), "x")
+	if len(parts) == 2 {
+		width, werr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		height, herr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if werr == nil && herr == nil && width > 0 && height > 0 {
+			return width, height, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid trim size %q", trim)
+}
+
+// loadFaces loads the configured fonts, or an installed typeface when none is configured,
+// or the bundled typeface when none is installed. Styles without a font of their own are
+// slanted or emboldened from the regular font.
+func loadFaces(options config.PDFConfig) ([4]*face, error) {
+	var faces [4]*face
+	paths := family{options.Font, options.BoldFont, options.ItalicFont, options.BoldItalicFont}
+	configured := paths.regular != ""
+	if !configured {
+		found, ok := findFamily()
+		if !ok {
+			return bundledFaces()
+		}
+		paths = found
+	}
+
+	load := func(path string) (*font, error) {
+		if path == "" {
+			return nil, nil
+		}
+		if _, err := os.Stat(path); err != nil && !configured {
+			return nil, nil
+		}
+		return loadFont(path)
+	}
+
+	fonts := [4]*font{}
+	for idx, path := range []string{paths.regular, paths.bold, paths.italic, paths.boldItalic} {
+		f, err := load(path)
+		if err != nil {
+			return faces, err
+		}
+		fonts[idx] = f
+	}
+	return newFaces(fonts), nil
+}
+
+// bundledFaces returns the faces of the bundled typeface, which has no italic styles of its
+// own.
+func bundledFaces() ([4]*face, error) {
+	fonts := [4]*font{}
+	for idx, data := range [][]byte{bundledRegular, bundledBold} {
+		f, err := parseFont(data)
+		if err != nil {
+			return [4]*face{}, err
+		}
+		fonts[idx] = f
+	}
+	return newFaces(fonts), nil
+}
+
+// newFaces returns the faces of the four styles from the fonts of those styles, slanting or
+// emboldening the others from the regular font.
+func newFaces(fonts [4]*font) [4]*face {
+	var faces [4]*face
+	faces[regular] = &face{font: fonts[0]}
+	faces[bold] = &face{font: fonts[1]}
+	if fonts[1] == nil {
+		faces[bold] = &face{font: fonts[0], embolden: true}
+	}
+	faces[italic] = &face{font: fonts[2]}
+	if fonts[2] == nil {
+		faces[italic] = &face{font: fonts[0], slant: true}
+	}
+	faces[bold|italic] = &face{font: fonts[3]}
+	if fonts[3] == nil {
+		switch {
+		case fonts[1] != nil:
+			faces[bold|italic] = &face{font: fonts[1], slant: true}
+		case fonts[2] != nil:
+			faces[bold|italic] = &face{font: fonts[2], embolden: true}
+		default:
+			faces[bold|italic] = &face{font: fonts[0], slant: true, embolden: true}
+		}
+	}
+
+	// faces which share a font share its resource name
+	names := map[*font]string{}
+	for _, f := range faces {
+		if _, ok := names[f.font]; !ok {
+			names[f.font] = "F" + strconv.Itoa(len(names)+1)
+		}
+		f.resource = names[f.font]
+	}
+
+	return faces
+}
+
+// render writes the typeset pages and their fonts as a PDF file.
+func render(book *manuscript.Book, t *typesetter) []byte {
+	w := newWriter()
+	catalogID, pagesID, infoID := w.reserve(), w.reserve(), w.reserve()
+
+	resources := &strings.Builder{}
+	written := map[*font]bool{}
+	for _, f := range t.faces {
+		if written[f.font] {
+			continue
+		}
+		written[f.font] = true
+		resources.WriteString("/" + f.resource + " " + ref(w.writeFont(f.font)) + " ")
+	}
+
+	s := t.settings
+	box := "[0 0 " + number(s.width) + " " + number(s.height) + "]"
+	kids := &strings.Builder{}
+	for _, p := range t.pages {
+		pageID, contentID := w.reserve(), w.reserve()
+		w.stream(contentID, "", []byte(p.content.String()))
+		w.object(pageID, "<< /Type /Page /Parent "+ref(pagesID)+" /MediaBox "+box+" /TrimBox "+box+
+			" /Resources << /Font << "+resources.String()+">> >> /Contents "+ref(contentID)+" >>")
+		kids.WriteString(ref(pageID) + " ")
+	}
+
+	w.object(pagesID, "<< /Type /Pages /Kids ["+kids.String()+"] /Count "+strconv.Itoa(len(t.pages))+" >>")
+	w.object(catalogID, "<< /Type /Catalog /Pages "+ref(pagesID)+" >>")
+	w.object(infoID, "<< /Title "+text(book.Title)+" /Author "+text(strings.Join(book.Authors, ", "))+" /Producer (inkwell) >>")
+
+	return w.finish(catalogID, infoID)
+}
@@ -0,0 +1,199 @@
+package pdf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// testFont returns a font in which every character is half an em wide.
+func testFont() *font {
+	f := &font{unitsPerEm: 1000, ascent: 800, descent: -200, capHeight: 700, cmap: map[rune]uint16{}, used: map[uint16]rune{}}
+	f.advances = []uint16{500, 500}
+	for r := rune(' '); r <= '~'; r++ {
+		f.cmap[r] = 1
+	}
+	return f
+}
+
+// testTypesetter returns a typesetter on a small page with a text block five lines deep.
+func testTypesetter() *typesetter {
+	f := testFont()
+	var faces [4]*face
+	for idx := range faces {
+		faces[idx] = &face{font: f, resource: "F1"}
+	}
+	s := settings{width: 200, height: 100, inside: 20, outside: 20, top: 20, bottom: 20, size: 10, leading: 12}
+	t := newTypesetter(s, faces, "Title")
+	t.newPage()
+	return t
+}
+
+func TestParseTrimSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		width   float64
+		height  float64
+		wantErr bool
+	}{
+		{input: "", width: 6, height: 9},
+		{input: "5.5x8.5", width: 5.5, height: 8.5},
+		{input: "6 x 9in", width: 6, height: 9},
+		{input: "A5", width: 5.83, height: 8.27},
+		{input: "huge", wantErr: true},
+		{input: "0x9", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			width, height, err := parseTrimSize(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseTrimSize(%q) expected error but got none", tt.input)
+				}
+				return
+			}
+			if err != nil || width != tt.width || height != tt.height {
+				t.Errorf("parseTrimSize(%q) = %v, %v, %v, want %v, %v", tt.input, width, height, err, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestBreakLines(t *testing.T) {
+	ts := testTypesetter()
+	boxes := ts.words("aaaa bbbb cccc dddd", regular, 10)
+
+	// each word is 20 points wide and each space 5 points
+	lines := ts.breakLines(boxes, func(int) (float64, float64) { return 0, 50 })
+	if len(lines) != 2 {
+		t.Fatalf("breakLines() returned %d lines, want 2", len(lines))
+	}
+	if len(lines[0].boxes) != 2 || lines[0].boxes[0].text != "aaaa" || lines[0].boxes[0].space {
+		t.Errorf("first line = %+v, want two words without a leading space", lines[0].boxes)
+	}
+	if lines[0].last || !lines[1].last {
+		t.Error("only the final line should be marked as the last line")
+	}
+}
+
+func TestPlaceLinesWidowsAndOrphans(t *testing.T) {
+	tests := []struct {
+		name      string
+		set       int
+		lines     int
+		wantPages int
+		wantFirst int
+	}{
+		{name: "fits", set: 0, lines: 5, wantPages: 1, wantFirst: 5},
+		{name: "orphan moves paragraph", set: 4, lines: 3, wantPages: 2, wantFirst: 0},
+		{name: "widow pulls line over", set: 2, lines: 4, wantPages: 2, wantFirst: 2},
+		{name: "plain split", set: 1, lines: 6, wantPages: 2, wantFirst: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := testTypesetter()
+			ts.y -= float64(tt.set) * ts.settings.leading
+
+			lines := make([]line, tt.lines)
+			for idx := range lines {
+				lines[idx] = line{boxes: []box{ts.box("x", regular, 10)}, align: left}
+			}
+			ts.placeLines(lines)
+
+			if len(ts.pages) != tt.wantPages {
+				t.Fatalf("placeLines() used %d pages, want %d", len(ts.pages), tt.wantPages)
+			}
+			first := strings.Count(ts.pages[0].content.String(), "Tj")
+			if first != tt.wantFirst {
+				t.Errorf("placeLines() set %d lines on the first page, want %d", first, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestMirroredMargins(t *testing.T) {
+	ts := testTypesetter()
+	ts.settings.inside, ts.settings.outside = 30, 10
+	if ts.left(1) != 30 || ts.left(2) != 10 {
+		t.Errorf("left() = %v, %v, want the inside margin on the left of recto pages", ts.left(1), ts.left(2))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	// without a system typeface the bundled one is embedded
+	installed := systemFamilies
+	systemFamilies = nil
+	t.Cleanup(func() { systemFamilies = installed })
+
+	book := &manuscript.Book{
+		Title:          "Test Book",
+		Authors:        []string{"Author One"},
+		SceneSeparator: "\\* \\* \\*",
+		Dedication:     markdown.Parse("To my family"),
+		Chapters: []*manuscript.Chapter{
+			{Title: "Chapter 1", Scenes: []*manuscript.Scene{
+				{Document: markdown.Parse(strings.Repeat("Once upon a time there was a *story* which ran on for pages. ", 80))},
+				{Document: markdown.Parse("Second scene.")},
+			}},
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "book.pdf")
+	err := Write(book, config.PDFConfig{DropCaps: true}, filename)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+
+	for _, part := range []string{"%PDF-1.7", "/FontFile2", "/ToUnicode", "DejaVuSerif-Bold", "/MediaBox [0 0 432 648]", "%%EOF"} {
+		if !bytes.Contains(data, []byte(part)) {
+			t.Errorf("output should contain %q", part)
+		}
+	}
+
+	// every entry of the cross-reference table should point at its object
+	xref := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data, -1)
+	for idx, match := range xref {
+		offset, _ := strconv.Atoi(string(match[1]))
+		header := strconv.Itoa(idx+1) + " 0 obj"
+		if !bytes.HasPrefix(data[offset:], []byte(header)) {
+			t.Errorf("xref entry %d does not point at %q", idx+1, header)
+		}
+	}
+
+	// title, blank, dedication, blank, and at least two pages of the chapter
+	count := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(data)
+	if pages, _ := strconv.Atoi(string(count[1])); pages < 6 {
+		t.Errorf("Write() produced %d pages, want at least 6", pages)
+	}
+}
+
+func TestSubset(t *testing.T) {
+	f, err := parseFont(bundledRegular)
+	if err != nil {
+		t.Fatalf("parseFont() error = %v", err)
+	}
+	f.encode("Hello")
+
+	subset, err := parseFont(f.subset())
+	if err != nil {
+		t.Fatalf("subset font does not parse: %v", err)
+	}
+	if subset.advances[f.cmap['H']] != f.advances[f.cmap['H']] {
+		t.Error("subset font should keep the metrics of the used glyphs")
+	}
+}
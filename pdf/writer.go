@@ -0,0 +1,167 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// writer is a struct that represents a PDF file as its objects are written
+type writer struct {
+	buf     *bytes.Buffer
+	offsets []int
+}
+
+// newWriter returns a writer which has written the file header.
+func newWriter() *writer {
+	w := &writer{buf: &bytes.Buffer{}}
+	// the comment of high bytes marks the file as binary for transfer programs
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+// reserve allocates the number of an object which is written later.
+func (w *writer) reserve() int {
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+// object writes the object with the given number.
+func (w *writer) object(id int, body string) {
+	w.offsets[id-1] = w.buf.Len()
+	w.buf.WriteString(strconv.Itoa(id) + " 0 obj\n" + body + "\nendobj\n")
+}
+
+// stream writes a compressed stream object with the given number. The dictionary
+// entries are written without the surrounding brackets.
+func (w *writer) stream(id int, dict string, data []byte) {
+	compressed := &bytes.Buffer{}
+	z := zlib.NewWriter(compressed)
+	_, _ = z.Write(data)
+	_ = z.Close()
+
+	w.offsets[id-1] = w.buf.Len()
+	w.buf.WriteString(strconv.Itoa(id) + " 0 obj\n")
+	w.buf.WriteString("<< " + dict + " /Filter /FlateDecode /Length " + strconv.Itoa(compressed.Len()) + " >>\nstream\n")
+	w.buf.Write(compressed.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// finish writes the cross-reference table and trailer and returns the file.
+func (w *writer) finish(root int, info int) []byte {
+	xref := w.buf.Len()
+	w.buf.WriteString("xref\n0 " + strconv.Itoa(len(w.offsets)+1) + "\n")
+	w.buf.WriteString("0000000000 65535 f \n")
+	for _, offset := range w.offsets {
+		w.buf.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	w.buf.WriteString("trailer\n")
+	w.buf.WriteString("<< /Size " + strconv.Itoa(len(w.offsets)+1) + " /Root " + ref(root) + " /Info " + ref(info) + " >>\n")
+	w.buf.WriteString("startxref\n" + strconv.Itoa(xref) + "\n%%EOF\n")
+	return w.buf.Bytes()
+}
+
+// writeFont writes the objects which embed the font as a composite font, and returns
+// the number of the font dictionary.
+func (w *writer) writeFont(f *font) int {
+	fontID, cidID, descriptorID := w.reserve(), w.reserve(), w.reserve()
+	fileID, unicodeID := w.reserve(), w.reserve()
+
+	glyphs := f.usedGlyphs()
+	name := subsetTag(f, glyphs) + "+" + f.name
+
+	w.object(fontID, "<< /Type /Font /Subtype /Type0 /BaseFont /"+name+" /Encoding /Identity-H"+
+		" /DescendantFonts ["+ref(cidID)+"] /ToUnicode "+ref(unicodeID)+" >>")
+
+	widths := &strings.Builder{}
+	for _, g := range glyphs {
+		widths.WriteString(strconv.Itoa(int(g)) + " [" + strconv.Itoa(f.scale(float64(f.advances[g]))) + "] ")
+	}
+	w.object(cidID, "<< /Type /Font /Subtype /CIDFontType2 /BaseFont /"+name+
+		" /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >>"+
+		" /FontDescriptor "+ref(descriptorID)+" /CIDToGIDMap /Identity /W ["+widths.String()+"] >>")
+
+	flags := 32
+	if f.italicAngle != 0 {
+		flags |= 64
+	}
+	w.object(descriptorID, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%d %d %d %d]"+
+		" /ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %s >>",
+		name, flags, f.scale(float64(f.bbox[0])), f.scale(float64(f.bbox[1])), f.scale(float64(f.bbox[2])), f.scale(float64(f.bbox[3])),
+		number(f.italicAngle), f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), ref(fileID)))
+
+	data := f.subset()
+	w.stream(fileID, "/Length1 "+strconv.Itoa(len(data)), data)
+	w.stream(unicodeID, "", []byte(toUnicode(f, glyphs)))
+
+	return fontID
+}
+
+// toUnicode returns a CMap which maps the glyphs back to text, so the text of the
+// document can be searched and copied.
+func toUnicode(f *font, glyphs []uint16) string {
+	builder := &strings.Builder{}
+	builder.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	builder.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	builder.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	builder.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(glyphs); start += 100 {
+		end := min(start+100, len(glyphs))
+		builder.WriteString(strconv.Itoa(end-start) + " beginbfchar\n")
+		for _, g := range glyphs[start:end] {
+			builder.WriteString("<" + hex4(int(g)) + "> <")
+			for _, unit := range utf16.Encode([]rune{f.used[g]}) {
+				builder.WriteString(hex4(int(unit)))
+			}
+			builder.WriteString(">\n")
+		}
+		builder.WriteString("endbfchar\n")
+	}
+	builder.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return builder.String()
+}
+
+// subsetTag returns the six letter tag which identifies a subset of the font.
+func subsetTag(f *font, glyphs []uint16) string {
+	h := sha1.New()
+	h.Write([]byte(f.name))
+	for _, g := range glyphs {
+		h.Write([]byte{byte(g >> 8), byte(g)})
+	}
+	sum := h.Sum(nil)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	return string(tag)
+}
+
+// text returns the string as a PDF text string.
+func text(s string) string {
+	builder := &strings.Builder{}
+	builder.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		builder.WriteString(hex4(int(unit)))
+	}
+	builder.WriteString(">")
+	return builder.String()
+}
+
+// ref returns an indirect reference to the object with the given number.
+func ref(id int) string {
+	return strconv.Itoa(id) + " 0 R"
+}
+
+// number formats the value with at most two decimal places.
+func number(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,287 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/htmlbook"
+	"github.com/nivthefox/inkwell/processor"
+	"github.com/nivthefox/inkwell/watch"
+)
+
+// script reloads the page when the book is rebuilt. Instead of reloading the whole page, it
+// swaps in the new body and scrolls to the first block which changed, so the paragraph being
+// edited stays in view.
+const script = `<a href="/stats" style="position:fixed;top:0.5em;right:0.5em;font:0.8rem sans-serif;opacity:0.6">Stats</a>
+<script>
+(function () {
+  var events = new EventSource("/events");
+  events.addEventListener("reload", function () {
+    fetch(location.href, {cache: "no-store"}).then(function (response) {
+      return response.text();
+    }).then(function (text) {
+      var next = new DOMParser().parseFromString(text, "text/html");
+      var before = document.body.children;
+      var after = next.body.children;
+      var changed = -1;
+      for (var i = 0; i < after.length; i++) {
+        if (after[i].tagName === "SCRIPT") continue;
+        if (i >= before.length || before[i].outerHTML !== after[i].outerHTML) {
+          changed = i;
+          break;
+        }
+      }
+      if (changed < 0) return;
+
+      var scroll = window.scrollY;
+      document.title = next.title;
+      document.head.innerHTML = next.head.innerHTML;
+      document.body.replaceChildren.apply(document.body, Array.prototype.filter.call(after, function (el) {
+        return el.tagName !== "SCRIPT";
+      }));
+      window.scrollTo(0, scroll);
+
+      var block = document.body.children[changed];
+      if (block) {
+        var box = block.getBoundingClientRect();
+        if (box.bottom < 0 || box.top > window.innerHeight) {
+          block.scrollIntoView({block: "center"});
+        }
+      }
+    });
+  });
+})();
+</script>
+`
+
+// Server is a struct that represents a preview of the book, compiled in memory and served
+// over HTTP
+type Server struct {
+	path string
+
+	mu    sync.RWMutex
+	cfg   *config.InkwellConfig
+	pages map[string]string
+	stats string
+	err   error
+
+	clients map[chan struct{}]bool
+}
+
+// New returns a server for the book configured in the file at the given path, and builds it.
+func New(path string) *Server {
+	s := &Server{path: path, clients: map[chan struct{}]bool{}}
+	s.Build()
+	return s
+}
+
+// Build reloads the config and compiles the book, and tells every connected browser to reload.
+// Errors are kept and shown in place of the pages until the next successful build.
+func (s *Server) Build() error {
+	pages, stats, cfg, err := s.compile()
+
+	s.mu.Lock()
+	if cfg != nil {
+		s.cfg = cfg
+	}
+	s.err = err
+	if err == nil {
+		s.pages = pages
+		s.stats = stats
+	}
+	for client := range s.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+	s.mu.Unlock()
+
+	return err
+}
+
+// compile renders the pages of the book and its statistics.
+func (s *Server) compile() (map[string]string, string, *config.InkwellConfig, error) {
+	cfg, err := config.NewInkwellConfig(s.path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	book, summary, err := processor.Compile(*cfg)
+	if err != nil {
+		return nil, "", cfg, err
+	}
+
+	css, err := htmlbook.Stylesheet(cfg.HTML)
+	if err != nil {
+		return nil, "", cfg, err
+	}
+
+	pages := map[string]string{}
+	for _, file := range htmlbook.Site(processor.TypesetBook(book, cfg.TypographyFor(cfg.HTML.Typography)), css) {
+		pages[file.Name] = file.Content
+	}
+
+	return pages, statistics(cfg.Title, summary), cfg, nil
+}
+
+// Watch rebuilds the book whenever the config or one of its source files changes, until the
+// context is done.
+func (s *Server) Watch(ctx context.Context, interval time.Duration) {
+	poller := watch.NewPoller(s.files())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if len(poller.Changes()) == 0 {
+				continue
+			}
+			_ = s.Build()
+			// the config may list different files now
+			poller = watch.NewPoller(s.files())
+		}
+	}
+}
+
+// files returns the config, the source files it lists and the directories they were found in.
+func (s *Server) files() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := []string{s.path}
+	if s.cfg != nil {
+		files = append(files, s.cfg.SourceFiles()...)
+		files = append(files, s.cfg.Directories()...)
+	}
+	return files
+}
+
+// ServeHTTP serves the index, a page per section and chapter, the statistics of the book and
+// the stream of reload events.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "events" {
+		s.events(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	err := s.err
+	content, ok := s.pages[name]
+	switch name {
+	case "", "index.html":
+		content, ok = s.pages["index.html"]
+	case "stats":
+		content, ok = s.stats, s.stats != ""
+	}
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	switch {
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		content = "<!DOCTYPE html>\n<html>\n<head>\n<title>Build failed</title>\n</head>\n<body>\n" +
+			"<h1>Build failed</h1>\n<pre>" + html.EscapeString(err.Error()) + "</pre>\n</body>\n</html>\n"
+	case !ok:
+		w.WriteHeader(http.StatusNotFound)
+		content = "<!DOCTYPE html>\n<html>\n<head>\n<title>Not found</title>\n</head>\n<body>\n" +
+			"<h1>Not found</h1>\n<p><a href=\"/\">Contents</a></p>\n</body>\n</html>\n"
+	}
+
+	_, _ = w.Write([]byte(strings.Replace(content, "</body>", script+"</body>", 1)))
+}
+
+// events streams a reload event to the browser after every build.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[client] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client:
+			_, _ = fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// statistics renders the summary of the book as a table.
+func statistics(title string, summary *processor.BookSummary) string {
+	builder := &strings.Builder{}
+	builder.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	builder.WriteString("<title>Statistics - " + html.EscapeString(title) + "</title>\n")
+	builder.WriteString("<style>body { font-family: sans-serif; margin: 2em auto; max-width: 40em; } " +
+		"table { border-collapse: collapse; width: 100%; } th, td { padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; } " +
+		"td.number, th.number { text-align: right; }</style>\n")
+	builder.WriteString("</head>\n<body>\n")
+	builder.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	builder.WriteString("<p><a href=\"/\">Contents</a></p>\n")
+	builder.WriteString("<table>\n<tr><th>Chapter</th><th class=\"number\">Scenes</th><th class=\"number\">Words</th><th class=\"number\">Characters</th></tr>\n")
+	for _, section := range summary.SectionSummary {
+		builder.WriteString("<tr><td>" + html.EscapeString(section.Title) + " <small>(" + html.EscapeString(section.Placement) + ")</small></td><td></td>")
+		builder.WriteString(`<td class="number">` + strconv.Itoa(section.Words) + "</td>")
+		builder.WriteString(`<td class="number">` + strconv.Itoa(section.Characters) + "</td></tr>\n")
+	}
+	for _, chapter := range summary.ChapterSummary {
+		builder.WriteString("<tr><td>" + html.EscapeString(chapter.Title) + "</td>")
+		builder.WriteString(`<td class="number">` + strconv.Itoa(len(chapter.SceneSummary)) + "</td>")
+		builder.WriteString(`<td class="number">` + strconv.Itoa(chapter.Words) + "</td>")
+		builder.WriteString(`<td class="number">` + strconv.Itoa(chapter.Characters) + "</td></tr>\n")
+	}
+	builder.WriteString("<tr><th>Total</th><th></th>")
+	builder.WriteString(`<th class="number">` + strconv.Itoa(summary.Words) + "</th>")
+	builder.WriteString(`<th class="number">` + strconv.Itoa(summary.Characters) + "</th></tr>\n")
+	builder.WriteString("</table>\n")
+
+	// parts are rolled up in a table of their own, with nested parts indented
+	if len(summary.PartSummary) > 0 {
+		builder.WriteString("<table>\n<tr><th>Part</th><th class=\"number\">Chapters</th><th class=\"number\">Words</th><th class=\"number\">Characters</th></tr>\n")
+		var parts func(summaries []processor.PartSummary, depth int)
+		parts = func(summaries []processor.PartSummary, depth int) {
+			for _, part := range summaries {
+				builder.WriteString(`<tr><td style="padding-left: ` + strconv.Itoa(1+depth*2) + `em">` + html.EscapeString(part.Title) + "</td>")
+				builder.WriteString(`<td class="number">` + strconv.Itoa(part.Chapters) + "</td>")
+				builder.WriteString(`<td class="number">` + strconv.Itoa(part.Words) + "</td>")
+				builder.WriteString(`<td class="number">` + strconv.Itoa(part.Characters) + "</td></tr>\n")
+				parts(part.Parts, depth+1)
+			}
+		}
+		parts(summary.PartSummary, 0)
+		builder.WriteString("</table>\n")
+	}
+
+	builder.WriteString("</body>\n</html>\n")
+	return builder.String()
+}
@@ -0,0 +1,131 @@
+package preview
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBook writes a config with two chapters and returns its path.
+func writeBook(t *testing.T) string {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "one.md"), []byte("The first chapter."), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "two.md"), []byte("The second chapter."), 0644)
+
+	cfg := "title: Test Book\nauthors:\n  - Author One\n" +
+		"output_filename: " + filepath.Join(dir, "book.md") + "\n" +
+		"chapters:\n" +
+		"  - title: Chapter 1\n    scenes:\n      - files:\n          - " + filepath.Join(dir, "one.md") + "\n" +
+		"  - title: Chapter 2\n    scenes:\n      - files:\n          - " + filepath.Join(dir, "two.md") + "\n"
+	path := filepath.Join(dir, ".inkwell.yaml")
+	_ = os.WriteFile(path, []byte(cfg), 0644)
+	return path
+}
+
+func get(t *testing.T, server *Server, path string) (int, string) {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestServeHTTP(t *testing.T) {
+	path := writeBook(t)
+	server := New(path)
+
+	tests := []struct {
+		path     string
+		code     int
+		expected string
+	}{
+		{path: "/", code: http.StatusOK, expected: `<a href="chapter-2.html">Chapter 2</a>`},
+		{path: "/chapter-1.html", code: http.StatusOK, expected: "The first chapter."},
+		{path: "/chapter-2.html", code: http.StatusOK, expected: "The second chapter."},
+		{path: "/stats", code: http.StatusOK, expected: "<td>Chapter 2</td>"},
+		{path: "/missing.html", code: http.StatusNotFound, expected: "Not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			code, body := get(t, server, tt.path)
+			if code != tt.code {
+				t.Errorf("GET %s returned %d, want %d", tt.path, code, tt.code)
+			}
+			if !strings.Contains(body, tt.expected) {
+				t.Errorf("GET %s should contain %q", tt.path, tt.expected)
+			}
+			if !strings.Contains(body, `new EventSource("/events")`) {
+				t.Errorf("GET %s should include the reload script", tt.path)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "book.md")); err == nil {
+		t.Error("the preview should not write the output files")
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	path := writeBook(t)
+	server := New(path)
+
+	_ = os.Remove(filepath.Join(filepath.Dir(path), "two.md"))
+	if err := server.Build(); err == nil {
+		t.Fatal("Build() should fail when a source file is missing")
+	}
+
+	code, body := get(t, server, "/chapter-1.html")
+	if code != http.StatusInternalServerError || !strings.Contains(body, "Build failed") {
+		t.Errorf("GET returned %d, want the build error", code)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	path := writeBook(t)
+	server := New(path)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/events")
+	if err != nil {
+		t.Fatalf("GET /events error = %v", err)
+	}
+	defer response.Body.Close()
+
+	reader := bufio.NewReader(response.Body)
+	// wait until the client is connected before building
+	line, _ := reader.ReadString('\n')
+	if !strings.HasPrefix(line, ": connected") {
+		t.Fatalf("first line = %q, want the connection comment", line)
+	}
+
+	_ = server.Build()
+
+	done := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				done <- ""
+				return
+			}
+			if strings.HasPrefix(line, "event:") {
+				done <- strings.TrimSpace(line)
+				return
+			}
+		}
+	}()
+
+	select {
+	case event := <-done:
+		if event != "event: reload" {
+			t.Errorf("event = %q, want a reload", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("no reload event after a build")
+	}
+}
@@ -0,0 +1,240 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// defaultCommentMarkers are the markers which start a comment line when the config gives none
+var defaultCommentMarkers = []string{"TODO:"}
+
+// comment matches an Obsidian comment or an HTML comment
+var comment = regexp.MustCompile(`(?s)%%(.*?)%%|<!--(.*?)-->`)
+
+// markComments rewrites the Obsidian and HTML comments in the source, and the lines which start
+// with one of the markers, as CriticMarkup comments, so that every comment is parsed alike.
+// Comments are joined onto a single line, and fenced code blocks are left as they are. Along
+// with the result, it returns the line of the source on which each line of the result starts,
+// and then the line past the end of the source.
+func markComments(source string, markers []string) (string, []int) {
+	if len(markers) == 0 {
+		markers = defaultCommentMarkers
+	}
+
+	builder := &strings.Builder{}
+	origin := 1
+	origins := []int{origin}
+	// keep writes text of the source as it is, noting the line each of its lines starts on
+	keep := func(out *strings.Builder, text string) {
+		out.WriteString(text)
+		for idx := 0; idx < strings.Count(text, "\n"); idx++ {
+			origin++
+			origins = append(origins, origin)
+		}
+	}
+
+	text := &strings.Builder{}
+	flush := func() {
+		content := text.String()
+		joined := &strings.Builder{}
+		last := 0
+		for _, match := range comment.FindAllStringSubmatchIndex(content, -1) {
+			keep(joined, content[last:match[0]])
+			start, end := match[2], match[3]
+			if start < 0 {
+				start, end = match[4], match[5]
+			}
+			note := content[start:end]
+			joined.WriteString("{>>" + strings.Join(strings.Fields(note), " ") + "<<}")
+			origin += strings.Count(content[match[0]:match[1]], "\n")
+			last = match[1]
+		}
+		keep(joined, content[last:])
+
+		lines := strings.SplitAfter(joined.String(), "\n")
+		for idx, line := range lines {
+			trimmed := strings.TrimSpace(line)
+			for _, marker := range markers {
+				if marker != "" && strings.HasPrefix(trimmed, marker) {
+					lines[idx] = "{>>" + trimmed + "<<}" + line[len(strings.TrimRight(line, "\r\n")):]
+					break
+				}
+			}
+		}
+		builder.WriteString(strings.Join(lines, ""))
+		text.Reset()
+	}
+
+	fence := ""
+	for _, line := range strings.SplitAfter(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			keep(builder, line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+			keep(builder, line)
+		default:
+			text.WriteString(line)
+		}
+	}
+	flush()
+
+	return builder.String(), append(origins, origin+1)
+}
+
+// sourceLines moves the lines of the blocks of the document, which was parsed from the result
+// of markComments, back to the lines of the source they came from.
+func sourceLines(doc *markdown.Node, origins []int) {
+	markdown.Walk(doc, func(n *markdown.Node) bool {
+		if n.Line > 0 && n.EndLine < len(origins) {
+			n.Line, n.EndLine = origins[n.Line-1], origins[n.EndLine]-1
+		}
+		return true
+	})
+}
+
+// calloutComments replaces the Obsidian callouts of the comment type in the document with
+// comments, which hold the title of the callout and then its text.
+func calloutComments(doc *markdown.Node) {
+	markdown.Walk(doc, func(n *markdown.Node) bool {
+		for idx, child := range n.Children {
+			if child.Kind != markdown.BlockQuote || len(child.Children) == 0 || child.Children[0].Kind != markdown.Paragraph {
+				continue
+			}
+			first := child.Children[0]
+			split := len(first.Children)
+			for pos, inline := range first.Children {
+				if inline.Kind == markdown.SoftBreak || inline.Kind == markdown.HardBreak {
+					split = pos
+					break
+				}
+			}
+			title := markdown.PlainText(&markdown.Node{Kind: markdown.Paragraph, Children: first.Children[:split]})
+			if !strings.HasPrefix(strings.ToLower(title), "[!comment]") {
+				continue
+			}
+			title = strings.TrimSpace(title[len("[!comment]"):])
+			rest := append([]*markdown.Node{{Kind: markdown.Paragraph, Children: first.Children[split:]}}, child.Children[1:]...)
+			text := strings.Join(strings.Fields(markdown.PlainText(&markdown.Node{Kind: markdown.BlockQuote, Children: rest})), " ")
+			if title != "" && text != "" {
+				text = title + ": " + text
+			} else if title != "" {
+				text = title
+			}
+			comment := &markdown.Node{Kind: markdown.Comment, Literal: text}
+			n.Children[idx] = &markdown.Node{Kind: markdown.Paragraph, Line: child.Line, EndLine: child.EndLine, Children: []*markdown.Node{comment}}
+		}
+		return true
+	})
+}
+
+// applyComments removes the comments from the document and settles its changes, unless the
+// book is built for review.
+func applyComments(doc *markdown.Node, comments config.CommentsConfig) {
+	if !comments.Review {
+		resolveChanges(doc, comments.Changes == "reject")
+	}
+}
+
+// withoutComments returns a copy of the document as it reads once its comments are removed
+// and its changes settled, from which its words are counted.
+func withoutComments(doc *markdown.Node, comments config.CommentsConfig) *markdown.Node {
+	clean := doc.Clone()
+	resolveChanges(clean, comments.Changes == "reject")
+	return clean
+}
+
+// resolveChanges removes the comments from the document, and accepts or rejects its changes:
+// an accepted insertion keeps its text and an accepted deletion loses it, and a rejected change
+// does the opposite. The space left behind is tidied, and paragraphs which are left empty are
+// removed.
+func resolveChanges(n *markdown.Node, reject bool) bool {
+	var children []*markdown.Node
+	changed := false
+	for _, child := range n.Children {
+		switch {
+		case child.Kind == markdown.Comment,
+			child.Kind == markdown.Insertion && reject,
+			child.Kind == markdown.Deletion && !reject:
+			changed = true
+		case child.Kind == markdown.Insertion, child.Kind == markdown.Deletion:
+			resolveChanges(child, reject)
+			children = append(children, child.Children...)
+			changed = true
+		default:
+			if resolveChanges(child, reject) && !child.IsBlock() {
+				changed = true
+			}
+			if (child.Kind == markdown.Paragraph || child.Kind == markdown.Heading) && len(child.Children) == 0 {
+				continue
+			}
+			children = append(children, child)
+		}
+	}
+
+	if changed && !n.IsBlock() || changed && (n.Kind == markdown.Paragraph || n.Kind == markdown.Heading) {
+		children = tidyInlines(children)
+	}
+	n.Children = children
+	return changed
+}
+
+// tidyInlines removes the space left behind by removed text: doubled spaces, spaces before
+// punctuation, breaks at the start or end of the content, and breaks which follow one another.
+func tidyInlines(nodes []*markdown.Node) []*markdown.Node {
+	var tidy []*markdown.Node
+	space := true
+	for _, n := range nodes {
+		switch n.Kind {
+		case markdown.SoftBreak, markdown.HardBreak:
+			if space {
+				continue
+			}
+			trimTrailingSpace(tidy)
+			space = true
+		case markdown.Text:
+			if space {
+				n.Literal = strings.TrimLeft(n.Literal, " ")
+				n.Raw = strings.TrimLeft(n.Raw, " ")
+			}
+			if n.Literal == "" {
+				continue
+			}
+			// text runs on from other text only where something was removed between them
+			if len(tidy) > 0 && tidy[len(tidy)-1].Kind == markdown.Text && strings.ContainsAny(n.Literal[:1], ".,;:!?)") {
+				trimTrailingSpace(tidy)
+			}
+			space = strings.HasSuffix(n.Literal, " ")
+		default:
+			space = false
+		}
+		tidy = append(tidy, n)
+	}
+
+	for len(tidy) > 0 && (tidy[len(tidy)-1].Kind == markdown.SoftBreak || tidy[len(tidy)-1].Kind == markdown.HardBreak) {
+		tidy = tidy[:len(tidy)-1]
+	}
+	trimTrailingSpace(tidy)
+	if len(tidy) > 0 && tidy[len(tidy)-1].Kind == markdown.Text && tidy[len(tidy)-1].Literal == "" {
+		tidy = tidy[:len(tidy)-1]
+	}
+	return tidy
+}
+
+// trimTrailingSpace removes the spaces at the end of the last node, if it is text.
+func trimTrailingSpace(nodes []*markdown.Node) {
+	if len(nodes) == 0 || nodes[len(nodes)-1].Kind != markdown.Text {
+		return
+	}
+	last := nodes[len(nodes)-1]
+	last.Literal = strings.TrimRight(last.Literal, " ")
+	last.Raw = strings.TrimRight(last.Raw, " ")
+}
@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func TestMarkComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		markers  []string
+		input    string
+		expected string
+	}{
+		{
+			name:     "obsidian comments",
+			input:    "She ran.%% too fast? %%\n\n%%\nCheck the\nmap.\n%%",
+			expected: "She ran.{>>too fast?<<}\n\n{>>Check the map.<<}",
+		},
+		{
+			name:     "html comments",
+			input:    "She ran.<!-- too fast? -->",
+			expected: "She ran.{>>too fast?<<}",
+		},
+		{
+			name:     "todo lines",
+			input:    "She ran.\n  TODO: fix the pacing\nThen stopped.",
+			expected: "She ran.\n{>>TODO: fix the pacing<<}\nThen stopped.",
+		},
+		{
+			name:     "custom markers",
+			markers:  []string{"FIXME:", "NOTE:"},
+			input:    "TODO: keep me\nNOTE: drop me",
+			expected: "TODO: keep me\n{>>NOTE: drop me<<}",
+		},
+		{
+			name:     "fenced code",
+			input:    "```\n%% code %%\nTODO: code\n```\n%% prose %%",
+			expected: "```\n%% code %%\nTODO: code\n```\n{>>prose<<}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := markComments(tt.input, tt.markers)
+			if result != tt.expected {
+				t.Errorf("markComments(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveChanges(t *testing.T) {
+	input := "It was {++very ++}cold.{>>Check<<} She {~~ran~>walked~~} home {--slowly--}.\n\n{>>A note of its own<<}\n\nTODO: a line\nThe end."
+
+	marked, _ := markComments(input, nil)
+	accepted := markdown.Parse(marked)
+	resolveChanges(accepted, false)
+	expected := "It was very cold. She walked home.\n\nThe end.\n"
+	if result := markdown.RenderMarkdown(accepted); result != expected {
+		t.Errorf("resolveChanges() accepted = %q, want %q", result, expected)
+	}
+
+	rejected := markdown.Parse(marked)
+	resolveChanges(rejected, true)
+	expected = "It was cold. She ran home slowly.\n\nThe end.\n"
+	if result := markdown.RenderMarkdown(rejected); result != expected {
+		t.Errorf("resolveChanges() rejected = %q, want %q", result, expected)
+	}
+}
+
+func TestProcessSceneComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scene.md")
+	content := "Mara waited{>>why?<<} {++in the rain++}.\n\n%% She should be angrier here. %%\n\nTODO: name the ship"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		comments config.CommentsConfig
+		expected string
+		words    int
+	}{
+		{
+			name:     "clean",
+			expected: "Mara waited in the rain.\n",
+			words:    5,
+		},
+		{
+			name:     "rejected",
+			comments: config.CommentsConfig{Changes: "reject"},
+			expected: "Mara waited.\n",
+			words:    2,
+		},
+		{
+			name:     "review",
+			comments: config.CommentsConfig{Review: true},
+			expected: "Mara waited{>>why?<<} {++in the rain++}.\n\n{>>She should be angrier here.<<}\n\n{>>TODO: name the ship<<}\n",
+			words:    5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chapter := &ChapterSummary{}
+			scene, err := ProcessScene(config.SceneConfig{Files: []string{path}}, Options{Comments: tt.comments}, chapter, &manuscript.Chapter{})
+			if err != nil {
+				t.Fatalf("ProcessScene() error = %v", err)
+			}
+			if scene.String() != tt.expected {
+				t.Errorf("ProcessScene() = %q, want %q", scene.String(), tt.expected)
+			}
+			if words := chapter.SceneSummary[0].Words; words != tt.words {
+				t.Errorf("ProcessScene() words = %d, want %d", words, tt.words)
+			}
+		})
+	}
+}
+
+func TestCalloutComments(t *testing.T) {
+	doc := markdown.Parse("She ran.\n\n> [!comment] Sam\n> Too fast?\n> Maybe.\n\n> [!COMMENT]\n> No title.\n\n> [!note] Kept\n> As it is.")
+	calloutComments(doc)
+
+	expected := "She ran.\n\n{>>Sam: Too fast? Maybe.<<}\n\n{>>No title.<<}\n\n> [!note] Kept\n> As it is.\n"
+	if result := markdown.RenderMarkdown(doc); result != expected {
+		t.Errorf("calloutComments() = %q, want %q", result, expected)
+	}
+	if doc.Children[1].Line != 3 || doc.Children[1].EndLine != 5 {
+		t.Errorf("calloutComments() lines = %d-%d, want 3-5", doc.Children[1].Line, doc.Children[1].EndLine)
+	}
+}
+
+func TestOptionsForComments(t *testing.T) {
+	_, err := optionsFor(config.InkwellConfig{Comments: config.CommentsConfig{Changes: "maybe"}})
+	if err == nil || !strings.Contains(err.Error(), "want accept or reject") {
+		t.Errorf("optionsFor() error = %v, want an invalid changes error", err)
+	}
+}
@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+	"gopkg.in/yaml.v3"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// frontMatter removes the YAML front matter from the document and returns its values.
+func frontMatter(doc *markdown.Node) (manuscript.Metadata, error) {
+	meta := manuscript.Metadata{}
+	for idx, child := range doc.Children {
+		if child.Kind != markdown.FrontMatter {
+			continue
+		}
+		doc.Children = append(doc.Children[:idx], doc.Children[idx+1:]...)
+		err := yaml.Unmarshal([]byte(child.Literal), &meta)
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	return meta, nil
+}
+
+// isCut reports whether the front matter marks the file as cut from the book.
+func isCut(meta manuscript.Metadata) bool {
+	return strings.EqualFold(metaString(meta, "status"), "cut")
+}
+
+// mergeMetadata adds the values of the front matter to the metadata of a scene or section.
+// The first file to give a value wins.
+func mergeMetadata(into manuscript.Metadata, meta manuscript.Metadata) {
+	for key, value := range meta {
+		if _, ok := into[key]; !ok {
+			into[key] = value
+		}
+	}
+}
+
+// metaString returns a value from the front matter as text, or an empty string if it is missing.
+func metaString(meta manuscript.Metadata, key string) string {
+	value, ok := meta[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return formatValue(value)
+}
+
+// formatValue formats a front matter value for use in text. Lists are joined with commas.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006-01-02")
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, formatValue(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// expandTemplate replaces each {{key}} placeholder in the template with the value of the key.
+// Missing keys are left empty, and separators left dangling at the end are trimmed.
+func expandTemplate(template string, values manuscript.Metadata) string {
+	expanded := placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		return metaString(values, placeholder.FindStringSubmatch(match)[1])
+	})
+	return strings.TrimRight(strings.TrimSpace(expanded), " :-–—")
+}
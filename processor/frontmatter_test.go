@@ -0,0 +1,167 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func TestFrontMatter(t *testing.T) {
+	doc := markdown.Parse("---\npov: Mara\ntags: [storm, sea]\n---\nThe storm broke.")
+
+	meta, err := frontMatter(doc)
+	if err != nil {
+		t.Fatalf("frontMatter() error = %v", err)
+	}
+	if metaString(meta, "pov") != "Mara" || metaString(meta, "tags") != "storm, sea" {
+		t.Errorf("frontMatter() = %v, want the values of the front matter", meta)
+	}
+	if output := markdown.RenderMarkdown(doc); strings.Contains(output, "pov") {
+		t.Errorf("frontMatter() should remove the front matter from the document, got %q", output)
+	}
+
+	_, err = frontMatter(markdown.Parse("---\n- not a map\n---\ntext"))
+	if err == nil {
+		t.Error("frontMatter() should reject front matter which is not a map")
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	values := manuscript.Metadata{"number": "3", "title": "The Storm", "pov": "Mara"}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{template: "Scene {{number}}: {{title}}", expected: "Scene 3: The Storm"},
+		{template: "{{ pov }}", expected: "Mara"},
+		{template: "{{title}} — {{date}}", expected: "The Storm"},
+		{template: "{{missing}}", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			if result := expandTemplate(tt.template, values); result != tt.expected {
+				t.Errorf("expandTemplate(%q) = %q, want %q", tt.template, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessChapterFrontMatter(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"one.md": "---\ntitle: Landfall\nsynopsis: Mara reaches the island.\npov: Mara\n---\nThe boat ran aground.",
+		"cut.md": "---\nstatus: Cut\n---\nA scene nobody will read.",
+		"two.md": "---\nnumber: 2a\n---\nThe tide came in.",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	chapter := config.ChapterConfig{
+		Title:        "Chapter 1",
+		SceneHeading: "{{number}}. {{title}} ({{pov}})",
+		Scenes: []config.SceneConfig{
+			{Files: []string{filepath.Join(tempDir, "one.md")}},
+			{Files: []string{filepath.Join(tempDir, "cut.md")}},
+			{Files: []string{filepath.Join(tempDir, "two.md")}},
+		},
+	}
+
+	summary := &BookSummary{}
+	compiled := &manuscript.Book{}
+	result, err := ProcessChapter(chapter, "", Options{}, summary, compiled)
+	if err != nil {
+		t.Fatalf("ProcessChapter() error = %v", err)
+	}
+
+	output := result.String()
+	if strings.Contains(output, "nobody") || strings.Contains(output, "synopsis") {
+		t.Errorf("ProcessChapter() should skip cut files and strip front matter, got %q", output)
+	}
+	if !strings.Contains(output, "### 1. Landfall (Mara)\n\nThe boat ran aground.") {
+		t.Errorf("ProcessChapter() should add the scene heading, got %q", output)
+	}
+	if strings.Count(output, "\\* \\* \\*") != 1 {
+		t.Errorf("ProcessChapter() should separate the two remaining scenes once, got %q", output)
+	}
+
+	scenes := summary.ChapterSummary[0].SceneSummary
+	if len(scenes) != 2 {
+		t.Fatalf("ProcessChapter() summarized %d scenes, want 2", len(scenes))
+	}
+	if scenes[0].Title != "Landfall" || scenes[0].Synopsis != "Mara reaches the island." || scenes[1].Number != "2a" {
+		t.Errorf("scene summaries = %+v, want the title, synopsis and number from the front matter", scenes)
+	}
+
+	first := compiled.Chapters[0].Scenes[0]
+	if first.Document.Children[0].Kind != markdown.Heading || metaString(first.Metadata, "pov") != "Mara" {
+		t.Error("compiled scene should start with its heading and keep its front matter")
+	}
+}
+
+func TestProcessBookCutChapter(t *testing.T) {
+	tempDir := t.TempDir()
+	kept := filepath.Join(tempDir, "kept.md")
+	cut := filepath.Join(tempDir, "cut.md")
+	if err := os.WriteFile(kept, []byte("The boat ran aground."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(cut, []byte("---\nstatus: cut\n---\nA scene nobody will read."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	summaryFile := filepath.Join(tempDir, "summary.yaml")
+	cfg := config.InkwellConfig{
+		OutputFilename:  config.OutputFilename(filepath.Join(tempDir, "book.md")),
+		SummaryFilename: config.OutputFilename(summaryFile),
+		Chapters: []config.ChapterConfig{
+			{Title: "Chapter 1", Scenes: []config.SceneConfig{{Files: []string{kept}}}},
+			{Title: "Chapter 2", Scenes: []config.SceneConfig{{Files: []string{cut}}, {Files: []string{cut}}}},
+		},
+	}
+	if err := ProcessBook(cfg); err != nil {
+		t.Fatalf("ProcessBook() error = %v", err)
+	}
+
+	_, summary, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if _, err := summary.String(); err != nil {
+		t.Fatalf("BookSummary.String() error = %v", err)
+	}
+	if len(summary.ChapterSummary[1].SceneSummary) != 0 || summary.ChapterSummary[1].Average != 0 {
+		t.Errorf("summary of the cut chapter = %+v, want no scenes and no average", summary.ChapterSummary[1])
+	}
+}
+
+func TestProcessSectionFrontMatter(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "preface.md")
+	err := os.WriteFile(file, []byte("---\ntitle: A Note on the Text\n---\nSome words."), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	compiled := &manuscript.Book{}
+	result, err := ProcessSection(config.SectionConfig{Title: "Preface", Files: []string{file}}, Options{}, &BookSummary{}, compiled)
+	if err != nil {
+		t.Fatalf("ProcessSection() error = %v", err)
+	}
+	if result.String() != "# A Note on the Text\nSome words.\n" {
+		t.Errorf("ProcessSection() = %q, want the title from the front matter", result.String())
+	}
+	if compiled.Sections[0].Title != "A Note on the Text" {
+		t.Errorf("compiled section title = %q", compiled.Sections[0].Title)
+	}
+}
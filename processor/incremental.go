@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"path/filepath"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+// Cache is a struct that represents the source files of a book as they were parsed, kept from
+// one build to the next so that a rebuild only reads and parses the files which changed. Each
+// file keeps the notes embedded in it, so that a change to a note forgets the files which
+// embed it too. The source map of the last build is kept as well, so that a rebuild which
+// writes only some of the outputs still maps the others.
+type Cache struct {
+	files   map[string]*sourceFile
+	sources *SourceMap
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{files: map[string]*sourceFile{}}
+}
+
+// get returns a copy of the parsed file at the path, or nil if it is not in the cache. The
+// copy has a document of its own, which may be changed as the book is built.
+func (c *Cache) get(path string) *sourceFile {
+	if c == nil {
+		return nil
+	}
+	source, ok := c.files[filepath.Clean(path)]
+	if !ok {
+		return nil
+	}
+	copied := *source
+	copied.document = source.document.Clone()
+	return &copied
+}
+
+// put keeps a copy of the parsed file at the path.
+func (c *Cache) put(path string, source *sourceFile) {
+	if c == nil {
+		return
+	}
+	copied := *source
+	copied.document = source.document.Clone()
+	c.files[filepath.Clean(path)] = &copied
+}
+
+// forget removes the changed files from the cache, along with the files which embed them, and
+// returns the files which embed them.
+func (c *Cache) forget(changed []string) []string {
+	if c == nil {
+		return nil
+	}
+	paths := cleanPaths(changed)
+	var embedding []string
+	for path, source := range c.files {
+		if paths[path] {
+			delete(c.files, path)
+		} else if includesAny(source.embeds, paths) {
+			delete(c.files, path)
+			embedding = append(embedding, path)
+		}
+	}
+	return embedding
+}
+
+// ProcessCached builds the book as ProcessBook does, but takes the parsed source files from
+// the cache where it can, and keeps those it parses there for the next build.
+func ProcessCached(config config.InkwellConfig, cache *Cache) error {
+	return processBook(config, cache)
+}
+
+// ProcessChanges rebuilds the book after the given source files have changed. Only the
+// changed files, and the files which embed them, are parsed again; the others are taken from
+// the cache. The outputs of the book are always rebuilt, but only the sections, chapters and
+// scenes which include one of the changed files, or a file which embeds one of them, have
+// their own outputs rewritten.
+func ProcessChanges(config config.InkwellConfig, changed []string, cache *Cache) error {
+	embedding := cache.forget(changed)
+	return processBook(affected(config, append(embedding, changed...)), cache)
+}
+
+// cleanPaths returns the set of the paths, cleaned so that they can be compared.
+func cleanPaths(paths []string) map[string]bool {
+	clean := map[string]bool{}
+	for _, path := range paths {
+		clean[filepath.Clean(path)] = true
+	}
+	return clean
+}
+
+// includesAny reports whether any of the files is in the set of paths.
+func includesAny(files []string, paths map[string]bool) bool {
+	for _, file := range files {
+		if paths[filepath.Clean(file)] {
+			return true
+		}
+	}
+	return false
+}
+
+// affected returns a copy of the config in which the outputs of the sections, chapters
+// and scenes which do not include any of the changed files are removed.
+func affected(cfg config.InkwellConfig, changed []string) config.InkwellConfig {
+	paths := cleanPaths(changed)
+	includes := func(files []string) bool {
+		return includesAny(files, paths)
+	}
+
+	cfg.Sections, cfg.Chapters, cfg.Parts, _ = affectedContents(cfg.Sections, cfg.Chapters, cfg.Parts, includes)
+
+	return cfg
+}
+
+// affectedContents returns copies of the section, chapter and part configs in which the
+// outputs of those which do not include any of the changed files are removed, and whether
+// any of them included one.
+func affectedContents(sections []config.SectionConfig, chapters []config.ChapterConfig, parts []config.PartConfig, includes func([]string) bool) ([]config.SectionConfig, []config.ChapterConfig, []config.PartConfig, bool) {
+	changed := false
+
+	affectedSections := make([]config.SectionConfig, len(sections))
+	for idx, section := range sections {
+		if includes(section.Files) {
+			changed = true
+		} else {
+			section.OutputFilename = ""
+		}
+		affectedSections[idx] = section
+	}
+
+	affectedChapters := make([]config.ChapterConfig, len(chapters))
+	for idx, chapter := range chapters {
+		scenes := make([]config.SceneConfig, len(chapter.Scenes))
+		changedScene := false
+		for sidx, scene := range chapter.Scenes {
+			if includes(scene.Files) {
+				changedScene = true
+			} else {
+				scene.OutputFilename = ""
+			}
+			scenes[sidx] = scene
+		}
+		chapter.Scenes = scenes
+		if changedScene {
+			changed = true
+		} else {
+			chapter.OutputFilename = ""
+		}
+		affectedChapters[idx] = chapter
+	}
+
+	affectedParts := make([]config.PartConfig, len(parts))
+	for idx, part := range parts {
+		var changedPart bool
+		part.Sections, part.Chapters, part.Parts, changedPart = affectedContents(part.Sections, part.Chapters, part.Parts, includes)
+		if changedPart {
+			changed = true
+		} else {
+			part.OutputFilename = ""
+		}
+		affectedParts[idx] = part
+	}
+
+	return affectedSections, affectedChapters, affectedParts, changed
+}
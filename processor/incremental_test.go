@@ -0,0 +1,237 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func TestAffected(t *testing.T) {
+	cfg := config.InkwellConfig{
+		OutputFilename: "book.md",
+		Sections: []config.SectionConfig{
+			{Title: "Prologue", Files: []string{"prologue.md"}, OutputFilename: "prologue-out.md"},
+		},
+		Chapters: []config.ChapterConfig{
+			{Title: "Chapter 1", OutputFilename: "chapter-1.md", Scenes: []config.SceneConfig{
+				{Files: []string{"one/a.md"}, OutputFilename: "one-a.md"},
+				{Files: []string{"one/b.md"}, OutputFilename: "one-b.md"},
+			}},
+			{Title: "Chapter 2", OutputFilename: "chapter-2.md", Scenes: []config.SceneConfig{
+				{Files: []string{"two/a.md"}, OutputFilename: "two-a.md"},
+			}},
+		},
+	}
+
+	result := affected(cfg, []string{"./one/b.md"})
+
+	if result.OutputFilename != "book.md" {
+		t.Error("affected() should keep the book output")
+	}
+	if result.Sections[0].OutputFilename != "" {
+		t.Error("affected() should remove the output of an unchanged section")
+	}
+	if result.Chapters[0].OutputFilename != "chapter-1.md" {
+		t.Error("affected() should keep the output of a chapter with a changed scene")
+	}
+	if result.Chapters[0].Scenes[0].OutputFilename != "" || result.Chapters[0].Scenes[1].OutputFilename != "one-b.md" {
+		t.Error("affected() should only keep the output of the changed scene")
+	}
+	if result.Chapters[1].OutputFilename != "" || result.Chapters[1].Scenes[0].OutputFilename != "" {
+		t.Error("affected() should remove the outputs of an unchanged chapter")
+	}
+
+	if cfg.Chapters[1].OutputFilename != "chapter-2.md" || cfg.Chapters[0].Scenes[0].OutputFilename != "one-a.md" {
+		t.Error("affected() should not modify the original config")
+	}
+}
+
+func TestAffectedParts(t *testing.T) {
+	cfg := config.InkwellConfig{
+		Parts: []config.PartConfig{
+			{Title: "Part One", OutputFilename: "part-1.md", Parts: []config.PartConfig{
+				{Title: "Book One", OutputFilename: "book-1.md", Chapters: []config.ChapterConfig{
+					{Title: "Chapter 1", OutputFilename: "chapter-1.md", Scenes: []config.SceneConfig{{Files: []string{"one.md"}}}},
+				}},
+			}},
+			{Title: "Part Two", OutputFilename: "part-2.md", Sections: []config.SectionConfig{
+				{Title: "Interlude", Files: []string{"interlude.md"}, OutputFilename: "interlude-out.md"},
+			}},
+		},
+	}
+
+	result := affected(cfg, []string{"one.md"})
+
+	if result.Parts[0].OutputFilename != "part-1.md" || result.Parts[0].Parts[0].OutputFilename != "book-1.md" {
+		t.Error("affected() should keep the outputs of the parts holding a changed file")
+	}
+	if result.Parts[0].Parts[0].Chapters[0].OutputFilename != "chapter-1.md" {
+		t.Error("affected() should keep the output of a changed chapter within a part")
+	}
+	if result.Parts[1].OutputFilename != "" || result.Parts[1].Sections[0].OutputFilename != "" {
+		t.Error("affected() should remove the outputs of an unchanged part")
+	}
+	if cfg.Parts[1].OutputFilename != "part-2.md" {
+		t.Error("affected() should not modify the original config")
+	}
+}
+
+func TestProcessChangesCache(t *testing.T) {
+	tempDir := t.TempDir()
+	one := filepath.Join(tempDir, "one.md")
+	two := filepath.Join(tempDir, "two.md")
+	write := func(path string, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	write(one, "The tide turned.")
+	write(two, "The gulls cried.")
+
+	output := filepath.Join(tempDir, "book.md")
+	cfg := config.InkwellConfig{
+		OutputFilename: config.OutputFilename(output),
+		OutputNumbers:  true,
+		SourceMap:      config.OutputFilename(filepath.Join(tempDir, "book.map.json")),
+		Chapters: []config.ChapterConfig{
+			{Title: "One", Scenes: []config.SceneConfig{{Files: []string{one}}, {Files: []string{two}}}},
+		},
+	}
+	cache := NewCache()
+	if err := ProcessCached(cfg, cache); err != nil {
+		t.Fatalf("ProcessCached() error = %v", err)
+	}
+
+	// only the file reported as changed is read again, so the unreported edit is not seen
+	write(one, "The tide came in.")
+	write(two, "The gulls were silent.")
+	if err := ProcessChanges(cfg, []string{one}, cache); err != nil {
+		t.Fatalf("ProcessChanges() error = %v", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !strings.Contains(string(content), "The tide came in.") || !strings.Contains(string(content), "The gulls cried.") {
+		t.Errorf("ProcessChanges() should parse only the changed file again, got %q", content)
+	}
+
+	// the documents taken from the cache may be changed without changing the cache
+	cached := cache.get(one)
+	cached.document.Children[0].Children = nil
+	if text := markdown.PlainText(cache.get(one).document); text != "The tide came in." {
+		t.Errorf("cached document = %q, want it unchanged", text)
+	}
+}
+
+func TestProcessChangesEmbeddedNote(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"scene.md":        "The lighthouse stood.\n\n![[keeper]]",
+		"other.md":        "The boats came home.",
+		"vault/keeper.md": "The keeper was old.",
+		"vault/unused.md": "Nobody reads this.",
+	})
+	output := func(name string) string { return filepath.Join(root, "out-"+name) }
+	yaml := "title: Embeds\n" +
+		"output_filename: " + output("book.md") + "\n" +
+		"vault: " + filepath.Join(root, "vault") + "\n" +
+		"chapters:\n" +
+		"  - title: One\n" +
+		"    output_filename: " + output("one.md") + "\n" +
+		"    scenes:\n" +
+		"      - files: [" + filepath.Join(root, "scene.md") + "]\n" +
+		"        output_filename: " + output("scene.md") + "\n" +
+		"  - title: Two\n" +
+		"    output_filename: " + output("two.md") + "\n" +
+		"    scenes:\n" +
+		"      - files: [" + filepath.Join(root, "other.md") + "]\n"
+	configFile := filepath.Join(root, ".inkwell.yaml")
+	if err := os.WriteFile(configFile, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	cfg, err := config.NewInkwellConfig(configFile)
+	if err != nil {
+		t.Fatalf("NewInkwellConfig() error = %v", err)
+	}
+
+	cache := NewCache()
+	if err := ProcessCached(*cfg, cache); err != nil {
+		t.Fatalf("ProcessCached() error = %v", err)
+	}
+	if err := os.Remove(output("two.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	note := filepath.Join(root, "vault", "keeper.md")
+	if err := os.WriteFile(note, []byte("The keeper was young."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessChanges(*cfg, []string{note}, cache); err != nil {
+		t.Fatalf("ProcessChanges() error = %v", err)
+	}
+
+	for _, name := range []string{"book.md", "one.md", "scene.md"} {
+		content, err := os.ReadFile(output(name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if !strings.Contains(string(content), "The keeper was young.") {
+			t.Errorf("%s should show the edited note, got %q", name, content)
+		}
+	}
+	if _, err := os.Stat(output("two.md")); !os.IsNotExist(err) {
+		t.Error("ProcessChanges() should not rewrite a chapter which does not embed the note")
+	}
+}
+
+func TestProcessChangesSourceMap(t *testing.T) {
+	tempDir := t.TempDir()
+	one := filepath.Join(tempDir, "one.md")
+	two := filepath.Join(tempDir, "two.md")
+	for path, content := range map[string]string{one: "The tide turned.", two: "The gulls cried."} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	output := func(name string) string { return filepath.Join(tempDir, "out-"+name) }
+	sourceMap := filepath.Join(tempDir, "map.json")
+	cfg := config.InkwellConfig{
+		OutputFilename: config.OutputFilename(output("book.md")),
+		SourceMap:      config.OutputFilename(sourceMap),
+		Chapters: []config.ChapterConfig{
+			{Title: "One", OutputFilename: config.OutputFilename(output("one.md")), Scenes: []config.SceneConfig{{Files: []string{one}}}},
+			{Title: "Two", OutputFilename: config.OutputFilename(output("two.md")), Scenes: []config.SceneConfig{{Files: []string{two}}}},
+		},
+	}
+	cache := NewCache()
+	if err := ProcessCached(cfg, cache); err != nil {
+		t.Fatalf("ProcessCached() error = %v", err)
+	}
+	if err := os.WriteFile(one, []byte("The tide came in."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessChanges(cfg, []string{one}, cache); err != nil {
+		t.Fatalf("ProcessChanges() error = %v", err)
+	}
+
+	sources, err := ReadSourceMap(sourceMap)
+	if err != nil {
+		t.Fatalf("ReadSourceMap() error = %v", err)
+	}
+	var files []string
+	for _, output := range sources.Outputs {
+		files = append(files, filepath.Base(output.File))
+	}
+	if strings.Join(files, " ") != "out-one.md out-two.md out-book.md" {
+		t.Errorf("source map outputs = %v, want every output in the order of the first build", files)
+	}
+	paragraph, err := sources.Locate(output("two.md"), "<1>")
+	if err != nil || paragraph.Source != two {
+		t.Errorf("Locate() = %+v, %v, want the paragraph of the unchanged chapter", paragraph, err)
+	}
+}
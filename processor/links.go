@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// defaultGlossaryTitle is the title of the glossary when the config does not give one
+const defaultGlossaryTitle = "Glossary"
+
+// glossaryNote is a struct that represents a note in the glossary which is linked from the book
+type glossaryNote struct {
+	title    string
+	path     string
+	document *markdown.Node
+}
+
+// resolveLinks matches the wiki links of the book to its sections, chapters and parts by their
+// titles and the names of their source files, and then to the notes in the glossary. The notes
+// which are linked, and those they link to in turn, are added to the back of the book as a
+// glossary, whose text is returned. A link which matches neither is added to the warnings of
+// the summary.
+func resolveLinks(wikiLinks config.WikiLinksConfig, glossary []string, options Options, summary *BookSummary, compiled *manuscript.Book) (string, error) {
+	entries := compiled.Contents()
+	compiled.Links = map[string]int{}
+	name := func(key string, idx int) {
+		if _, ok := compiled.Links[key]; !ok && key != "" {
+			compiled.Links[key] = idx
+		}
+	}
+	for idx, entry := range entries {
+		switch {
+		case entry.Section != nil:
+			name(strings.ToLower(strings.TrimSpace(entry.Section.Title)), idx)
+			for _, file := range entry.Section.Files {
+				name(manuscript.LinkName(file), idx)
+			}
+		case entry.Chapter != nil:
+			name(strings.ToLower(strings.TrimSpace(entry.Chapter.Title)), idx)
+			for _, scene := range entry.Chapter.Scenes {
+				for _, file := range scene.Files {
+					name(manuscript.LinkName(file), idx)
+				}
+			}
+		default:
+			name(strings.ToLower(strings.TrimSpace(entry.Part.Title)), idx)
+		}
+	}
+
+	notes := map[string]string{}
+	for _, path := range glossary {
+		if _, ok := notes[manuscript.LinkName(path)]; !ok {
+			notes[manuscript.LinkName(path)] = path
+		}
+	}
+
+	// the glossary follows every other entry of the book, so links within it resolve to it
+	var linked []string
+	seen := map[string]bool{}
+	check := func(doc *markdown.Node, idx int, owner string) {
+		markdown.Walk(doc, func(n *markdown.Node) bool {
+			if n.Kind != markdown.WikiLink {
+				return true
+			}
+			if _, ok := compiled.Link(n, idx); ok {
+				return false
+			}
+			note, _, _ := strings.Cut(n.Destination, "#")
+			if path, ok := notes[manuscript.LinkName(note)]; ok {
+				if !seen[path] {
+					seen[path] = true
+					linked = append(linked, path)
+				}
+				return false
+			}
+			summary.AddWarning(fmt.Sprintf("%s links to [[%s]], which is not in the book", owner, n.Destination))
+			return false
+		})
+	}
+	for idx, entry := range entries {
+		switch {
+		case entry.Section != nil:
+			check(entry.Section.Document, idx, fmt.Sprintf("section %q", entry.Section.Title))
+		case entry.Chapter != nil:
+			for _, scene := range entry.Chapter.Scenes {
+				check(scene.Document, idx, fmt.Sprintf("chapter %q", entry.Chapter.Title))
+			}
+		}
+	}
+
+	var linkedNotes []glossaryNote
+	for len(linkedNotes) < len(linked) {
+		path := linked[len(linkedNotes)]
+		source, err := parseFile(path, options)
+		if err != nil {
+			return "", err
+		}
+		doc := source.document
+		title := metaString(source.metadata, "title")
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		linkedNotes = append(linkedNotes, glossaryNote{title: title, path: path, document: doc})
+		check(doc, len(entries), fmt.Sprintf("glossary note %q", title))
+	}
+	if len(linkedNotes) == 0 {
+		return "", nil
+	}
+
+	sort.SliceStable(linkedNotes, func(i, j int) bool {
+		return strings.ToLower(linkedNotes[i].title) < strings.ToLower(linkedNotes[j].title)
+	})
+
+	title := wikiLinks.GlossaryTitle
+	if title == "" {
+		title = defaultGlossaryTitle
+	}
+	body := &strings.Builder{}
+	document := &markdown.Node{Kind: markdown.Document}
+	section := SectionSummary{Title: title, Placement: "back"}
+	var files []string
+	for _, note := range linkedNotes {
+		heading := &markdown.Node{Kind: markdown.Heading, Level: 2, Children: markdown.ParseInline(note.title).Children}
+		document.Children = append(document.Children, heading)
+		document.Children = append(document.Children, note.document.Children...)
+		files = append(files, note.path)
+
+		words, characters := countText(note.document, options)
+		section.Characters += characters
+		section.Words += words
+		section.Files++
+
+		body.WriteString("\n" + typesetMarkdown(heading, options.Typography) + "\n\n")
+		body.WriteString(typesetMarkdown(options.Paragraphs.mark(note.path, note.document), options.Typography) + "\n")
+	}
+
+	summary.AddSectionSummary(section)
+	compiled.AddSection(&manuscript.Section{Title: title, Placement: manuscript.Back, Files: files, Document: document})
+	name(strings.ToLower(title), len(entries))
+	for _, file := range files {
+		name(manuscript.LinkName(file), len(entries))
+	}
+
+	return "# " + typesetTitle(title, options.Typography) + "\n" + body.String(), nil
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+func TestProcessBookWikiLinks(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"one.md":           "Mara sailed to [[Harbor|the harbor]] past a [[Dragons#Sea Dragons|sea dragon]].",
+		"two.md":           "She remembered [[one#Dawn]] and [[Atlantis]].",
+		"wiki/Dragons.md":  "Large and fond of the [[Kraken]].",
+		"wiki/Kraken.md":   "---\ntitle: The Kraken\n---\nA legend.",
+		"wiki/Unlinked.md": "Never mentioned.",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(tempDir, name) }
+
+	warnings := &bytes.Buffer{}
+	Warnings = warnings
+	t.Cleanup(func() { Warnings = os.Stderr })
+
+	output := filepath.Join(tempDir, "book.md")
+	yaml := "title: Links\n" +
+		"scene_separator: \"* * *\"\n" +
+		"output_filename: " + output + "\n" +
+		"wiki_links:\n  glossary: " + filepath.Join(tempDir, "wiki") + "\n" +
+		"chapters:\n" +
+		"  - title: Harbor\n    scenes:\n      - files: [" + path("one.md") + "]\n" +
+		"  - title: Chapter 2\n    scenes:\n      - files: [" + path("two.md") + "]\n"
+	if err := os.WriteFile(filepath.Join(tempDir, ".inkwell.yaml"), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := config.NewInkwellConfig(filepath.Join(tempDir, ".inkwell.yaml"))
+	if err != nil {
+		t.Fatalf("NewInkwellConfig() error = %v", err)
+	}
+	cfg := *loaded
+
+	book, summary, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	expected := map[string]int{"harbor": 0, "one": 0, "chapter 2": 1, "two": 1, "glossary": 2, "dragons": 2, "kraken": 2}
+	if !reflect.DeepEqual(book.Links, expected) {
+		t.Errorf("Compile() links = %v, want %v", book.Links, expected)
+	}
+	glossary := book.Sections[len(book.Sections)-1]
+	if glossary.Title != "Glossary" || len(glossary.Files) != 2 {
+		t.Errorf("Compile() should add the linked notes to a glossary, got %+v", glossary)
+	}
+	if !reflect.DeepEqual(summary.Warnings, []string{`chapter "Chapter 2" links to [[Atlantis]], which is not in the book`}) {
+		t.Errorf("Compile() warnings = %v", summary.Warnings)
+	}
+
+	err = ProcessBook(cfg)
+	if err != nil {
+		t.Fatalf("ProcessBook() error = %v", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !strings.HasSuffix(string(content), "# Glossary\n\n## Dragons\n\nLarge and fond of the [[Kraken]].\n\n## The Kraken\n\nA legend.\n") {
+		t.Errorf("full manuscript should end with the glossary, got %q", content)
+	}
+	if !strings.Contains(warnings.String(), "warning: chapter \"Chapter 2\" links to [[Atlantis]]") {
+		t.Errorf("ProcessBook() should report the unresolved link, got %q", warnings.String())
+	}
+}
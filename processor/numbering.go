@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+)
+
+// defaultChapterHeading is the heading of a numbered chapter when the config does not give one
+const defaultChapterHeading = "Chapter {{number}}: {{title}}"
+
+// numberStyles are the styles in which chapters may be numbered
+var numberStyles = map[string]func(int) string{
+	"arabic": strconv.Itoa,
+	"roman":  romanNumeral,
+	"words":  spellNumber,
+	"none":   func(int) string { return "" },
+}
+
+var (
+	ones = []string{"", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten",
+		"Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen"}
+	tens = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+)
+
+// numberChapters returns a copy of the config in which each chapter is given the next number
+// in the numbering style of the book, and the title of each numbered chapter is replaced with
+// the heading filled in with its number and title. Chapters which are unnumbered are not
+// counted, and chapters with a number of their own keep it but are still counted. When the
+// book does not number its chapters, the config is returned as it is.
+func numberChapters(cfg config.InkwellConfig) (config.InkwellConfig, error) {
+	numbering := cfg.ChapterNumbering
+	if numbering.Style == "" && numbering.Heading == "" {
+		return cfg, nil
+	}
+
+	style := numbering.Style
+	if style == "" {
+		style = "arabic"
+	}
+	format, ok := numberStyles[style]
+	if !ok {
+		return cfg, fmt.Errorf("invalid chapter numbering style %q: want arabic, roman, words or none", numbering.Style)
+	}
+	heading := numbering.Heading
+	if heading == "" {
+		heading = defaultChapterHeading
+	}
+
+	var number func(chapters []config.ChapterConfig, parts []config.PartConfig, count *int) ([]config.ChapterConfig, []config.PartConfig)
+	number = func(chapters []config.ChapterConfig, parts []config.PartConfig, count *int) ([]config.ChapterConfig, []config.PartConfig) {
+		numbered := make([]config.ChapterConfig, len(chapters))
+		for idx, chapter := range chapters {
+			if !chapter.Unnumbered {
+				*count++
+				if chapter.Number == "" {
+					chapter.Number = format(*count)
+				}
+				if chapter.Number != "" {
+					chapter.Title = expandTemplate(heading, manuscript.Metadata{"number": chapter.Number, "title": chapter.Title})
+				}
+			}
+			numbered[idx] = chapter
+		}
+
+		numberedParts := make([]config.PartConfig, len(parts))
+		for idx, part := range parts {
+			partCount := count
+			if numbering.RestartPerPart {
+				partCount = new(int)
+			}
+			part.Chapters, part.Parts = number(part.Chapters, part.Parts, partCount)
+			numberedParts[idx] = part
+		}
+
+		return numbered, numberedParts
+	}
+
+	count := 0
+	cfg.Chapters, cfg.Parts = number(cfg.Chapters, cfg.Parts, &count)
+	return cfg, nil
+}
+
+// romanNumeral returns the number in upper case Roman numerals.
+func romanNumeral(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+
+	builder := &strings.Builder{}
+	for idx, value := range values {
+		for n >= value {
+			builder.WriteString(symbols[idx])
+			n -= value
+		}
+	}
+	return builder.String()
+}
+
+// spellNumber returns the number spelled out in English words, such as Twenty-One.
+func spellNumber(n int) string {
+	switch {
+	case n <= 0 || n >= 1000000:
+		return strconv.Itoa(n)
+	case n < 20:
+		return ones[n]
+	case n < 100:
+		if n%10 == 0 {
+			return tens[n/10]
+		}
+		return tens[n/10] + "-" + ones[n%10]
+	case n < 1000:
+		if n%100 == 0 {
+			return ones[n/100] + " Hundred"
+		}
+		return ones[n/100] + " Hundred and " + spellNumber(n%100)
+	default:
+		if n%1000 == 0 {
+			return spellNumber(n/1000) + " Thousand"
+		}
+		if n%1000 < 100 {
+			return spellNumber(n/1000) + " Thousand and " + spellNumber(n%1000)
+		}
+		return spellNumber(n/1000) + " Thousand " + spellNumber(n%1000)
+	}
+}
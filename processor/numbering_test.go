@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+func TestRomanNumeral(t *testing.T) {
+	tests := map[int]string{1: "I", 4: "IV", 9: "IX", 14: "XIV", 40: "XL", 1994: "MCMXCIV", 3999: "MMMCMXCIX"}
+	for n, expected := range tests {
+		if result := romanNumeral(n); result != expected {
+			t.Errorf("romanNumeral(%d) = %q, want %q", n, result, expected)
+		}
+	}
+}
+
+func TestSpellNumber(t *testing.T) {
+	tests := map[int]string{
+		1:    "One",
+		12:   "Twelve",
+		20:   "Twenty",
+		21:   "Twenty-One",
+		100:  "One Hundred",
+		105:  "One Hundred and Five",
+		342:  "Three Hundred and Forty-Two",
+		1000: "One Thousand",
+		1001: "One Thousand and One",
+		2345: "Two Thousand Three Hundred and Forty-Five",
+	}
+	for n, expected := range tests {
+		if result := spellNumber(n); result != expected {
+			t.Errorf("spellNumber(%d) = %q, want %q", n, result, expected)
+		}
+	}
+}
+
+func TestNumberChapters(t *testing.T) {
+	cfg := config.InkwellConfig{
+		Chapters: []config.ChapterConfig{
+			{Title: "Before", Unnumbered: true},
+			{Title: "Arrival"},
+			{Title: "Storm", Number: "2a"},
+		},
+		Parts: []config.PartConfig{
+			{Title: "Part Two", Chapters: []config.ChapterConfig{{Title: "Landfall"}, {}}},
+		},
+	}
+
+	titles := func(cfg config.InkwellConfig) []string {
+		var result []string
+		for _, chapter := range cfg.Chapters {
+			result = append(result, chapter.Title)
+		}
+		for _, chapter := range cfg.Parts[0].Chapters {
+			result = append(result, chapter.Title)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		numbering config.NumberingConfig
+		expected  []string
+	}{
+		{
+			name:     "disabled",
+			expected: []string{"Before", "Arrival", "Storm", "Landfall", ""},
+		},
+		{
+			name:      "arabic",
+			numbering: config.NumberingConfig{Style: "arabic"},
+			expected:  []string{"Before", "Chapter 1: Arrival", "Chapter 2a: Storm", "Chapter 3: Landfall", "Chapter 4"},
+		},
+		{
+			name:      "words restarting per part",
+			numbering: config.NumberingConfig{Style: "words", Heading: "{{number}}. {{title}}", RestartPerPart: true},
+			expected:  []string{"Before", "One. Arrival", "2a. Storm", "One. Landfall", "Two."},
+		},
+		{
+			name:      "roman",
+			numbering: config.NumberingConfig{Style: "roman", Heading: "{{number}}"},
+			expected:  []string{"Before", "I", "2a", "III", "IV"},
+		},
+		{
+			name:      "none",
+			numbering: config.NumberingConfig{Style: "none"},
+			expected:  []string{"Before", "Arrival", "Chapter 2a: Storm", "Landfall", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			numbered := cfg
+			numbered.ChapterNumbering = tt.numbering
+			result, err := numberChapters(numbered)
+			if err != nil {
+				t.Fatalf("numberChapters() error = %v", err)
+			}
+			if !reflect.DeepEqual(titles(result), tt.expected) {
+				t.Errorf("numberChapters() titles = %q, want %q", titles(result), tt.expected)
+			}
+		})
+	}
+
+	if cfg.Chapters[1].Title != "Arrival" || cfg.Parts[0].Chapters[0].Number != "" {
+		t.Error("numberChapters() should not modify the original config")
+	}
+
+	_, err := numberChapters(config.InkwellConfig{ChapterNumbering: config.NumberingConfig{Style: "greek"}})
+	if err == nil {
+		t.Error("numberChapters() should reject an unknown style")
+	}
+}
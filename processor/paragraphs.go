@@ -0,0 +1,378 @@
+package processor
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// paragraphMark brackets the position of a paragraph at the end of its text, until the
+// output which holds it is written
+const paragraphMark = "\u2063"
+
+// idAlphabet is the alphabet of paragraph ids, which leaves out the letters and digits most
+// easily mistaken for one another
+const idAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// idLength is the length of a new paragraph id, which grows when an id of that length is taken
+const idLength = 4
+
+var paragraphMarks = regexp.MustCompile(` ` + paragraphMark + `([0-9]+)` + paragraphMark)
+
+// paragraphEntry is a struct that represents a paragraph in the mapping file: its id, the
+// source file it was found in, and its text, against which the next build matches its
+// paragraphs. The path and lines of the source file are kept for the source map.
+type paragraphEntry struct {
+	ID   string `yaml:"id"`
+	File string `yaml:"file"`
+	Text string `yaml:"text"`
+
+	path    string
+	line    int
+	endLine int
+}
+
+// paragraphMapping is a struct that represents the mapping file. The retired ids are those of
+// paragraphs which were merged or deleted, and are not given out again.
+type paragraphMapping struct {
+	Paragraphs []paragraphEntry `yaml:"paragraphs"`
+	Retired    []string         `yaml:"retired,omitempty"`
+}
+
+// paragraphChange is a struct that represents an id which no longer stands for the same text.
+// A merged paragraph names the id of the paragraph it was merged into.
+type paragraphChange struct {
+	ID     string
+	Kind   string
+	Old    string
+	New    string
+	Merged string
+}
+
+// pendingOutput is a struct that represents an output which is written once every paragraph
+// of the book is known
+type pendingOutput struct {
+	text       string
+	numbers    bool
+	separators []string
+	filename   config.OutputFilename
+}
+
+// markedParagraphs is a struct that represents the paragraphs of the book as it is built,
+// when they are given stable ids or traced back to their source files. Each paragraph is
+// marked in the text as its source file is processed, and the outputs are held until every
+// paragraph of the book is known, so that the paragraphs can be matched to those of the
+// mapping file as a whole. The files of the paragraphs are kept relative to the root, the
+// directory of the mapping file.
+type markedParagraphs struct {
+	root       string
+	ids        bool
+	paragraphs []paragraphEntry
+	pending    []pendingOutput
+}
+
+// mark returns a copy of the document in which each top-level paragraph is marked at its end,
+// and records the paragraphs. When the paragraphs are not marked, the document is returned
+// as it is.
+func (p *markedParagraphs) mark(path string, doc *markdown.Node) *markdown.Node {
+	if p == nil {
+		return doc
+	}
+
+	file := path
+	if rel, err := filepath.Rel(p.root, path); err == nil {
+		file = filepath.ToSlash(rel)
+	}
+	marked := doc.Clone()
+	for _, block := range marked.Children {
+		text := strings.Join(strings.Fields(markdown.PlainText(block)), " ")
+		if block.Kind != markdown.Paragraph || text == "" {
+			continue
+		}
+		mark := paragraphMark + strconv.Itoa(len(p.paragraphs)) + paragraphMark
+		block.Children = append(block.Children, &markdown.Node{Kind: markdown.HTMLInline, Literal: " " + mark})
+		p.paragraphs = append(p.paragraphs, paragraphEntry{File: file, Text: text, path: path, line: block.Line, endLine: block.EndLine})
+	}
+	return marked
+}
+
+// write writes the output to the file with the given filename, numbering its paragraphs if
+// asked. When the paragraphs are marked, the output is held until they are all known.
+func (p *markedParagraphs) write(output string, numbers bool, separators []string, filename config.OutputFilename) error {
+	if p == nil {
+		return writeToFile(output, numbers, separators, filename)
+	}
+	p.pending = append(p.pending, pendingOutput{text: output, numbers: numbers, separators: separators, filename: filename})
+	return nil
+}
+
+// finish gives the paragraphs their ids, if they are kept, and writes the held outputs with
+// their paragraphs numbered. Then it updates the mapping file and writes the report, and
+// writes the source map of the outputs if one is given. With a cache, the source map keeps
+// the outputs of the last build which were not written again.
+func (p *markedParagraphs) finish(options config.ParagraphIDsConfig, sourceMap config.OutputFilename, cache *Cache) error {
+	var changes []paragraphChange
+	var retired []string
+	if p.ids {
+		mapping := paragraphMapping{}
+		data, err := os.ReadFile(options.Mapping)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		err = yaml.Unmarshal(data, &mapping)
+		if err != nil {
+			return fmt.Errorf("%s: %w", options.Mapping, err)
+		}
+		changes, retired = p.resolve(mapping)
+	}
+
+	sources := &SourceMap{}
+	for _, output := range p.pending {
+		text := strings.ReplaceAll(output.text, "\r\n", "\n")
+		if output.numbers {
+			text = strings.TrimSpace(text)
+		}
+		lines := strings.Split(text, "\n")
+		paragraphs := SourceOutput{File: string(output.filename)}
+		for _, numbered := range numberedParagraphs(text, output.separators) {
+			var paragraph *paragraphEntry
+			if mark := paragraphMarks.FindStringSubmatch(lines[numbered.endLine-1]); mark != nil {
+				idx, _ := strconv.Atoi(mark[1])
+				paragraph = &p.paragraphs[idx]
+			}
+
+			label := strconv.Itoa(numbered.number)
+			if p.ids && paragraph != nil {
+				label = paragraph.ID
+			}
+			if output.numbers && (paragraph != nil || !p.ids) {
+				lines[numbered.endLine-1] += " <" + label + ">"
+			}
+
+			if paragraph != nil {
+				paragraphs.Paragraphs = append(paragraphs.Paragraphs, SourceParagraph{
+					Paragraph:   numbered.number,
+					ID:          paragraph.ID,
+					Lines:       [2]int{numbered.line, numbered.endLine},
+					Source:      paragraph.path,
+					SourceLines: [2]int{paragraph.line, paragraph.endLine},
+				})
+			}
+		}
+		sources.Outputs = append(sources.Outputs, paragraphs)
+
+		text = paragraphMarks.ReplaceAllString(strings.Join(lines, "\n"), "")
+		err := writeToFile(text, false, nil, output.filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.ids {
+		data, err := yaml.Marshal(paragraphMapping{Paragraphs: p.paragraphs, Retired: retired})
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(options.Mapping, data, 0644)
+		if err != nil {
+			return err
+		}
+		if options.Report != "" {
+			err = writeToFile(paragraphReport(changes), false, nil, config.OutputFilename(options.Report))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if cache != nil {
+		sources = sources.carry(cache.sources)
+		cache.sources = sources
+	}
+	if sourceMap != "" {
+		return sources.write(string(sourceMap))
+	}
+	return nil
+}
+
+// resolve gives each paragraph of the book its id, and returns the ids which changed along
+// with every retired id. A paragraph with the same text as one in the mapping keeps its id;
+// then a paragraph which reads much like one which is left keeps that id, and is changed. Of
+// the paragraphs which are still left, one whose words are mostly found in a paragraph of the
+// book was merged into it, and any other was deleted. The rest of the paragraphs are new.
+func (p *markedParagraphs) resolve(mapping paragraphMapping) ([]paragraphChange, []string) {
+	used := map[string]bool{}
+	for _, id := range mapping.Retired {
+		used[id] = true
+	}
+	for _, old := range mapping.Paragraphs {
+		used[old.ID] = true
+	}
+
+	matched := make([]bool, len(mapping.Paragraphs))
+	var changes []paragraphChange
+
+	same := map[string][]int{}
+	for idx, old := range mapping.Paragraphs {
+		same[old.Text] = append(same[old.Text], idx)
+	}
+	for idx := range p.paragraphs {
+		candidates := same[p.paragraphs[idx].Text]
+		best := -1
+		for _, candidate := range candidates {
+			if matched[candidate] {
+				continue
+			}
+			if best < 0 || mapping.Paragraphs[candidate].File == p.paragraphs[idx].File && mapping.Paragraphs[best].File != p.paragraphs[idx].File {
+				best = candidate
+			}
+		}
+		if best >= 0 {
+			matched[best] = true
+			p.paragraphs[idx].ID = mapping.Paragraphs[best].ID
+		}
+	}
+
+	for idx := range p.paragraphs {
+		if p.paragraphs[idx].ID != "" {
+			continue
+		}
+		words := paragraphWords(p.paragraphs[idx].Text)
+		best, score := -1, 0.5
+		for candidate, old := range mapping.Paragraphs {
+			if matched[candidate] {
+				continue
+			}
+			similarity := 2 * float64(commonWords(words, paragraphWords(old.Text))) / float64(len(words)+len(paragraphWords(old.Text)))
+			if similarity > score || similarity == score && best >= 0 && old.File == p.paragraphs[idx].File && mapping.Paragraphs[best].File != old.File {
+				best, score = candidate, similarity
+			}
+		}
+		if best >= 0 {
+			matched[best] = true
+			p.paragraphs[idx].ID = mapping.Paragraphs[best].ID
+			changes = append(changes, paragraphChange{ID: p.paragraphs[idx].ID, Kind: "changed", Old: mapping.Paragraphs[best].Text, New: p.paragraphs[idx].Text})
+		}
+	}
+
+	for idx := range p.paragraphs {
+		if p.paragraphs[idx].ID == "" {
+			p.paragraphs[idx].ID = newParagraphID(p.paragraphs[idx].Text, used)
+		}
+		used[p.paragraphs[idx].ID] = true
+	}
+
+	retired := mapping.Retired
+	for candidate, old := range mapping.Paragraphs {
+		if matched[candidate] {
+			continue
+		}
+		retired = append(retired, old.ID)
+		words := paragraphWords(old.Text)
+		change := paragraphChange{ID: old.ID, Kind: "deleted", Old: old.Text}
+		best := 0.8
+		for _, paragraph := range p.paragraphs {
+			containment := float64(commonWords(words, paragraphWords(paragraph.Text))) / float64(len(words))
+			if containment >= best {
+				best = containment
+				change.Kind, change.Merged, change.New = "merged", paragraph.ID, paragraph.Text
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, retired
+}
+
+// newParagraphID returns an id for the paragraph which is not yet used, made from a hash of
+// its text so that the same text tends to get the same id.
+func newParagraphID(text string, used map[string]bool) string {
+	for attempt := 0; ; attempt++ {
+		sum := sha1.Sum([]byte(text + "\x00" + strconv.Itoa(attempt)))
+		n := new(big.Int).SetBytes(sum[:])
+		base := big.NewInt(int64(len(idAlphabet)))
+		id := &strings.Builder{}
+		length := idLength + attempt/8
+		for id.Len() < length {
+			mod := new(big.Int)
+			n.DivMod(n, base, mod)
+			id.WriteByte(idAlphabet[mod.Int64()])
+		}
+		if !used[id.String()] {
+			return id.String()
+		}
+	}
+}
+
+// paragraphWords returns the words of the paragraph in lower case, without punctuation.
+func paragraphWords(text string) []string {
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.TrimFunc(word, unicode.IsPunct)
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// commonWords returns the number of words which the two lists have in common, counting a
+// repeated word as often as it appears in both.
+func commonWords(a []string, b []string) int {
+	counts := map[string]int{}
+	for _, word := range b {
+		counts[word]++
+	}
+	common := 0
+	for _, word := range a {
+		if counts[word] > 0 {
+			counts[word]--
+			common++
+		}
+	}
+	return common
+}
+
+// paragraphReport returns the report of the ids which no longer stand for the same text.
+func paragraphReport(changes []paragraphChange) string {
+	builder := &strings.Builder{}
+	builder.WriteString("# Paragraph IDs\n\n")
+	if len(changes) == 0 {
+		builder.WriteString("No paragraph IDs were changed, merged or deleted.\n")
+		return builder.String()
+	}
+
+	for _, change := range changes {
+		switch change.Kind {
+		case "changed":
+			builder.WriteString("- `" + change.ID + "` changed: " + excerpt(change.Old) + " is now " + excerpt(change.New) + "\n")
+		case "merged":
+			builder.WriteString("- `" + change.ID + "` merged into `" + change.Merged + "`: " + excerpt(change.Old) + "\n")
+		default:
+			builder.WriteString("- `" + change.ID + "` deleted: " + excerpt(change.Old) + "\n")
+		}
+	}
+	return builder.String()
+}
+
+// excerpt returns the opening words of the text, quoted.
+func excerpt(text string) string {
+	words := strings.Fields(text)
+	if len(words) > 8 {
+		return strconv.Quote(strings.Join(words[:8], " ") + "…")
+	}
+	return strconv.Quote(text)
+}
@@ -0,0 +1,130 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+func TestResolveParagraphIDs(t *testing.T) {
+	mapping := paragraphMapping{
+		Paragraphs: []paragraphEntry{
+			{ID: "k3f9", File: "one.md", Text: "Mara waited in the rain."},
+			{ID: "w7xq", File: "one.md", Text: "The ship came in late that night, low in the water."},
+			{ID: "c2dm", File: "one.md", Text: "Nobody spoke."},
+			{ID: "h8pt", File: "two.md", Text: "The harbour was empty by morning."},
+		},
+		Retired: []string{"zz22"},
+	}
+	ids := &markedParagraphs{ids: true, paragraphs: []paragraphEntry{
+		{File: "one.md", Text: "A gull cried."},
+		{File: "one.md", Text: "Mara waited in the rain."},
+		{File: "one.md", Text: "The ship came in late that night, sitting low in the water. Nobody spoke."},
+	}}
+
+	changes, retired := ids.resolve(mapping)
+
+	if ids.paragraphs[1].ID != "k3f9" {
+		t.Errorf("unchanged paragraph id = %q, want %q", ids.paragraphs[1].ID, "k3f9")
+	}
+	if ids.paragraphs[2].ID != "w7xq" {
+		t.Errorf("changed paragraph id = %q, want %q", ids.paragraphs[2].ID, "w7xq")
+	}
+	if id := ids.paragraphs[0].ID; len(id) != idLength || strings.Trim(id, idAlphabet) != "" || id == "k3f9" || id == "w7xq" {
+		t.Errorf("new paragraph id = %q, want a new id of %d characters", id, idLength)
+	}
+
+	expected := []paragraphChange{
+		{ID: "w7xq", Kind: "changed"},
+		{ID: "c2dm", Kind: "merged", Merged: "w7xq"},
+		{ID: "h8pt", Kind: "deleted"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("resolve() changes = %+v, want %d changes", changes, len(expected))
+	}
+	for idx, change := range changes {
+		if change.ID != expected[idx].ID || change.Kind != expected[idx].Kind || change.Merged != expected[idx].Merged {
+			t.Errorf("resolve() change %d = %+v, want %+v", idx, change, expected[idx])
+		}
+	}
+	if strings.Join(retired, ",") != "zz22,c2dm,h8pt" {
+		t.Errorf("resolve() retired = %v, want [zz22 c2dm h8pt]", retired)
+	}
+}
+
+func TestNewParagraphID(t *testing.T) {
+	first := newParagraphID("Mara waited in the rain.", map[string]bool{})
+	if again := newParagraphID("Mara waited in the rain.", map[string]bool{}); again != first {
+		t.Errorf("newParagraphID() = %q, then %q, want the same id for the same text", first, again)
+	}
+	if other := newParagraphID("Mara waited in the rain.", map[string]bool{first: true}); other == first {
+		t.Errorf("newParagraphID() = %q, want an id other than the one in use", other)
+	}
+}
+
+func TestProcessBookParagraphIDs(t *testing.T) {
+	tempDir := t.TempDir()
+	scene := filepath.Join(tempDir, "one.md")
+	write := func(content string) {
+		if err := os.WriteFile(scene, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	output := filepath.Join(tempDir, "book.md")
+	report := filepath.Join(tempDir, "report.md")
+	cfg := config.InkwellConfig{
+		Title:          "Test Book",
+		OutputFilename: config.OutputFilename(output),
+		OutputNumbers:  true,
+		Chapters:       []config.ChapterConfig{{Title: "One", Scenes: []config.SceneConfig{{Files: []string{scene}}}}},
+		ParagraphIDs: config.ParagraphIDsConfig{
+			Enabled: true,
+			Mapping: filepath.Join(tempDir, ".inkwell-paragraphs.yaml"),
+			Report:  report,
+		},
+	}
+	build := func() map[string]string {
+		if err := ProcessBook(cfg); err != nil {
+			t.Fatalf("ProcessBook() error = %v", err)
+		}
+		content, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		ids := map[string]string{}
+		for _, match := range regexp.MustCompile(`(?m)^(.*) <([0-9a-z]+)>$`).FindAllStringSubmatch(string(content), -1) {
+			ids[match[1]] = match[2]
+		}
+		return ids
+	}
+
+	write("Mara waited in the rain.\n\nThe ship was late.\n\nNobody came.")
+	before := build()
+	if len(before) != 3 {
+		t.Fatalf("output should number each paragraph with an id, got %v", before)
+	}
+
+	write("A gull cried.\n\nMara waited in the rain.\n\nThe ship was very late.")
+	after := build()
+	if after["Mara waited in the rain."] != before["Mara waited in the rain."] {
+		t.Errorf("id of an unchanged paragraph moved from %q to %q", before["Mara waited in the rain."], after["Mara waited in the rain."])
+	}
+	if after["The ship was very late."] != before["The ship was late."] {
+		t.Errorf("id of an edited paragraph moved from %q to %q", before["The ship was late."], after["The ship was very late."])
+	}
+
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	for _, line := range []string{"`" + before["The ship was late."] + "` changed", "`" + before["Nobody came."] + "` deleted"} {
+		if !strings.Contains(string(content), line) {
+			t.Errorf("report should contain %q, got %q", line, content)
+		}
+	}
+}
@@ -1,73 +1,148 @@
 package processor
 
 import (
+	"fmt"
 	"io"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/docx"
+	"github.com/nivthefox/inkwell/epub"
+	"github.com/nivthefox/inkwell/htmlbook"
+	"github.com/nivthefox/inkwell/latex"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+	"github.com/nivthefox/inkwell/pdf"
 )
 
-var spaces = regexp.MustCompile(`[ \t]+`)
-var wikiLinks = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
-
-// processWikiLinks removes wiki-style links [[text]] and replaces them with just the text content
-func processWikiLinks(content string) string {
-	return wikiLinks.ReplaceAllString(content, "$1")
-}
+// Warnings receives the warnings found while the book is built, such as wiki links which
+// could not be resolved
+var Warnings io.Writer = os.Stderr
 
 // ProcessBook iterates over each of the files in every scene in the config
 // and builds the appropriate output files by concatenating the contents
 // of the files in each scene.
 func ProcessBook(config config.InkwellConfig) error {
+	return processBook(config, nil)
+}
+
+// processBook builds the book, taking its parsed source files from the cache if there is one.
+func processBook(config config.InkwellConfig, cache *Cache) error {
+	if err := checkLayout(config.Chapters, config.Parts, "the book"); err != nil {
+		return err
+	}
+	config, err := numberChapters(config)
+	if err != nil {
+		return err
+	}
+
 	builder := &strings.Builder{}
 	summary := BookSummary{}
+	compiled := newBook(config)
 
+	options, err := optionsFor(config)
+	if err != nil {
+		return err
+	}
+	options.Cache = cache
+	if config.ParagraphIDs.Enabled || config.SourceMap != "" {
+		options.Paragraphs = &markedParagraphs{root: filepath.Dir(config.ParagraphIDs.Mapping), ids: config.ParagraphIDs.Enabled}
+	}
 	createMetadata(config, builder)
 
 	if config.Title != "" {
-		tperr := createTitlePage(config.Title, config.Authors, builder)
+		tperr := createTitlePage(typesetTitle(config.Title, options.Typography), config.Authors, builder)
 		if tperr != nil {
 			return tperr
 		}
 	}
 
-	derr := createDedication(config.DedicationFilename, builder)
+	derr := createDedication(config.DedicationFilename, builder, compiled)
 	if derr != nil {
 		return derr
 	}
 
-	for _, section := range config.Sections {
-		_, secerr := ProcessSection(section, config.StripWikiLinks)
-		if secerr != nil {
-			return secerr
-		}
+	text, err := processContents(config.Sections, config.Chapters, config.Parts, config.SceneSeparator, options, &summary, compiled)
+	if err != nil {
+		return err
 	}
-
-	for _, chapter := range config.Chapters {
-		text, err := ProcessChapter(chapter, config.SceneSeparator, config.StripWikiLinks, &summary)
-		if err != nil {
-			return err
-		}
-		builder.WriteString("\n" + text.String())
+	glossary, err := resolveLinks(config.WikiLinks, config.GlossaryFiles(), options, &summary, compiled)
+	if err != nil {
+		return err
+	}
+	if glossary != "" {
+		text += "\n" + glossary
 	}
+	// the DOCX output rounds the words itself, as the publisher rule would
+	words := summary.Words
+	summary.CountedBy(config.WordCount)
+	for _, warning := range summary.Warnings {
+		fmt.Fprintln(Warnings, "warning: "+warning)
+	}
+	if config.TOC.Manuscript {
+		builder.WriteString(tableOfContents(TypesetBook(compiled, options.Typography), builder.String(), text))
+	}
+	builder.WriteString(text)
+	separators := sceneSeparators(config.SceneSeparator, config.Chapters, config.Parts)
 
 	if config.OutputFilename != "" {
-		ferr := writeToFile(builder.String(), config.OutputNumbers, config.OutputFilename)
+		ferr := options.Paragraphs.write(builder.String(), config.OutputNumbers, separators, config.OutputFilename)
 		if ferr != nil {
 			return ferr
 		}
 	}
+	if options.Paragraphs != nil {
+		merr := options.Paragraphs.finish(config.ParagraphIDs, config.SourceMap, cache)
+		if merr != nil {
+			return merr
+		}
+	}
+
+	if config.OutputEpub != "" {
+		eerr := epub.Write(TypesetBook(compiled, config.TypographyFor(config.Epub.Typography)), config.Epub, string(config.OutputEpub))
+		if eerr != nil {
+			return eerr
+		}
+	}
+
+	if config.OutputDocx != "" {
+		dxerr := docx.Write(TypesetBook(compiled, config.TypographyFor(config.Docx.Typography)), config.Docx, words, string(config.OutputDocx))
+		if dxerr != nil {
+			return dxerr
+		}
+	}
+
+	if config.OutputHTML != "" {
+		herr := htmlbook.Write(TypesetBook(compiled, config.TypographyFor(config.HTML.Typography)), config.HTML, string(config.OutputHTML))
+		if herr != nil {
+			return herr
+		}
+	}
+
+	if config.OutputPDF != "" {
+		perr := pdf.Write(TypesetBook(compiled, config.TypographyFor(config.PDF.Typography)), config.PDF, string(config.OutputPDF))
+		if perr != nil {
+			return perr
+		}
+	}
+
+	if config.OutputLatex != "" {
+		lerr := latex.Write(TypesetBook(compiled, config.TypographyFor(config.Latex.Typography)), config.Latex, string(config.OutputLatex))
+		if lerr != nil {
+			return lerr
+		}
+	}
 
 	if config.SummaryFilename != "" {
-		sum, serr := summary.String()
+		sum, serr := summary.Format(config.SummaryFormat)
 		if serr != nil {
 			return serr
 		}
-		ferr := writeToFile(sum, false, config.SummaryFilename)
+		ferr := writeToFile(sum, false, nil, config.SummaryFilename)
 		if ferr != nil {
 			return ferr
 		}
@@ -76,139 +151,471 @@ func ProcessBook(config config.InkwellConfig) error {
 	return nil
 }
 
+// newBook returns the book of the config before its contents are added. A config which does
+// not give the language of the book, such as one built in code, gets the default language.
+func newBook(cfg config.InkwellConfig) *manuscript.Book {
+	language := cfg.Language
+	if language == "" {
+		language = config.DefaultLanguage
+	}
+	return &manuscript.Book{
+		Title:          cfg.Title,
+		Summary:        cfg.Summary,
+		Authors:        cfg.Authors,
+		Language:       language,
+		SceneSeparator: cfg.SceneSeparator,
+		TOC:            manuscript.TOCOptions{Title: cfg.TOC.Title, Depth: cfg.TOC.Depth, Scenes: cfg.TOC.Scenes},
+	}
+}
+
+// Compile processes the dedication, sections and chapters in the config and returns the
+// compiled book and its summary, without writing any of the output files.
+func Compile(config config.InkwellConfig) (*manuscript.Book, *BookSummary, error) {
+	if err := checkLayout(config.Chapters, config.Parts, "the book"); err != nil {
+		return nil, nil, err
+	}
+	config, err := numberChapters(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary := &BookSummary{}
+	compiled := newBook(config)
+
+	err = createDedication(config.DedicationFilename, &strings.Builder{}, compiled)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sections, chapters, parts := contentsWithoutOutputs(config.Sections, config.Chapters, config.Parts)
+	options, err := optionsFor(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = processContents(sections, chapters, parts, config.SceneSeparator, options, summary, compiled)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = resolveLinks(config.WikiLinks, config.GlossaryFiles(), options, summary, compiled)
+	if err != nil {
+		return nil, nil, err
+	}
+	summary.CountedBy(config.WordCount)
+
+	return compiled, summary, nil
+}
+
+// withoutOutputs returns a copy of the chapter config with the output files of the
+// chapter and its scenes removed.
+func withoutOutputs(chapter config.ChapterConfig) config.ChapterConfig {
+	chapter.OutputFilename = ""
+	scenes := make([]config.SceneConfig, len(chapter.Scenes))
+	for idx, scene := range chapter.Scenes {
+		scene.OutputFilename = ""
+		scenes[idx] = scene
+	}
+	chapter.Scenes = scenes
+	return chapter
+}
+
+// contentsWithoutOutputs returns copies of the section, chapter and part configs with the
+// output files of each of them, and of everything within them, removed.
+func contentsWithoutOutputs(sections []config.SectionConfig, chapters []config.ChapterConfig, parts []config.PartConfig) ([]config.SectionConfig, []config.ChapterConfig, []config.PartConfig) {
+	strippedSections := make([]config.SectionConfig, len(sections))
+	for idx, section := range sections {
+		section.OutputFilename = ""
+		strippedSections[idx] = section
+	}
+
+	strippedChapters := make([]config.ChapterConfig, len(chapters))
+	for idx, chapter := range chapters {
+		strippedChapters[idx] = withoutOutputs(chapter)
+	}
+
+	strippedParts := make([]config.PartConfig, len(parts))
+	for idx, part := range parts {
+		part.OutputFilename = ""
+		part.Sections, part.Chapters, part.Parts = contentsWithoutOutputs(part.Sections, part.Chapters, part.Parts)
+		strippedParts[idx] = part
+	}
+
+	return strippedSections, strippedChapters, strippedParts
+}
+
+// processContents processes the sections, chapters and parts of the book or of a part into
+// the compiled book, and returns their text in reading order. Chapters without a scene
+// separator of their own use the given separator.
+func processContents(sections []config.SectionConfig, chapters []config.ChapterConfig, parts []config.PartConfig, separator string, options Options, summary *BookSummary, compiled *manuscript.Book) (string, error) {
+	texts := map[any]string{}
+	for _, section := range sections {
+		text, err := ProcessSection(section, options, summary, compiled)
+		if err != nil {
+			return "", err
+		}
+		if text != nil {
+			texts[compiled.Sections[len(compiled.Sections)-1]] = text.String()
+		}
+	}
+
+	for _, chapter := range chapters {
+		text, err := ProcessChapter(chapter, separator, options, summary, compiled)
+		if err != nil {
+			return "", err
+		}
+		texts[compiled.Chapters[len(compiled.Chapters)-1]] = text.String()
+	}
+
+	for _, part := range parts {
+		text, err := ProcessPart(part, separator, options, summary, compiled)
+		if err != nil {
+			return "", err
+		}
+		texts[compiled.Parts[len(compiled.Parts)-1]] = text.String()
+	}
+
+	// sections, chapters and parts are written in reading order; the contents of each
+	// part are already in its text
+	builder := &strings.Builder{}
+	for _, entry := range compiled.Contents() {
+		switch {
+		case entry.Depth > 0:
+		case entry.Section != nil:
+			builder.WriteString("\n" + texts[entry.Section])
+		case entry.Chapter != nil:
+			builder.WriteString("\n" + texts[entry.Chapter])
+		default:
+			builder.WriteString("\n" + texts[entry.Part])
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// sceneSeparators returns the scene separators used by the chapters, including those within
+// the parts, along with the given separator of the book or part which holds them.
+func sceneSeparators(separator string, chapters []config.ChapterConfig, parts []config.PartConfig) []string {
+	separators := []string{separator}
+	for _, chapter := range chapters {
+		if chapter.SceneSeparator != "" {
+			separators = append(separators, chapter.SceneSeparator)
+		}
+	}
+	for _, part := range parts {
+		if part.SceneSeparator != "" {
+			separator = part.SceneSeparator
+		}
+		separators = append(separators, sceneSeparators(separator, part.Chapters, part.Parts)...)
+	}
+	return separators
+}
+
+// ProcessPart processes the sections, chapters and parts within the part in the config,
+// and writes the title of the part followed by its contents in reading order to the
+// appropriate output file. Chapters in the part are separated by the separator of the part,
+// or by the given separator if the part has none. The words and characters within the part
+// are rolled up into a summary of the part.
+func ProcessPart(config config.PartConfig, separator string, options Options, book *BookSummary, compiled *manuscript.Book) (*strings.Builder, error) {
+	if config.SceneSeparator != "" {
+		separator = config.SceneSeparator
+	}
+
+	// the contents of the part are compiled and summarized on their own before they are
+	// added to the book
+	summary := &BookSummary{}
+	contents := &manuscript.Book{SceneSeparator: separator}
+	text, err := processContents(config.Sections, config.Chapters, config.Parts, separator, options, summary, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString("# " + typesetTitle(config.Title, options.Typography) + "\n")
+	builder.WriteString(text)
+
+	if config.OutputFilename != "" {
+		err := options.Paragraphs.write(builder.String(), config.OutputNumbers, sceneSeparators(separator, config.Chapters, config.Parts), config.OutputFilename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, section := range summary.SectionSummary {
+		book.AddSectionSummary(section)
+	}
+	for _, chapter := range summary.ChapterSummary {
+		book.AddChapterSummary(chapter)
+	}
+	book.AddPartSummary(PartSummary{
+		Summary:  summary.Summary,
+		Title:    config.Title,
+		Chapters: len(summary.ChapterSummary),
+		Parts:    summary.PartSummary,
+	})
+	compiled.AddPart(&manuscript.Part{
+		Title:    config.Title,
+		Sections: contents.Sections,
+		Chapters: contents.Chapters,
+		Parts:    contents.Parts,
+	})
+	return builder, nil
+}
+
 // ProcessChapter iterates over each of the scenes in the chapter in the config
 // and builds the appropriate output files by concatenating the contents
-// of the files in each scene.
-func ProcessChapter(config config.ChapterConfig, separator string, stripWikiLinks bool, book *BookSummary) (*strings.Builder, error) {
+// of the files in each scene. Scenes are separated by the separator of the
+// chapter, or by the given separator of the book if the chapter has none.
+func ProcessChapter(config config.ChapterConfig, separator string, options Options, book *BookSummary, compiled *manuscript.Book) (*strings.Builder, error) {
 	builder := &strings.Builder{}
-	builder.WriteString("## " + config.Title + "\n")
+	builder.WriteString("## " + typesetTitle(config.Title, options.Typography) + "\n")
 	summary := ChapterSummary{
-		Title: config.Title,
+		Title:  config.Title,
+		Number: config.Number,
+	}
+	if config.SceneSeparator != "" {
+		separator = config.SceneSeparator
+	}
+	chapter := &manuscript.Chapter{
+		Title:          config.Title,
+		Number:         config.Number,
+		ExcludeFromTOC: config.ExcludeFromTOC,
+		SceneBreak:     markdown.NewSceneBreak(separator),
 	}
 
-	for idx, scene := range config.Scenes {
-		if idx > 0 {
-			builder.WriteString("\n\\* \\* \\*\n\n")
-		}
-
-		sceneBuilder, err := ProcessScene(scene, stripWikiLinks, &summary)
+	for _, scene := range config.Scenes {
+		sceneBuilder, err := ProcessScene(scene, options, &summary, chapter)
 		if err != nil {
 			return nil, err
 		}
+		// every file in the scene was cut
+		if sceneBuilder == nil {
+			continue
+		}
+
+		if len(chapter.Scenes) > 1 {
+			builder.WriteString("\n" + strings.TrimSpace(markdown.RenderMarkdown(chapter.SceneBreak)) + "\n\n")
+		}
+
+		if config.SceneHeading != "" {
+			compiled := chapter.Scenes[len(chapter.Scenes)-1]
+			heading := sceneHeading(config.SceneHeading, compiled)
+			if heading != nil {
+				compiled.Heading = markdown.PlainText(heading)
+				compiled.Document.Children = append([]*markdown.Node{heading}, compiled.Document.Children...)
+				builder.WriteString(typesetMarkdown(heading, options.Typography) + "\n\n")
+			}
+		}
 
 		builder.WriteString(sceneBuilder.String())
 	}
 
 	if config.OutputFilename != "" {
-		err := writeToFile(builder.String(), config.OutputNumbers, config.OutputFilename)
+		err := options.Paragraphs.write(builder.String(), config.OutputNumbers, []string{separator}, config.OutputFilename)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	book.AddChapterSummary(summary)
+	compiled.AddChapter(chapter)
 	return builder, nil
 }
 
 // ProcessScene concatenates the contents of the files in the scene in the config
-// and writes the output to the appropriate output file.
-func ProcessScene(config config.SceneConfig, stripWikiLinks bool, chapter *ChapterSummary) (*strings.Builder, error) {
+// and writes the output to the appropriate output file. Files whose front matter
+// marks them as cut are skipped, and if every file is cut the scene is left out
+// of the book and a nil builder is returned.
+func ProcessScene(config config.SceneConfig, options Options, chapter *ChapterSummary, compiled *manuscript.Chapter) (*strings.Builder, error) {
 	scene := &strings.Builder{}
 	summary := SceneSummary{}
+	document := &markdown.Node{Kind: markdown.Document}
+	metadata := manuscript.Metadata{}
+	var files []string
 
-	for idx, path := range config.Files {
-		builder := &strings.Builder{}
-		if idx > 0 {
-			scene.WriteString("\n")
-		}
-
-		file, err := os.Open(path)
+	for _, path := range config.Files {
+		source, err := parseFile(path, options)
 		if err != nil {
 			return nil, err
 		}
-
-		// Read the contents of the file
-		_, err = io.Copy(builder, file)
-		if err != nil {
-			return nil, err
+		doc, meta := source.document, source.metadata
+		if isCut(meta) {
+			continue
 		}
-		_ = file.Close()
+		mergeMetadata(metadata, meta)
+		document.Children = append(document.Children, doc.Children...)
+		files = append(files, path)
+		files = append(files, source.embeds...)
 
-		// Trim extra newlines
-		content := strings.TrimSpace(builder.String())
-		content = spaces.ReplaceAllString(content, " ")
-		
-		// Process wiki links if enabled
-		if stripWikiLinks {
-			content = processWikiLinks(content)
+		if summary.Files > 0 {
+			scene.WriteString("\n")
 		}
 
-		summary.AddCharacters(len(content))
-		summary.AddWords(len(strings.Fields(content)))
+		words, characters := countText(doc, options)
+		summary.AddCharacters(characters)
+		summary.AddWords(words)
 		summary.AddFile()
+		summary.EmbeddedWords += source.embeddedWords
+
+		scene.WriteString(typesetMarkdown(options.Paragraphs.mark(path, doc), options.Typography) + "\n")
+	}
 
-		scene.WriteString(content + "\n")
+	if len(config.Files) > 0 && summary.Files == 0 {
+		return nil, nil
 	}
 
 	if config.OutputFilename != "" {
-		err := writeToFile(scene.String(), config.OutputNumbers, config.OutputFilename)
+		err := options.Paragraphs.write(scene.String(), config.OutputNumbers, nil, config.OutputFilename)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	number := metaString(metadata, "number")
+	if number == "" {
+		number = strconv.Itoa(len(compiled.Scenes) + 1)
+	}
+	summary.Title = metaString(metadata, "title")
+	summary.Number = number
+	summary.Synopsis = metaString(metadata, "synopsis")
+
 	chapter.AddSceneSummary(summary)
+	compiled.AddScene(&manuscript.Scene{Title: summary.Title, Number: number, Files: files, Metadata: metadata, Document: document})
 	return scene, nil
 }
 
 // ProcessSection concatenates the contents of the files in the section in the config
-// and writes the output to the appropriate output file.
-func ProcessSection(config config.SectionConfig, stripWikiLinks bool) (*strings.Builder, error) {
-	section := &strings.Builder{}
-	section.WriteString("# " + config.Title + "\n")
+// and writes the output to the appropriate output file. A title in the front matter
+// of one of the files replaces the title in the config. Files whose front matter
+// marks them as cut are skipped, and if every file is cut the section is left out
+// of the book and a nil builder is returned.
+func ProcessSection(config config.SectionConfig, options Options, book *BookSummary, compiled *manuscript.Book) (*strings.Builder, error) {
+	placement, chapter, err := parsePlacement(config.Placement)
+	if err != nil {
+		return nil, err
+	}
 
-	for idx, path := range config.Files {
-		builder := &strings.Builder{}
-		if idx > 0 {
-			section.WriteString("\n")
-		}
+	body := &strings.Builder{}
+	document := &markdown.Node{Kind: markdown.Document}
+	metadata := manuscript.Metadata{}
+	var files []string
+	summary := SectionSummary{Placement: config.Placement}
+	if summary.Placement == "" {
+		summary.Placement = "front"
+	}
 
-		file, err := os.Open(path)
+	for _, path := range config.Files {
+		source, err := parseFile(path, options)
 		if err != nil {
 			return nil, err
 		}
-
-		// Read the contents of the file
-		_, err = io.Copy(builder, file)
-		if err != nil {
-			return nil, err
+		doc, meta := source.document, source.metadata
+		if isCut(meta) {
+			continue
 		}
-		_ = file.Close()
+		mergeMetadata(metadata, meta)
+		document.Children = append(document.Children, doc.Children...)
+		files = append(files, path)
+		files = append(files, source.embeds...)
 
-		// Trim extra newlines
-		content := strings.TrimSpace(builder.String())
-		content = spaces.ReplaceAllString(content, " ")
-		
-		// Process wiki links if enabled
-		if stripWikiLinks {
-			content = processWikiLinks(content)
+		if summary.Files > 0 {
+			body.WriteString("\n")
 		}
 
-		section.WriteString(content + "\n")
+		words, characters := countText(doc, options)
+		summary.Characters += characters
+		summary.Words += words
+		summary.Files++
+
+		body.WriteString(typesetMarkdown(options.Paragraphs.mark(path, doc), options.Typography) + "\n")
+	}
+
+	if len(config.Files) > 0 && summary.Files == 0 {
+		return nil, nil
 	}
 
+	title := config.Title
+	if override := metaString(metadata, "title"); override != "" {
+		title = override
+	}
+	section := &strings.Builder{}
+	section.WriteString("# " + typesetTitle(title, options.Typography) + "\n")
+	section.WriteString(body.String())
+
 	if config.OutputFilename != "" {
-		err := writeToFile(section.String(), config.OutputNumbers, config.OutputFilename)
+		err := options.Paragraphs.write(section.String(), config.OutputNumbers, nil, config.OutputFilename)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	summary.Title = title
+	book.AddSectionSummary(summary)
+	compiled.AddSection(&manuscript.Section{
+		Title:          title,
+		Placement:      placement,
+		Chapter:        chapter,
+		ExcludeFromTOC: config.ExcludeFromTOC,
+		Files:          files,
+		Metadata:       metadata,
+		Document:       document,
+	})
 	return section, nil
 }
 
+// checkLayout returns an error if the book or one of its parts holds both chapters and parts.
+// The config lists the chapters and the parts of each in lists of their own, so it cannot say
+// where the chapters fall among the parts.
+func checkLayout(chapters []config.ChapterConfig, parts []config.PartConfig, holder string) error {
+	if len(chapters) > 0 && len(parts) > 0 {
+		return fmt.Errorf("%s holds both chapters and parts, whose order cannot be kept; move the chapters into a part of their own, or make them sections", holder)
+	}
+	for _, part := range parts {
+		if err := checkLayout(part.Chapters, part.Parts, fmt.Sprintf("part %q", part.Title)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePlacement parses the placement of a section: front (the default), back, or
+// before_chapter:N, which places the section before the Nth chapter.
+func parsePlacement(placement string) (manuscript.Placement, int, error) {
+	switch placement {
+	case "", "front":
+		return manuscript.Front, 0, nil
+	case "back":
+		return manuscript.Back, 0, nil
+	}
+
+	if number, ok := strings.CutPrefix(placement, "before_chapter:"); ok {
+		chapter, err := strconv.Atoi(strings.TrimSpace(number))
+		if err == nil && chapter > 0 {
+			return manuscript.BeforeChapter, chapter, nil
+		}
+	}
+	return manuscript.Front, 0, fmt.Errorf("invalid section placement %q: want front, back or before_chapter:N", placement)
+}
+
+// sceneHeading returns a heading for the scene from the template, filled in with the values
+// in the front matter of the scene, its title and its number. It returns nil if the heading
+// would be empty.
+func sceneHeading(template string, scene *manuscript.Scene) *markdown.Node {
+	values := manuscript.Metadata{}
+	mergeMetadata(values, scene.Metadata)
+	values["title"] = scene.Title
+	values["number"] = scene.Number
+
+	text := expandTemplate(template, values)
+	if text == "" {
+		return nil
+	}
+
+	heading := &markdown.Node{Kind: markdown.Heading, Level: 3}
+	heading.Children = markdown.ParseInline(text).Children
+	return heading
+}
+
 // createDedication reads the contents of the dedication file and writes it to the builder.
-func createDedication(filename string, builder *strings.Builder) error {
+func createDedication(filename string, builder *strings.Builder, compiled *manuscript.Book) error {
 	if filename == "" {
 		return nil
 	}
@@ -219,15 +626,17 @@ func createDedication(filename string, builder *strings.Builder) error {
 	}
 	defer file.Close()
 
-	builder.WriteString("## Dedication\n")
-
 	// Read the contents of the file
-	_, err = io.Copy(builder, file)
+	dedication := &strings.Builder{}
+	_, err = io.Copy(dedication, file)
 	if err != nil {
 		return err
 	}
 
+	builder.WriteString("## Dedication\n")
+	builder.WriteString(dedication.String())
 	builder.WriteString("\n")
+	compiled.Dedication = markdown.Parse(dedication.String())
 	return nil
 }
 
@@ -257,7 +666,8 @@ func createTitlePage(title string, authors []string, builder *strings.Builder) e
 }
 
 // writeToFile writes the contents of the strings.Builder to a file with the given filename.
-func writeToFile(output string, numbers bool, filename config.OutputFilename) error {
+// When numbering paragraphs, paragraphs which match one of the scene separators are skipped.
+func writeToFile(output string, numbers bool, separators []string, filename config.OutputFilename) error {
 	// trim windows line endings
 	output = strings.ReplaceAll(output, "\r\n", "\n")
 
@@ -268,50 +678,7 @@ func writeToFile(output string, numbers bool, filename config.OutputFilename) er
 	defer file.Close()
 
 	if numbers {
-		o := strings.Split(output, "\n")
-		no := 1
-		inMeta := false
-		inBlockquote := false
-
-		for idx, line := range o {
-			trimmedLine := strings.TrimSpace(line)
-
-			// Handle metadata section
-			if trimmedLine == "---" {
-				inMeta = !inMeta
-				continue
-			}
-			if inMeta {
-				continue
-			}
-
-			// Skip empty lines, headers, and scene separators
-			if trimmedLine == "" ||
-				strings.HasPrefix(trimmedLine, "#") ||
-				trimmedLine == "\\* \\* \\*" ||
-				strings.HasPrefix(trimmedLine, "-") {
-				continue
-			}
-
-			// Handle blockquotes
-			if strings.HasPrefix(trimmedLine, ">") {
-				inBlockquote = true
-				continue
-			}
-
-			// If we were in a blockquote and hit a non-blockquote line,
-			// mark the end of blockquote
-			if inBlockquote && !strings.HasPrefix(trimmedLine, ">") {
-				inBlockquote = false
-			}
-
-			// Only number actual paragraph lines (not in blockquote or other special elements)
-			if !inBlockquote && trimmedLine != "" {
-				o[idx] = line + " <" + strconv.Itoa(no) + ">"
-				no += 1
-			}
-		}
-		output = strings.TrimSpace(strings.Join(o, "\n"))
+		output = numberParagraphs(output, separators)
 	}
 
 	_, err = file.WriteString(output)
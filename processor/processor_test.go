@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"archive/zip"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -8,9 +10,11 @@ import (
 	"time"
 
 	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
 )
 
-func TestProcessWikiLinks(t *testing.T) {
+func TestRemoveWikiLinks(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -29,7 +33,7 @@ func TestProcessWikiLinks(t *testing.T) {
 		{
 			name:     "nested brackets",
 			input:    "[[link with [inner] brackets]]",
-			expected: "[[link with [inner] brackets]]", // regex stops at first ], so nested brackets don't work
+			expected: "[[link with [inner] brackets]]", // wiki link targets cannot contain ]
 		},
 		{
 			name:     "no wiki links",
@@ -39,20 +43,32 @@ func TestProcessWikiLinks(t *testing.T) {
 		{
 			name:     "empty wiki link",
 			input:    "Text with [[]] empty link",
-			expected: "Text with [[]] empty link", // regex requires at least one non-] character
+			expected: "Text with [[]] empty link", // wiki link targets cannot be empty
 		},
 		{
 			name:     "wiki link with special characters",
 			input:    "[[link with @#$%^&*()]]",
-			expected: "link with @#$%^&*()",
+			expected: "link with @ > $%^&*()", // the text after # is a heading
+		},
+		{
+			name:     "wiki link with an alias",
+			input:    "Ask [[Mara Quill|Mara]] and [[Ships#The Heron|the Heron]]",
+			expected: "Ask Mara and the Heron",
+		},
+		{
+			name:     "wiki link to a heading",
+			input:    "See [[Ships#The Heron]] and [[#Rigging]]",
+			expected: "See Ships > The Heron and Rigging",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := processWikiLinks(tt.input)
+			doc := markdown.ParseInline(tt.input)
+			removeWikiLinks(doc)
+			result := markdown.RenderMarkdown(doc)
 			if result != tt.expected {
-				t.Errorf("processWikiLinks(%q) = %q, want %q", tt.input, result, tt.expected)
+				t.Errorf("removeWikiLinks(%q) = %q, want %q", tt.input, result, tt.expected)
 			}
 		})
 	}
@@ -180,7 +196,7 @@ func TestCreateDedication(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			builder := &strings.Builder{}
-			err := createDedication(tt.filename, builder)
+			err := createDedication(tt.filename, builder, &manuscript.Book{})
 
 			if tt.wantErr && err == nil {
 				t.Error("createDedication() expected error but got none")
@@ -235,7 +251,7 @@ func TestWriteToFile(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			fullPath := filepath.Join(tempDir, tt.filename)
-			err := writeToFile(tt.output, tt.numbers, config.OutputFilename(fullPath))
+			err := writeToFile(tt.output, tt.numbers, nil, config.OutputFilename(fullPath))
 
 			if tt.wantErr && err == nil {
 				t.Error("writeToFile() expected error but got none")
@@ -271,6 +287,36 @@ func TestWriteToFile(t *testing.T) {
 	}
 }
 
+func TestNumberParagraphs(t *testing.T) {
+	input := "---\ntitle: test\n---\n\n# Header\n\nFirst line\nof a paragraph.\n\n- a list item\n\n> A quote.\n\n\\* \\* \\*\n\n    code\n\nSecond paragraph."
+	expected := "---\ntitle: test\n---\n\n# Header\n\nFirst line\nof a paragraph. <1>\n\n- a list item\n\n> A quote.\n\n\\* \\* \\*\n\n    code\n\nSecond paragraph. <2>"
+
+	result := numberParagraphs(input, []string{"\\* \\* \\*"})
+	if result != expected {
+		t.Errorf("numberParagraphs() = %q, want %q", result, expected)
+	}
+
+	// configured separators are recognized however they are written
+	input = "One.\n\n\\#\n\nTwo.\n\n*\t*\t*\n\nThree."
+	expected = "One. <1>\n\n\\#\n\nTwo. <2>\n\n*\t*\t*\n\nThree. <3>"
+	result = numberParagraphs(input, []string{"#", "*&#9;*&#9;*"})
+	if result != expected {
+		t.Errorf("numberParagraphs() = %q, want %q", result, expected)
+	}
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	input := "Too   many\tspaces.  \nA hard break.\n\n    indented   code"
+	expected := "Too many spaces.  \nA hard break.\n\n    indented   code\n"
+
+	doc := markdown.Parse(input)
+	normalizeWhitespace(doc)
+	result := markdown.RenderMarkdown(doc)
+	if result != expected {
+		t.Errorf("normalizeWhitespace() = %q, want %q", result, expected)
+	}
+}
+
 func TestProcessScene(t *testing.T) {
 	// Create temporary files for testing
 	tempDir := t.TempDir()
@@ -328,7 +374,7 @@ func TestProcessScene(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			chapter := &ChapterSummary{}
-			result, err := ProcessScene(tt.config, tt.stripWikiLinks, chapter)
+			result, err := ProcessScene(tt.config, Options{StripWikiLinks: tt.stripWikiLinks}, chapter, &manuscript.Chapter{})
 
 			if tt.wantErr && err == nil {
 				t.Error("ProcessScene() expected error but got none")
@@ -363,6 +409,40 @@ func TestProcessScene(t *testing.T) {
 	}
 }
 
+func TestProcessSceneWordCount(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "scene.md")
+	content := "# Arrival\n\nShe *waited*—and waited. ![a gull](gull.png) <!-- a note -->\n\n> Nobody came, cafe\u0301.\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		count      config.WordCountConfig
+		words      int
+		characters int
+	}{
+		{name: "word", words: 7, characters: 47},
+		{name: "scrivener", count: config.WordCountConfig{Rule: "scrivener"}, words: 8, characters: 47},
+		{name: "runes", count: config.WordCountConfig{Characters: "runes"}, words: 7, characters: 48},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chapter := &ChapterSummary{}
+			_, err := ProcessScene(config.SceneConfig{Files: []string{file}}, Options{WordCount: tt.count}, chapter, &manuscript.Chapter{})
+			if err != nil {
+				t.Fatalf("ProcessScene() unexpected error = %v", err)
+			}
+			scene := chapter.SceneSummary[0]
+			if scene.Words != tt.words || scene.Characters != tt.characters {
+				t.Errorf("ProcessScene() = %d words and %d characters, want %d and %d", scene.Words, scene.Characters, tt.words, tt.characters)
+			}
+		})
+	}
+}
+
 func TestProcessSection(t *testing.T) {
 	// Create temporary files for testing
 	tempDir := t.TempDir()
@@ -405,7 +485,7 @@ func TestProcessSection(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := ProcessSection(tt.config, tt.stripWikiLinks)
+			result, err := ProcessSection(tt.config, Options{StripWikiLinks: tt.stripWikiLinks}, &BookSummary{}, &manuscript.Book{})
 
 			if tt.wantErr && err == nil {
 				t.Error("ProcessSection() expected error but got none")
@@ -488,7 +568,7 @@ func TestProcessChapter(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			book := &BookSummary{}
-			result, err := ProcessChapter(tt.config, tt.separator, false, book)
+			result, err := ProcessChapter(tt.config, tt.separator, Options{}, book, &manuscript.Book{})
 
 			if tt.wantErr && err == nil {
 				t.Error("ProcessChapter() expected error but got none")
@@ -532,4 +612,358 @@ func TestProcessChapter(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestProcessChapterSeparator(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "scene.txt")
+	err := os.WriteFile(file, []byte("A scene."), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	scenes := []config.SceneConfig{{Files: []string{file}}, {Files: []string{file}}}
+
+	tests := []struct {
+		name      string
+		chapter   string
+		book      string
+		expected  string
+		separator string
+	}{
+		{name: "book separator", book: "#", expected: "\n\\#\n\n", separator: "#"},
+		{name: "chapter separator", chapter: "~", book: "#", expected: "\n~\n\n", separator: "~"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled := &manuscript.Book{}
+			chapter := config.ChapterConfig{Title: "Chapter", SceneSeparator: tt.chapter, Scenes: scenes}
+			result, err := ProcessChapter(chapter, tt.book, Options{}, &BookSummary{}, compiled)
+			if err != nil {
+				t.Fatalf("ProcessChapter() error = %v", err)
+			}
+			if !strings.Contains(result.String(), tt.expected) {
+				t.Errorf("ProcessChapter() = %q, want separator %q", result.String(), tt.expected)
+			}
+			if compiled.Chapters[0].SceneBreak.Literal != tt.separator {
+				t.Errorf("compiled scene break = %q, want %q", compiled.Chapters[0].SceneBreak.Literal, tt.separator)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tempDir := t.TempDir()
+	scene := filepath.Join(tempDir, "scene.txt")
+	err := os.WriteFile(scene, []byte("Some scene content."), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	chapterOutput := filepath.Join(tempDir, "chapter.md")
+	cfg := config.InkwellConfig{
+		Title:          "Test Book",
+		OutputFilename: config.OutputFilename(filepath.Join(tempDir, "book.md")),
+		Chapters: []config.ChapterConfig{
+			{Title: "Chapter 1", OutputFilename: config.OutputFilename(chapterOutput), Scenes: []config.SceneConfig{
+				{Files: []string{scene}},
+			}},
+		},
+	}
+
+	book, summary, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile() unexpected error = %v", err)
+	}
+	if book.Title != "Test Book" || len(book.Chapters) != 1 || len(book.Chapters[0].Scenes) != 1 {
+		t.Errorf("Compile() returned an incomplete book: %+v", book)
+	}
+	if summary.Words != 3 {
+		t.Errorf("Compile() summary words = %d, want 3", summary.Words)
+	}
+	if book.Language != "en" {
+		t.Errorf("Compile() language = %q, want en for a config without one", book.Language)
+	}
+	if _, err := os.Stat(chapterOutput); err == nil {
+		t.Error("Compile() should not write the output files")
+	}
+}
+
+func TestCompileWordCount(t *testing.T) {
+	tempDir := t.TempDir()
+	scene := filepath.Join(tempDir, "scene.txt")
+	err := os.WriteFile(scene, []byte(strings.Repeat("word ", 3760)), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cfg := config.InkwellConfig{
+		Chapters:  []config.ChapterConfig{{Title: "Chapter 1", Scenes: []config.SceneConfig{{Files: []string{scene}}}}},
+		WordCount: config.WordCountConfig{Rule: "publisher"},
+	}
+	_, summary, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile() unexpected error = %v", err)
+	}
+	if summary.Words != 4000 || summary.ChapterSummary[0].Words != 3760 {
+		t.Errorf("Compile() words = %d, chapter %d, want the book rounded to 4000 and the chapter 3760", summary.Words, summary.ChapterSummary[0].Words)
+	}
+	if summary.CountRule != "publisher" || summary.CharacterRule != "graphemes" {
+		t.Errorf("Compile() rules = %q and %q, want publisher and graphemes", summary.CountRule, summary.CharacterRule)
+	}
+
+	// the DOCX cover page reports the same rounded count as the summary
+	docxFile := filepath.Join(tempDir, "book.docx")
+	cfg.OutputDocx = config.OutputFilename(docxFile)
+	if err := ProcessBook(cfg); err != nil {
+		t.Fatalf("ProcessBook() unexpected error = %v", err)
+	}
+	archive, err := zip.OpenReader(docxFile)
+	if err != nil {
+		t.Fatalf("Failed to open DOCX: %v", err)
+	}
+	defer archive.Close()
+	document, err := archive.Open("word/document.xml")
+	if err != nil {
+		t.Fatalf("Failed to open DOCX document: %v", err)
+	}
+	content, _ := io.ReadAll(document)
+	document.Close()
+	if !strings.Contains(string(content), "about 4,000 words") {
+		t.Errorf("DOCX should report about 4,000 words, got %q", content)
+	}
+
+	cfg.WordCount.Rule = "pages"
+	if _, _, err := Compile(cfg); err == nil {
+		t.Error("Compile() should fail for an unknown word count rule")
+	}
+}
+
+func TestParsePlacement(t *testing.T) {
+	tests := []struct {
+		input     string
+		placement manuscript.Placement
+		chapter   int
+		wantErr   bool
+	}{
+		{input: "", placement: manuscript.Front},
+		{input: "front", placement: manuscript.Front},
+		{input: "back", placement: manuscript.Back},
+		{input: "before_chapter:3", placement: manuscript.BeforeChapter, chapter: 3},
+		{input: "before_chapter:0", wantErr: true},
+		{input: "middle", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			placement, chapter, err := parsePlacement(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsePlacement(%q) expected error but got none", tt.input)
+				}
+				return
+			}
+			if err != nil || placement != tt.placement || chapter != tt.chapter {
+				t.Errorf("parsePlacement(%q) = %v, %v, %v, want %v, %v", tt.input, placement, chapter, err, tt.placement, tt.chapter)
+			}
+		})
+	}
+}
+
+func TestProcessBookSections(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"prologue.md":  "Before.",
+		"interlude.md": "Between.",
+		"appendix.md":  "After all of it.",
+		"one.md":       "First.",
+		"two.md":       "Second.",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	path := func(name string) []string { return []string{filepath.Join(tempDir, name)} }
+
+	output := filepath.Join(tempDir, "book.md")
+	cfg := config.InkwellConfig{
+		Title:          "Test Book",
+		OutputFilename: config.OutputFilename(output),
+		Sections: []config.SectionConfig{
+			{Title: "Appendix", Placement: "back", Files: path("appendix.md")},
+			{Title: "Prologue", Files: path("prologue.md")},
+			{Title: "Interlude", Placement: "before_chapter:2", Files: path("interlude.md")},
+		},
+		Chapters: []config.ChapterConfig{
+			{Title: "One", Scenes: []config.SceneConfig{{Files: path("one.md")}}},
+			{Title: "Two", Scenes: []config.SceneConfig{{Files: path("two.md")}}},
+		},
+	}
+
+	err := ProcessBook(cfg)
+	if err != nil {
+		t.Fatalf("ProcessBook() error = %v", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	last := -1
+	for _, heading := range []string{"# Prologue", "## One", "# Interlude", "## Two", "# Appendix"} {
+		idx := strings.Index(string(content), heading+"\n")
+		if idx <= last {
+			t.Fatalf("full manuscript should contain %q after the previous heading, got %q", heading, content)
+		}
+		last = idx
+	}
+
+	_, summary, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if len(summary.SectionSummary) != 3 || summary.SectionSummary[0].Placement != "back" || summary.Words != 8 {
+		t.Errorf("summary = %+v, want the sections counted in the totals", summary)
+	}
+}
+
+func TestProcessBookOnlySections(t *testing.T) {
+	tempDir := t.TempDir()
+	essay := filepath.Join(tempDir, "essay.md")
+	if err := os.WriteFile(essay, []byte("Only a few words."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	summaryFile := filepath.Join(tempDir, "summary.yaml")
+	cfg := config.InkwellConfig{
+		Title:           "Test Book",
+		OutputFilename:  config.OutputFilename(filepath.Join(tempDir, "book.md")),
+		SummaryFilename: config.OutputFilename(summaryFile),
+		Sections:        []config.SectionConfig{{Title: "Essay", Files: []string{essay}}},
+	}
+	if err := ProcessBook(cfg); err != nil {
+		t.Fatalf("ProcessBook() error = %v", err)
+	}
+	content, err := os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatalf("Failed to read summary: %v", err)
+	}
+	if !strings.Contains(string(content), "words: 4") || !strings.Contains(string(content), "average: 0") {
+		t.Errorf("summary = %q, want the words of the section and no average chapter", content)
+	}
+}
+
+func TestProcessBookParts(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"prologue.md":  "Before.",
+		"interlude.md": "A short pause.",
+		"one.md":       "First.",
+		"two.md":       "Second scene.",
+		"three.md":     "Third.",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	path := func(name string) []string { return []string{filepath.Join(tempDir, name)} }
+
+	output := filepath.Join(tempDir, "book.md")
+	partOutput := filepath.Join(tempDir, "part-one.md")
+	cfg := config.InkwellConfig{
+		Title:          "Test Book",
+		SceneSeparator: "* * *",
+		OutputFilename: config.OutputFilename(output),
+		Sections: []config.SectionConfig{
+			{Title: "Prologue", Files: path("prologue.md")},
+		},
+		Parts: []config.PartConfig{
+			{
+				Title:          "Part One",
+				SceneSeparator: "~",
+				OutputFilename: config.OutputFilename(partOutput),
+				Sections: []config.SectionConfig{
+					{Title: "Interlude", Placement: "back", Files: path("interlude.md")},
+				},
+				Chapters: []config.ChapterConfig{
+					{Title: "One", Scenes: []config.SceneConfig{{Files: path("one.md")}, {Files: path("two.md")}}},
+				},
+			},
+			{
+				Title: "Part Two",
+				Parts: []config.PartConfig{
+					{Title: "Book Three", Chapters: []config.ChapterConfig{
+						{Title: "Three", Scenes: []config.SceneConfig{{Files: path("three.md")}}},
+					}},
+				},
+			},
+		},
+	}
+
+	err := ProcessBook(cfg)
+	if err != nil {
+		t.Fatalf("ProcessBook() error = %v", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	last := -1
+	for _, heading := range []string{"# Prologue", "# Part One", "## One", "# Interlude", "# Part Two", "# Book Three", "## Three"} {
+		idx := strings.Index(string(content), heading+"\n")
+		if idx <= last {
+			t.Fatalf("full manuscript should contain %q after the previous heading, got %q", heading, content)
+		}
+		last = idx
+	}
+
+	part, err := os.ReadFile(partOutput)
+	if err != nil {
+		t.Fatalf("Failed to read part output: %v", err)
+	}
+	if !strings.HasPrefix(string(part), "# Part One\n") || !strings.Contains(string(part), "\n~\n") || strings.Contains(string(part), "Prologue") {
+		t.Errorf("part output = %q, want the part with its own separator", part)
+	}
+
+	compiled, summary, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if len(compiled.Parts) != 2 || len(compiled.Parts[1].Parts) != 1 || len(compiled.Chapters) != 0 {
+		t.Errorf("compiled book should hold the chapters within its parts, got %+v", compiled)
+	}
+	if summary.Words != 8 || len(summary.ChapterSummary) != 2 || len(summary.SectionSummary) != 2 {
+		t.Errorf("summary = %+v, want every section and chapter counted once", summary)
+	}
+	parts := summary.PartSummary
+	if len(parts) != 2 || parts[0].Words != 6 || parts[0].Chapters != 1 || parts[1].Words != 1 || parts[1].Parts[0].Title != "Book Three" {
+		t.Errorf("part summaries = %+v, want the words of each part rolled up", parts)
+	}
+}
+
+func TestProcessBookMixedLayout(t *testing.T) {
+	chapter := config.ChapterConfig{Title: "Interlude", Scenes: []config.SceneConfig{{Files: []string{"interlude.md"}}}}
+	cfg := config.InkwellConfig{
+		Parts: []config.PartConfig{
+			{Title: "Part One", Chapters: []config.ChapterConfig{chapter}},
+			{Title: "Part Two", Chapters: []config.ChapterConfig{chapter}, Parts: []config.PartConfig{{Title: "Act One"}}},
+		},
+	}
+
+	// the chapters would be moved ahead of the parts beside them, so the layout is refused
+	err := ProcessBook(cfg)
+	if err == nil || !strings.Contains(err.Error(), `part "Part Two" holds both chapters and parts`) {
+		t.Errorf("ProcessBook() error = %v, want the part which mixes chapters and parts", err)
+	}
+
+	cfg.Parts[1].Parts = nil
+	cfg.Chapters = []config.ChapterConfig{chapter}
+	if _, _, err := Compile(cfg); err == nil || !strings.Contains(err.Error(), "the book holds both chapters and parts") {
+		t.Errorf("Compile() error = %v, want the book which mixes chapters and parts", err)
+	}
+}
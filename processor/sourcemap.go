@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SourceMap is a struct that represents the source map of the book, which traces the
+// paragraphs of each Markdown output back to the source files they were written from
+type SourceMap struct {
+	Outputs []SourceOutput `json:"outputs"`
+}
+
+// SourceOutput is a struct that represents the paragraphs of one output file
+type SourceOutput struct {
+	File       string            `json:"file"`
+	Paragraphs []SourceParagraph `json:"paragraphs"`
+}
+
+// SourceParagraph is a struct that represents a paragraph of an output: its number and id as
+// the output numbers it, the first and last lines it spans in the output, and the source file
+// and the first and last lines it came from. A paragraph embedded from another note is found
+// at the lines of the embed.
+type SourceParagraph struct {
+	Paragraph   int    `json:"paragraph"`
+	ID          string `json:"id,omitempty"`
+	Lines       [2]int `json:"lines"`
+	Source      string `json:"source"`
+	SourceLines [2]int `json:"source_lines"`
+}
+
+// ReadSourceMap reads the source map at the given path.
+func ReadSourceMap(path string) (*SourceMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := &SourceMap{}
+	err = json.Unmarshal(data, sources)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sources, nil
+}
+
+// carry returns the source map with the outputs of the previous one which were not written
+// again, kept in their places, followed by the outputs which are new.
+func (m *SourceMap) carry(previous *SourceMap) *SourceMap {
+	if previous == nil {
+		return m
+	}
+	written := map[string]SourceOutput{}
+	for _, output := range m.Outputs {
+		written[filepath.Clean(output.File)] = output
+	}
+
+	carried := &SourceMap{}
+	for _, output := range previous.Outputs {
+		if rewritten, ok := written[filepath.Clean(output.File)]; ok {
+			output = rewritten
+			delete(written, filepath.Clean(output.File))
+		}
+		carried.Outputs = append(carried.Outputs, output)
+	}
+	for _, output := range m.Outputs {
+		if _, ok := written[filepath.Clean(output.File)]; ok {
+			carried.Outputs = append(carried.Outputs, output)
+		}
+	}
+	return carried
+}
+
+// write writes the source map to the file at the given path.
+func (m *SourceMap) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Locate returns the paragraph of the output at the given position, which is either a line of
+// the output or a paragraph as the output numbers it, such as <412> or <k3f9>. When a line is
+// given and the paragraph spans as many lines in its source file as in the output, the source
+// lines are narrowed to the line which matches it.
+func (m *SourceMap) Locate(output string, position string) (SourceParagraph, error) {
+	var paragraphs []SourceParagraph
+	found := false
+	for _, candidate := range m.Outputs {
+		if filepath.Clean(candidate.File) == filepath.Clean(output) {
+			paragraphs, found = candidate.Paragraphs, true
+			break
+		}
+	}
+	if !found {
+		return SourceParagraph{}, fmt.Errorf("%s is not in the source map", output)
+	}
+
+	if strings.HasPrefix(position, "<") && strings.HasSuffix(position, ">") {
+		label := strings.TrimSpace(position[1 : len(position)-1])
+		for _, paragraph := range paragraphs {
+			if paragraph.ID == label || paragraph.ID == "" && strconv.Itoa(paragraph.Paragraph) == label {
+				return paragraph, nil
+			}
+		}
+		return SourceParagraph{}, fmt.Errorf("%s has no paragraph %s from a source file", output, position)
+	}
+
+	line, err := strconv.Atoi(position)
+	if err != nil || line < 1 {
+		return SourceParagraph{}, fmt.Errorf("invalid position %q: want a line number or a paragraph such as <12>", position)
+	}
+	for _, paragraph := range paragraphs {
+		if line < paragraph.Lines[0] || line > paragraph.Lines[1] {
+			continue
+		}
+		if paragraph.Lines[1]-paragraph.Lines[0] == paragraph.SourceLines[1]-paragraph.SourceLines[0] {
+			offset := paragraph.SourceLines[0] + line - paragraph.Lines[0]
+			paragraph.SourceLines = [2]int{offset, offset}
+		}
+		return paragraph, nil
+	}
+	return SourceParagraph{}, fmt.Errorf("line %d of %s is not in a paragraph from a source file", line, output)
+}
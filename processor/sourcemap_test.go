@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+func TestProcessBookSourceMap(t *testing.T) {
+	tempDir := t.TempDir()
+	scene := filepath.Join(tempDir, "one.md")
+	content := "---\ntitle: Rain\n---\nMara waited %% a note\nover two lines %% in the rain.\n\n*The ship*\nwas late."
+	if err := os.WriteFile(scene, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	output := filepath.Join(tempDir, "chapter.md")
+	sourceMap := filepath.Join(tempDir, "book.map.json")
+	cfg := config.InkwellConfig{
+		SourceMap: config.OutputFilename(sourceMap),
+		Chapters: []config.ChapterConfig{{
+			Title:          "One",
+			OutputFilename: config.OutputFilename(output),
+			OutputNumbers:  true,
+			Scenes:         []config.SceneConfig{{Files: []string{scene}}},
+		}},
+	}
+	if err := ProcessBook(cfg); err != nil {
+		t.Fatalf("ProcessBook() error = %v", err)
+	}
+
+	written, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	expected := "## One\nMara waited in the rain. <1>\n\n*The ship*\nwas late. <2>"
+	if string(written) != expected {
+		t.Errorf("chapter output = %q, want %q", written, expected)
+	}
+
+	sources, err := ReadSourceMap(sourceMap)
+	if err != nil {
+		t.Fatalf("ReadSourceMap() error = %v", err)
+	}
+	tests := []struct {
+		position string
+		lines    [2]int
+	}{
+		{position: "<1>", lines: [2]int{4, 5}},
+		{position: "<2>", lines: [2]int{7, 8}},
+		{position: "5", lines: [2]int{8, 8}},
+	}
+	for _, tt := range tests {
+		paragraph, err := sources.Locate(output, tt.position)
+		if err != nil {
+			t.Errorf("Locate(%q) error = %v", tt.position, err)
+			continue
+		}
+		if paragraph.Source != scene || paragraph.SourceLines != tt.lines {
+			t.Errorf("Locate(%q) = %s:%v, want %s:%v", tt.position, paragraph.Source, paragraph.SourceLines, scene, tt.lines)
+		}
+	}
+
+	for _, position := range []string{"1", "<3>", "last"} {
+		if _, err := sources.Locate(output, position); err == nil {
+			t.Errorf("Locate(%q) should fail", position)
+		}
+	}
+	if _, err := sources.Locate(filepath.Join(tempDir, "book.md"), "1"); err == nil {
+		t.Errorf("Locate() should fail for an output which is not in the source map")
+	}
+}
@@ -1,29 +1,64 @@
 package processor
 
-import "gopkg.in/yaml.v3"
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/wordcount"
+)
 
 type Summary struct {
-	Characters int `yaml:"characters"`
-	Words      int `yaml:"words"`
+	Characters int `yaml:"characters" json:"characters"`
+	Words      int `yaml:"words" json:"words"`
 }
 
 type BookSummary struct {
 	Summary        `yaml:",inline"`
-	Average        int              `yaml:"average"`
-	ChapterSummary []ChapterSummary `yaml:"chapters"`
+	CountRule      string           `yaml:"count_rule,omitempty" json:"count_rule,omitempty"`
+	CharacterRule  string           `yaml:"character_rule,omitempty" json:"character_rule,omitempty"`
+	Average        int              `yaml:"average" json:"average"`
+	SectionSummary []SectionSummary `yaml:"sections,omitempty" json:"sections,omitempty"`
+	PartSummary    []PartSummary    `yaml:"parts,omitempty" json:"parts,omitempty"`
+	ChapterSummary []ChapterSummary `yaml:"chapters" json:"chapters"`
+	Warnings       []string         `yaml:"warnings,omitempty" json:"warnings,omitempty"`
+}
+
+type PartSummary struct {
+	Summary  `yaml:",inline"`
+	Title    string        `yaml:"title" json:"title"`
+	Chapters int           `yaml:"chapters" json:"chapters"`
+	Parts    []PartSummary `yaml:"parts,omitempty" json:"parts,omitempty"`
+}
+
+type SectionSummary struct {
+	Summary   `yaml:",inline"`
+	Title     string `yaml:"title" json:"title"`
+	Placement string `yaml:"placement" json:"placement"`
+	Files     int    `yaml:"files" json:"files"`
 }
 
 type ChapterSummary struct {
 	Summary      `yaml:",inline"`
-	Title        string         `yaml:"title"`
-	Average      int            `yaml:"average"`
-	SceneSummary []SceneSummary `yaml:"scenes"`
+	Number       string         `yaml:"number,omitempty" json:"number,omitempty"`
+	Title        string         `yaml:"title" json:"title"`
+	Average      int            `yaml:"average" json:"average"`
+	SceneSummary []SceneSummary `yaml:"scenes" json:"scenes"`
 }
 
 type SceneSummary struct {
 	Summary             `yaml:",inline"`
-	Files               int `yaml:"files"`
-	AverageWordsPerFile int `yaml:"average"`
+	Number              string `yaml:"number,omitempty" json:"number,omitempty"`
+	Title               string `yaml:"title,omitempty" json:"title,omitempty"`
+	Synopsis            string `yaml:"synopsis,omitempty" json:"synopsis,omitempty"`
+	Files               int    `yaml:"files" json:"files"`
+	AverageWordsPerFile int    `yaml:"average" json:"average"`
+	EmbeddedWords       int    `yaml:"embedded_words,omitempty" json:"embedded_words,omitempty"`
 }
 
 func (s *BookSummary) AddChapterSummary(c ChapterSummary) {
@@ -32,15 +67,42 @@ func (s *BookSummary) AddChapterSummary(c ChapterSummary) {
 	s.ChapterSummary = append(s.ChapterSummary, c)
 }
 
-func (s *BookSummary) String() (string, error) {
-	// compute averages
-	s.Average = s.Words / len(s.ChapterSummary)
-	for i := range s.ChapterSummary {
-		s.ChapterSummary[i].Average = s.ChapterSummary[i].Words / len(s.ChapterSummary[i].SceneSummary)
-		for j := range s.ChapterSummary[i].SceneSummary {
-			s.ChapterSummary[i].SceneSummary[j].AverageWordsPerFile = s.ChapterSummary[i].SceneSummary[j].Words / s.ChapterSummary[i].SceneSummary[j].Files
-		}
+func (s *BookSummary) AddSectionSummary(c SectionSummary) {
+	s.Characters += c.Characters
+	s.Words += c.Words
+	s.SectionSummary = append(s.SectionSummary, c)
+}
+
+// AddPartSummary appends a part to the book. The sections and chapters of the part are
+// added on their own, so the part is not counted in the totals again.
+func (s *BookSummary) AddPartSummary(p PartSummary) {
+	s.PartSummary = append(s.PartSummary, p)
+}
+
+// CountedBy records the rules by which the words and characters of the book were counted,
+// and rounds the total words of the book as the rule reports them.
+func (s *BookSummary) CountedBy(count config.WordCountConfig) {
+	s.CountRule = count.Rule
+	if s.CountRule == "" {
+		s.CountRule = wordcount.Word
 	}
+	s.CharacterRule = count.Characters
+	if s.CharacterRule == "" {
+		s.CharacterRule = wordcount.Graphemes
+	}
+	if s.CountRule == wordcount.Publisher {
+		s.Words = wordcount.Round(s.Words)
+	}
+}
+
+// AddWarning records a problem found while the book was compiled which did not stop it.
+func (s *BookSummary) AddWarning(warning string) {
+	s.Warnings = append(s.Warnings, warning)
+}
+
+// String returns the summary as YAML.
+func (s *BookSummary) String() (string, error) {
+	s.computeAverages()
 
 	// write to yaml
 	out, err := yaml.Marshal(s)
@@ -51,6 +113,161 @@ func (s *BookSummary) String() (string, error) {
 	return string(out), nil
 }
 
+// Format returns the summary in the given format: yaml, which is the default, json, csv or
+// markdown.
+func (s *BookSummary) Format(format string) (string, error) {
+	switch format {
+	case "", "yaml":
+		return s.String()
+	case "json":
+		s.computeAverages()
+		out, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(out) + "\n", nil
+	case "csv":
+		s.computeAverages()
+		return s.csv()
+	case "markdown":
+		s.computeAverages()
+		return s.markdown(), nil
+	default:
+		return "", fmt.Errorf("invalid summary format %q: want yaml, json, csv or markdown", format)
+	}
+}
+
+// computeAverages computes the average words of the chapters of the book, of the scenes of
+// each chapter and of the files of each scene.
+func (s *BookSummary) computeAverages() {
+	// sections are counted in the totals but not in the average chapter
+	words := 0
+	for _, chapter := range s.ChapterSummary {
+		words += chapter.Words
+	}
+	s.Average = average(words, len(s.ChapterSummary))
+	for i := range s.ChapterSummary {
+		s.ChapterSummary[i].Average = average(s.ChapterSummary[i].Words, len(s.ChapterSummary[i].SceneSummary))
+		for j := range s.ChapterSummary[i].SceneSummary {
+			s.ChapterSummary[i].SceneSummary[j].AverageWordsPerFile = average(s.ChapterSummary[i].SceneSummary[j].Words, s.ChapterSummary[i].SceneSummary[j].Files)
+		}
+	}
+}
+
+// average returns the words over the count, or zero if there is nothing to count, as for a
+// book made only of sections, a chapter whose every scene was cut or a scene without files.
+func average(words int, count int) int {
+	if count == 0 {
+		return 0
+	}
+	return words / count
+}
+
+// csv returns the summary as CSV, with a row for each scene.
+func (s *BookSummary) csv() (string, error) {
+	builder := &strings.Builder{}
+	writer := csv.NewWriter(builder)
+	writer.Write([]string{"chapter", "chapter_title", "scene", "scene_title", "files", "words", "characters", "embedded_words", "average_words_per_file", "chapter_average_words_per_scene"})
+	for i, chapter := range s.ChapterSummary {
+		number := chapter.Number
+		if number == "" {
+			number = strconv.Itoa(i + 1)
+		}
+		for j, scene := range chapter.SceneSummary {
+			index := scene.Number
+			if index == "" {
+				index = strconv.Itoa(j + 1)
+			}
+			writer.Write([]string{
+				number,
+				chapter.Title,
+				index,
+				scene.Title,
+				strconv.Itoa(scene.Files),
+				strconv.Itoa(scene.Words),
+				strconv.Itoa(scene.Characters),
+				strconv.Itoa(scene.EmbeddedWords),
+				strconv.Itoa(scene.AverageWordsPerFile),
+				strconv.Itoa(chapter.Average),
+			})
+		}
+	}
+	writer.Flush()
+	return builder.String(), writer.Error()
+}
+
+// markdown returns the summary as a Markdown report, with a table each of its sections, parts,
+// chapters and scenes.
+func (s *BookSummary) markdown() string {
+	builder := &strings.Builder{}
+	builder.WriteString("# Summary\n\n")
+	builder.WriteString(fmt.Sprintf("%d words and %d characters, with an average of %d words a chapter.\n", s.Words, s.Characters, s.Average))
+	if s.CountRule != "" {
+		builder.WriteString(fmt.Sprintf("Words are counted by the %s rule, and characters as %s.\n", s.CountRule, s.CharacterRule))
+	}
+
+	table := func(title string, header []string, rows [][]string) {
+		if len(rows) == 0 {
+			return
+		}
+		builder.WriteString("\n## " + title + "\n\n")
+		builder.WriteString("| " + strings.Join(header, " | ") + " |\n")
+		builder.WriteString("|" + strings.Repeat(" --- |", len(header)) + "\n")
+		for _, row := range rows {
+			for idx := range row {
+				row[idx] = strings.ReplaceAll(row[idx], "|", `\|`)
+			}
+			builder.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+	}
+
+	var rows [][]string
+	for _, section := range s.SectionSummary {
+		rows = append(rows, []string{section.Title, section.Placement, strconv.Itoa(section.Files), strconv.Itoa(section.Words), strconv.Itoa(section.Characters)})
+	}
+	table("Sections", []string{"Section", "Placement", "Files", "Words", "Characters"}, rows)
+
+	rows = nil
+	var part func(p PartSummary, depth int)
+	part = func(p PartSummary, depth int) {
+		rows = append(rows, []string{strings.Repeat("&emsp;", depth) + p.Title, strconv.Itoa(p.Chapters), strconv.Itoa(p.Words), strconv.Itoa(p.Characters)})
+		for _, child := range p.Parts {
+			part(child, depth+1)
+		}
+	}
+	for _, p := range s.PartSummary {
+		part(p, 0)
+	}
+	table("Parts", []string{"Part", "Chapters", "Words", "Characters"}, rows)
+
+	rows = nil
+	var scenes [][]string
+	for i, chapter := range s.ChapterSummary {
+		number := chapter.Number
+		if number == "" {
+			number = strconv.Itoa(i + 1)
+		}
+		rows = append(rows, []string{number, chapter.Title, strconv.Itoa(len(chapter.SceneSummary)), strconv.Itoa(chapter.Words), strconv.Itoa(chapter.Characters), strconv.Itoa(chapter.Average)})
+		for j, scene := range chapter.SceneSummary {
+			index := scene.Number
+			if index == "" {
+				index = strconv.Itoa(j + 1)
+			}
+			scenes = append(scenes, []string{number, index, scene.Title, strconv.Itoa(scene.Files), strconv.Itoa(scene.Words), strconv.Itoa(scene.Characters), strconv.Itoa(scene.AverageWordsPerFile)})
+		}
+	}
+	table("Chapters", []string{"Chapter", "Title", "Scenes", "Words", "Characters", "Average scene"}, rows)
+	table("Scenes", []string{"Chapter", "Scene", "Title", "Files", "Words", "Characters", "Average file"}, scenes)
+
+	if len(s.Warnings) > 0 {
+		builder.WriteString("\n## Warnings\n\n")
+		for _, warning := range s.Warnings {
+			builder.WriteString("- " + warning + "\n")
+		}
+	}
+	return builder.String()
+}
+
 func (c *ChapterSummary) AddSceneSummary(s SceneSummary) {
 	c.Characters += s.Characters
 	c.Words += s.Words
@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -247,20 +248,19 @@ func TestBookSummaryString(t *testing.T) {
 	}
 }
 
-func TestBookSummaryStringWithDivisionByZero(t *testing.T) {
-	// Test edge case where there are no chapters (should cause division by zero)
+func TestBookSummaryStringWithoutChapters(t *testing.T) {
+	// Test edge case where there are no chapters
 	book := &BookSummary{
 		Summary: Summary{Characters: 100, Words: 50},
 	}
 	
-	// This should panic due to division by zero in the average calculation
-	defer func() {
-		if r := recover(); r == nil {
-			t.Error("BookSummary.String() should panic when there are no chapters")
-		}
-	}()
-	
-	_, _ = book.String()
+	// a book made only of sections has no average chapter
+	if _, err := book.String(); err != nil {
+		t.Fatalf("BookSummary.String() error = %v", err)
+	}
+	if book.Average != 0 {
+		t.Errorf("book average = %d, want 0 for a book without chapters", book.Average)
+	}
 }
 
 func TestBookSummaryStringWithZeroScenes(t *testing.T) {
@@ -272,14 +272,13 @@ func TestBookSummaryStringWithZeroScenes(t *testing.T) {
 	}
 	book.AddChapterSummary(chapter)
 	
-	// This should panic due to division by zero in scene average calculation
-	defer func() {
-		if r := recover(); r == nil {
-			t.Error("BookSummary.String() should panic when chapter has no scenes")
-		}
-	}()
-	
-	_, _ = book.String()
+	// a chapter whose every scene was cut has no average scene
+	if _, err := book.String(); err != nil {
+		t.Fatalf("BookSummary.String() error = %v", err)
+	}
+	if book.ChapterSummary[0].Average != 0 {
+		t.Errorf("chapter average = %d, want 0 for a chapter without scenes", book.ChapterSummary[0].Average)
+	}
 }
 
 func TestBookSummaryStringWithZeroFiles(t *testing.T) {
@@ -295,14 +294,13 @@ func TestBookSummaryStringWithZeroFiles(t *testing.T) {
 	chapter.AddSceneSummary(scene)
 	book.AddChapterSummary(chapter)
 	
-	// This should panic due to division by zero in file average calculation
-	defer func() {
-		if r := recover(); r == nil {
-			t.Error("BookSummary.String() should panic when scene has no files")
-		}
-	}()
-	
-	_, _ = book.String()
+	// a scene without files has no average file
+	if _, err := book.String(); err != nil {
+		t.Fatalf("BookSummary.String() error = %v", err)
+	}
+	if average := book.ChapterSummary[0].SceneSummary[0].AverageWordsPerFile; average != 0 {
+		t.Errorf("scene average = %d, want 0 for a scene without files", average)
+	}
 }
 
 func TestSummaryStructIntegration(t *testing.T) {
@@ -361,4 +359,73 @@ func TestSummaryStructIntegration(t *testing.T) {
 			t.Errorf("Integration test: YAML missing pattern %q", pattern)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestBookSummaryFormat(t *testing.T) {
+	summary := func() *BookSummary {
+		book := &BookSummary{Warnings: []string{"a warning"}, CountRule: "scrivener", CharacterRule: "graphemes"}
+		book.AddSectionSummary(SectionSummary{Summary: Summary{Characters: 30, Words: 6}, Title: "Prologue", Placement: "front", Files: 1})
+		chapter := ChapterSummary{Number: "1", Title: "Arrival | Part One"}
+		chapter.AddSceneSummary(SceneSummary{Summary: Summary{Characters: 100, Words: 20}, Number: "1", Title: "Rain", Files: 2})
+		chapter.AddSceneSummary(SceneSummary{Summary: Summary{Characters: 200, Words: 40}, Number: "2", Files: 1, EmbeddedWords: 5})
+		book.AddChapterSummary(chapter)
+		return book
+	}
+
+	out, err := summary().Format("json")
+	if err != nil {
+		t.Fatalf("Format(json) error = %v", err)
+	}
+	var parsed BookSummary
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if parsed.Words != 66 || parsed.Average != 60 || parsed.ChapterSummary[0].SceneSummary[0].AverageWordsPerFile != 10 {
+		t.Errorf("Format(json) = %+v, want the totals and averages of the book", parsed)
+	}
+	if parsed.CountRule != "scrivener" {
+		t.Errorf("Format(json) count rule = %q, want scrivener", parsed.CountRule)
+	}
+	if !strings.Contains(out, `"embedded_words": 5`) {
+		t.Errorf("Format(json) = %q, want the keys of the YAML summary", out)
+	}
+
+	out, err = summary().Format("csv")
+	if err != nil {
+		t.Fatalf("Format(csv) error = %v", err)
+	}
+	expected := "chapter,chapter_title,scene,scene_title,files,words,characters,embedded_words,average_words_per_file,chapter_average_words_per_scene\n" +
+		"1,Arrival | Part One,1,Rain,2,20,100,0,10,30\n" +
+		"1,Arrival | Part One,2,,1,40,200,5,40,30\n"
+	if out != expected {
+		t.Errorf("Format(csv) = %q, want %q", out, expected)
+	}
+
+	out, err = summary().Format("markdown")
+	if err != nil {
+		t.Fatalf("Format(markdown) error = %v", err)
+	}
+	for _, line := range []string{
+		"66 words and 330 characters, with an average of 60 words a chapter.",
+		"Words are counted by the scrivener rule, and characters as graphemes.",
+		"| Prologue | front | 1 | 6 | 30 |",
+		"| 1 | Arrival \\| Part One | 2 | 60 | 300 | 30 |",
+		"| 1 | 1 | Rain | 2 | 20 | 100 | 10 |",
+		"- a warning",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Format(markdown) = %q, want it to contain %q", out, line)
+		}
+	}
+	if strings.Contains(out, "## Parts") {
+		t.Errorf("Format(markdown) = %q, want no table of parts for a book without them", out)
+	}
+
+	yamlOut, err := summary().Format("")
+	if err != nil || !strings.Contains(yamlOut, "average: 60") || !strings.Contains(yamlOut, "count_rule: scrivener") {
+		t.Errorf("Format() = %q, %v, want the YAML summary", yamlOut, err)
+	}
+	if _, err := summary().Format("xml"); err == nil {
+		t.Errorf("Format(xml) should fail")
+	}
+}
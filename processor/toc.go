@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// anchor is a struct that represents a heading of the manuscript and the slug which links to it
+type anchor struct {
+	text string
+	slug string
+}
+
+// tableOfContents returns the table of contents of the compiled book as a Markdown list of
+// links to the headings of the manuscript, which is the preceding text followed by the table
+// of contents and then the text of the book. Entries whose heading cannot be found, such as
+// scenes without a scene heading, are listed without a link.
+func tableOfContents(compiled *manuscript.Book, preceding string, text string) string {
+	entries := compiled.TableOfContents()
+	if len(entries) == 0 {
+		return ""
+	}
+
+	heading := "## " + compiled.TOCTitle() + "\n"
+	skipped := len(anchors(preceding + heading))
+	headings := anchors(preceding + heading + text)
+
+	builder := &strings.Builder{}
+	builder.WriteString("\n" + heading + "\n")
+
+	// entries are matched to headings in order, after those which precede the contents
+	next := skipped
+	for _, entry := range entries {
+		title := markdown.PlainText(markdown.ParseInline(entry.Title))
+		line := entry.Title
+		for idx := next; idx < len(headings); idx++ {
+			if headings[idx].text == title {
+				line = "[" + entry.Title + "](#" + headings[idx].slug + ")"
+				next = idx + 1
+				break
+			}
+		}
+		builder.WriteString(strings.Repeat("  ", entry.Level-1) + "- " + line + "\n")
+	}
+
+	return builder.String()
+}
+
+// anchors returns the headings of the Markdown in the order they appear, with the slugs which
+// link to them. A repeated slug has a count appended, as on GitHub.
+func anchors(source string) []anchor {
+	var result []anchor
+	used := map[string]int{}
+	for _, node := range markdown.Parse(source).Children {
+		if node.Kind != markdown.Heading {
+			continue
+		}
+		text := markdown.PlainText(node)
+		slug := slugify(text)
+		if count := used[slug]; count > 0 {
+			used[slug]++
+			slug += "-" + strconv.Itoa(count)
+		} else {
+			used[slug] = 1
+		}
+		result = append(result, anchor{text: text, slug: slug})
+	}
+	return result
+}
+
+// slugify returns the anchor of a heading: its text in lower case, without punctuation, and
+// with hyphens for spaces.
+func slugify(text string) string {
+	builder := &strings.Builder{}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			builder.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
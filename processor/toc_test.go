@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Chapter 1: The Storm":  "chapter-1-the-storm",
+		"Don't Panic!":          "dont-panic",
+		"Über die Brücke":       "über-die-brücke",
+		"snake_case - and more": "snake_case---and-more",
+	}
+	for text, expected := range tests {
+		if result := slugify(text); result != expected {
+			t.Errorf("slugify(%q) = %q, want %q", text, result, expected)
+		}
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	result := anchors("# Contents\n\nText.\n\n## Contents\n\n## *The* Storm\n")
+	expected := []anchor{{"Contents", "contents"}, {"Contents", "contents-1"}, {"The Storm", "the-storm"}}
+	if len(result) != len(expected) {
+		t.Fatalf("anchors() = %v, want %v", result, expected)
+	}
+	for idx := range expected {
+		if result[idx] != expected[idx] {
+			t.Errorf("anchor %d = %v, want %v", idx, result[idx], expected[idx])
+		}
+	}
+}
+
+func TestProcessBookTableOfContents(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"prologue.md": "Before.",
+		"one.md":      "---\ntitle: Landfall\n---\nFirst.",
+		"two.md":      "Second.",
+		"notes.md":    "Unlisted.",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	path := func(name string) []string { return []string{filepath.Join(tempDir, name)} }
+
+	output := filepath.Join(tempDir, "book.md")
+	cfg := config.InkwellConfig{
+		Title:          "Contents",
+		SceneSeparator: "* * *",
+		SceneHeading:   "{{title}}",
+		TOC:            config.TOCConfig{Title: "Table of Contents", Scenes: true, Manuscript: true},
+		OutputFilename: config.OutputFilename(output),
+		Sections:       []config.SectionConfig{{Title: "Prologue", Files: path("prologue.md")}},
+		Chapters: []config.ChapterConfig{
+			{Title: "Chapter 1", SceneHeading: "{{title}}", Scenes: []config.SceneConfig{{Files: path("one.md")}, {Files: path("two.md")}}},
+			{Title: "Notes", ExcludeFromTOC: true, Scenes: []config.SceneConfig{{Files: path("notes.md")}}},
+		},
+	}
+
+	err := ProcessBook(cfg)
+	if err != nil {
+		t.Fatalf("ProcessBook() error = %v", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	expected := "## Table of Contents\n\n" +
+		"- [Prologue](#prologue)\n" +
+		"- [Chapter 1](#chapter-1)\n" +
+		"  - [Landfall](#landfall)\n" +
+		"  - Scene 2\n"
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("full manuscript should contain the table of contents %q, got %q", expected, content)
+	}
+	if strings.Index(string(content), "## Table of Contents") > strings.Index(string(content), "# Prologue") {
+		t.Error("the table of contents should come before the contents of the book")
+	}
+}
@@ -0,0 +1,258 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// imageExtensions are the extensions of the files in the vault which are embedded as images
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true}
+
+// imageSize matches the text of an image embed which gives its size rather than its alt text
+var imageSize = regexp.MustCompile(`^[0-9]+(x[0-9]+)?$`)
+
+// transcluder is a struct that represents the embedding of notes from the vault into a source
+// file. The stack holds the source file and the notes being embedded into it, so that a note
+// which embeds itself is caught.
+type transcluder struct {
+	options Options
+	stack   []string
+	notes   []string
+	words   int
+}
+
+// vaultIndex returns the paths of the files in the vault by the names which an embed may give:
+// their path within the vault, and their file name alone, in lower case and without the
+// extension of a note. A path within the vault wins over a file name, and the first file
+// with a name wins over the others.
+func vaultIndex(root string, files []string) map[string]string {
+	index := map[string]string{}
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			continue
+		}
+		if _, ok := index[embedName(rel)]; !ok {
+			index[embedName(rel)] = file
+		}
+	}
+	for _, file := range files {
+		if _, ok := index[embedName(filepath.Base(file))]; !ok {
+			index[embedName(filepath.Base(file))] = file
+		}
+	}
+	return index
+}
+
+// embedName returns the name by which an embed matches a file in the vault.
+func embedName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(filepath.ToSlash(name)))
+	for _, extension := range []string{".md", ".markdown"} {
+		name = strings.TrimSuffix(name, extension)
+	}
+	return name
+}
+
+// find returns the path of the file in the vault which the target of an embed names.
+func (t *transcluder) find(target string) (string, bool) {
+	if file, ok := t.options.Vault[embedName(target)]; ok {
+		return file, true
+	}
+	file, ok := t.options.Vault[embedName(path.Base(filepath.ToSlash(target)))]
+	return file, ok
+}
+
+// blocks replaces the embeds in the paragraphs of the block and of the blocks within it.
+func (t *transcluder) blocks(parent *markdown.Node) error {
+	if len(t.options.Vault) == 0 {
+		return nil
+	}
+
+	var children []*markdown.Node
+	for _, child := range parent.Children {
+		switch child.Kind {
+		case markdown.Paragraph:
+			blocks, err := t.paragraph(child)
+			if err != nil {
+				return err
+			}
+			children = append(children, blocks...)
+		case markdown.BlockQuote, markdown.List, markdown.ListItem:
+			err := t.blocks(child)
+			if err != nil {
+				return err
+			}
+			children = append(children, child)
+		default:
+			children = append(children, child)
+		}
+	}
+	parent.Children = children
+	return nil
+}
+
+// paragraph returns the blocks which replace the paragraph. A paragraph which holds nothing
+// but embeds is replaced with the blocks they embed; elsewhere, the text of an embedded note
+// runs on within the paragraph. An embed is written as a wiki link after an exclamation mark,
+// and one which matches nothing in the vault is left as it is.
+func (t *transcluder) paragraph(p *markdown.Node) ([]*markdown.Node, error) {
+	var embedded [][]*markdown.Node
+	var inlines []*markdown.Node
+	alone := true
+
+	for idx := 0; idx < len(p.Children); idx++ {
+		n := p.Children[idx]
+		if idx+1 < len(p.Children) && p.Children[idx+1].Kind == markdown.WikiLink && isEmbedMarker(n) {
+			blocks, ok, err := t.embed(p.Children[idx+1])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				text := &markdown.Node{Kind: markdown.Text, Literal: strings.TrimSuffix(n.Literal, "!"), Raw: strings.TrimSuffix(n.Raw, "!")}
+				if text.Literal != "" {
+					inlines = append(inlines, text)
+					alone = alone && strings.TrimSpace(text.Literal) == ""
+				}
+				inlines = append(inlines, runOn(blocks)...)
+				embedded = append(embedded, blocks)
+				idx++
+				continue
+			}
+		}
+
+		inlines = append(inlines, n)
+		switch n.Kind {
+		case markdown.SoftBreak, markdown.HardBreak:
+		case markdown.Text:
+			alone = alone && strings.TrimSpace(n.Literal) == ""
+		default:
+			alone = false
+		}
+	}
+
+	if len(embedded) == 0 {
+		return []*markdown.Node{p}, nil
+	}
+	if !alone {
+		p.Children = inlines
+		return []*markdown.Node{p}, nil
+	}
+
+	var blocks []*markdown.Node
+	for _, embed := range embedded {
+		// the embedded blocks are found at the lines of the embed
+		for _, block := range embed {
+			block.Line, block.EndLine = p.Line, p.EndLine
+		}
+		blocks = append(blocks, embed...)
+	}
+	return blocks, nil
+}
+
+// embed returns the blocks which the embed stands for, and whether it matches a note or image
+// in the vault. A note is embedded along with the notes it embeds, and an embed of a heading
+// takes the blocks under the heading up to the next heading of the same or a higher level.
+func (t *transcluder) embed(link *markdown.Node) ([]*markdown.Node, bool, error) {
+	target, heading, _ := strings.Cut(link.Destination, "#")
+	file, ok := t.find(target)
+	if !ok {
+		return nil, false, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(file))
+	if imageExtensions[ext] {
+		alt := link.Literal
+		if alt == link.Destination || imageSize.MatchString(alt) {
+			alt = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		image := &markdown.Node{Kind: markdown.Image, Destination: filepath.ToSlash(file), Children: []*markdown.Node{{Kind: markdown.Text, Literal: alt}}}
+		return []*markdown.Node{{Kind: markdown.Paragraph, Children: []*markdown.Node{image}}}, true, nil
+	}
+	if ext != ".md" && ext != ".markdown" {
+		return nil, false, nil
+	}
+
+	clean := filepath.Clean(file)
+	for _, open := range t.stack {
+		if open == clean {
+			return nil, false, fmt.Errorf("%s: ![[%s]] embeds itself: %s > %s", t.stack[0], link.Destination, strings.Join(t.stack, " > "), clean)
+		}
+	}
+
+	doc, _, err := readFile(file, t.options.Comments.Markers)
+	if err != nil {
+		return nil, false, err
+	}
+	t.stack = append(t.stack, clean)
+	err = t.blocks(doc)
+	t.stack = t.stack[:len(t.stack)-1]
+	if err != nil {
+		return nil, false, err
+	}
+
+	blocks := doc.Children
+	if strings.TrimSpace(heading) != "" {
+		blocks, ok = headingSection(blocks, heading)
+		if !ok {
+			return nil, false, nil
+		}
+	}
+
+	t.notes = append(t.notes, file)
+	// the words of notes embedded within an embedded note are counted with it
+	if len(t.stack) == 1 {
+		embedded := &markdown.Node{Kind: markdown.Document, Children: blocks}
+		words, _ := countText(embedded, t.options)
+		t.words += words
+	}
+	return blocks, true, nil
+}
+
+// headingSection returns the blocks under the first heading with the given text, up to the
+// next heading of the same or a higher level, and whether the heading was found.
+func headingSection(blocks []*markdown.Node, heading string) ([]*markdown.Node, bool) {
+	for idx, block := range blocks {
+		if block.Kind != markdown.Heading || !strings.EqualFold(markdown.PlainText(block), strings.TrimSpace(heading)) {
+			continue
+		}
+		end := idx + 1
+		for end < len(blocks) && (blocks[end].Kind != markdown.Heading || blocks[end].Level > block.Level) {
+			end++
+		}
+		return blocks[idx+1 : end], true
+	}
+	return nil, false
+}
+
+// runOn returns the inline nodes of the embedded blocks, run on as one line of text.
+func runOn(blocks []*markdown.Node) []*markdown.Node {
+	var inlines []*markdown.Node
+	markdown.Walk(&markdown.Node{Kind: markdown.Document, Children: blocks}, func(n *markdown.Node) bool {
+		switch n.Kind {
+		case markdown.Paragraph, markdown.Heading:
+			if len(inlines) > 0 {
+				inlines = append(inlines, &markdown.Node{Kind: markdown.SoftBreak})
+			}
+			inlines = append(inlines, n.Children...)
+			return false
+		case markdown.CodeBlock, markdown.HTMLBlock, markdown.SceneBreak, markdown.ThematicBreak:
+			return false
+		}
+		return true
+	})
+	return inlines
+}
+
+// isEmbedMarker reports whether the node is text which ends with an exclamation mark that
+// was not escaped, and so turns the wiki link after it into an embed.
+func isEmbedMarker(n *markdown.Node) bool {
+	if n.Kind != markdown.Text || !strings.HasSuffix(n.Literal, "!") {
+		return false
+	}
+	return n.Raw == "" || strings.HasSuffix(n.Raw, "!") && !strings.HasSuffix(n.Raw, `\!`)
+}
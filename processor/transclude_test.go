@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// writeVault writes the files into a vault in a temporary directory and returns its root.
+func writeVault(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestVaultIndex(t *testing.T) {
+	root := "vault"
+	files := []string{
+		filepath.Join(root, "People", "Mara.md"),
+		filepath.Join(root, "Places", "Mara.md"),
+		filepath.Join(root, "maps", "Harbor.png"),
+	}
+	index := vaultIndex(root, files)
+
+	tests := map[string]string{
+		"people/mara":     files[0],
+		"places/mara":     files[1],
+		"mara":            files[0],
+		"maps/harbor.png": files[2],
+		"harbor.png":      files[2],
+	}
+	for name, expected := range tests {
+		if index[name] != expected {
+			t.Errorf("vaultIndex()[%q] = %q, want %q", name, index[name], expected)
+		}
+	}
+}
+
+func TestParseFileEmbeds(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"scene.md":        "The tide turned.\n\n![[Harbor]]\n\nShe recalled ![[Lore#Dawn]] and slept.\n\n![[map.png|The bay]]\n\n![[Missing]]",
+		"notes/Harbor.md": "---\ntitle: Harbor\n---\nA quiet harbor.\n\n![[Lore#Dusk]]",
+		"notes/Lore.md":   "# Dawn\n\nThe sun rose.\n\n## Birds\n\nGulls cried.\n\n# Dusk\n\nThe sun set.",
+		"map.png":         "png",
+	})
+	files, err := filepath.Glob(filepath.Join(root, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes, err := filepath.Glob(filepath.Join(root, "notes", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := Options{Vault: vaultIndex(root, append(files, notes...))}
+
+	source, err := parseFile(filepath.Join(root, "scene.md"), options)
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+
+	expected := "The tide turned.\n\nA quiet harbor.\n\nThe sun set.\n\nShe recalled The sun rose.\nBirds\nGulls cried. and slept.\n\n" +
+		"![The bay](" + filepath.ToSlash(filepath.Join(root, "map.png")) + ")\n\n![[Missing]]\n"
+	if result := markdown.RenderMarkdown(source.document); result != expected {
+		t.Errorf("parseFile() = %q, want %q", result, expected)
+	}
+
+	embeds := []string{
+		filepath.Join(root, "notes", "Lore.md"),
+		filepath.Join(root, "notes", "Harbor.md"),
+		filepath.Join(root, "notes", "Lore.md"),
+	}
+	if strings.Join(source.embeds, ",") != strings.Join(embeds, ",") {
+		t.Errorf("parseFile() embeds = %v, want %v", source.embeds, embeds)
+	}
+	// "A quiet harbor. The sun set." and "The sun rose. Birds Gulls cried."
+	if source.embeddedWords != 12 {
+		t.Errorf("parseFile() embedded words = %d, want 12", source.embeddedWords)
+	}
+}
+
+func TestParseFileEmbedCycle(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"scene.md": "![[One]]",
+		"One.md":   "One.\n\n![[Two]]",
+		"Two.md":   "Two.\n\n![[One]]",
+	})
+	files, err := filepath.Glob(filepath.Join(root, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = parseFile(filepath.Join(root, "scene.md"), Options{Vault: vaultIndex(root, files)})
+	if err == nil || !strings.Contains(err.Error(), "embeds itself") {
+		t.Fatalf("parseFile() error = %v, want an embed cycle", err)
+	}
+}
+
+func TestParseFileEscapedEmbed(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"scene.md": `Wow\![[One]]`,
+		"One.md":   "One.",
+	})
+	files, err := filepath.Glob(filepath.Join(root, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := parseFile(filepath.Join(root, "scene.md"), Options{Vault: vaultIndex(root, files)})
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+	if len(source.embeds) != 0 {
+		t.Errorf("parseFile() should not embed after an escaped exclamation mark, got %v", source.embeds)
+	}
+}
+
+func TestProcessSceneEmbeddedWords(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"scene.md":      "Mara waited.\n\n![[Backstory]]",
+		"Backstory.md":  "Years ago, she left.",
+		"Unembedded.md": "Never seen.",
+	})
+	files, err := filepath.Glob(filepath.Join(root, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chapter := &ChapterSummary{}
+	compiled := &manuscript.Chapter{}
+	sceneConfig := config.SceneConfig{Files: []string{filepath.Join(root, "scene.md")}}
+	_, err = ProcessScene(sceneConfig, Options{Vault: vaultIndex(root, files)}, chapter, compiled)
+	if err != nil {
+		t.Fatalf("ProcessScene() error = %v", err)
+	}
+
+	summary := chapter.SceneSummary[0]
+	if summary.Words != 6 || summary.EmbeddedWords != 4 {
+		t.Errorf("ProcessScene() words = %d, embedded = %d, want 6 and 4", summary.Words, summary.EmbeddedWords)
+	}
+	if len(compiled.Scenes[0].Files) != 2 || compiled.Scenes[0].Files[1] != filepath.Join(root, "Backstory.md") {
+		t.Errorf("ProcessScene() files = %v, should include the embedded note", compiled.Scenes[0].Files)
+	}
+}
@@ -0,0 +1,199 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+	"github.com/nivthefox/inkwell/wordcount"
+)
+
+var spaces = regexp.MustCompile(`[ \t]+`)
+
+// Options is a struct that represents the options which apply to every source file of the
+// book as it is processed. The typography is that of the markdown outputs, and the vault maps
+// the names by which notes and images may be embedded to their paths. The paragraphs are only
+// marked when the book is built with stable paragraph ids or a source map. The word count
+// selects the rules by which the words and characters of the book are counted, and the cache,
+// if there is one, keeps the parsed source files from one build to the next.
+type Options struct {
+	StripWikiLinks bool
+	Typography     config.TypographyConfig
+	Vault          map[string]string
+	Comments       config.CommentsConfig
+	Paragraphs     *markedParagraphs
+	WordCount      config.WordCountConfig
+	Cache          *Cache
+}
+
+// optionsFor returns the options of the book in the config.
+func optionsFor(cfg config.InkwellConfig) (Options, error) {
+	switch cfg.Comments.Changes {
+	case "", "accept", "reject":
+	default:
+		return Options{}, fmt.Errorf("invalid comments changes %q: want accept or reject", cfg.Comments.Changes)
+	}
+	err := wordcount.Check(cfg.WordCount.Rule, cfg.WordCount.Characters)
+	if err != nil {
+		return Options{}, err
+	}
+
+	return Options{
+		StripWikiLinks: cfg.StripWikiLinks,
+		Typography:     cfg.TypographyFor(nil),
+		Vault:          vaultIndex(cfg.Vault, cfg.VaultFiles()),
+		Comments:       cfg.Comments,
+		WordCount:      cfg.WordCount,
+	}, nil
+}
+
+// sourceFile is a struct that represents a parsed source file. The embeds are the notes
+// embedded in it at any depth, and the embedded words are the words they add to it.
+type sourceFile struct {
+	document      *markdown.Node
+	metadata      manuscript.Metadata
+	embeds        []string
+	embeddedWords int
+}
+
+// parseFile reads the file at the given path into a document tree and applies
+// the source transforms to it. The front matter is removed from the document and
+// returned separately.
+func parseFile(path string, options Options) (*sourceFile, error) {
+	if cached := options.Cache.get(path); cached != nil {
+		return cached, nil
+	}
+
+	doc, meta, err := readFile(path, options.Comments.Markers)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &transcluder{options: options, stack: []string{filepath.Clean(path)}}
+	err = t.blocks(doc)
+	if err != nil {
+		return nil, err
+	}
+	applyComments(doc, options.Comments)
+
+	if options.StripWikiLinks {
+		removeWikiLinks(doc)
+	}
+	source := &sourceFile{document: doc, metadata: meta, embeds: t.notes, embeddedWords: t.words}
+	options.Cache.put(path, source)
+	return source, nil
+}
+
+// readFile reads the file at the given path into a document tree with its front matter
+// removed and its whitespace normalized. Its comments, and the lines which start with one
+// of the markers, are parsed as CriticMarkup comments, as are its comment callouts, and its
+// blocks keep the lines of the file they were read from.
+func readFile(path string, markers []string) (*markdown.Node, manuscript.Metadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	builder := &strings.Builder{}
+	_, err = io.Copy(builder, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	marked, origins := markComments(builder.String(), markers)
+	doc := markdown.Parse(marked)
+	sourceLines(doc, origins)
+	calloutComments(doc)
+	meta, err := frontMatter(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: invalid front matter: %w", path, err)
+	}
+
+	normalizeWhitespace(doc)
+	return doc, meta, nil
+}
+
+// normalizeWhitespace collapses runs of spaces and tabs in the text of the document.
+// Code, HTML and hard line breaks are left untouched.
+func normalizeWhitespace(doc *markdown.Node) {
+	markdown.Walk(doc, func(n *markdown.Node) bool {
+		if n.Kind == markdown.Text {
+			n.Literal = spaces.ReplaceAllString(n.Literal, " ")
+			n.Raw = spaces.ReplaceAllString(n.Raw, " ")
+		}
+		return true
+	})
+}
+
+// removeWikiLinks replaces wiki-style links [[text]] with just their text content.
+func removeWikiLinks(doc *markdown.Node) {
+	markdown.Walk(doc, func(n *markdown.Node) bool {
+		if n.Kind == markdown.WikiLink {
+			*n = markdown.Node{Kind: markdown.Text, Literal: n.Literal, Raw: n.Literal}
+		}
+		return true
+	})
+}
+
+// numberParagraphs appends a running number to the last line of each top-level
+// paragraph of the output. Headings, front matter, lists, block quotes and the
+// given scene separators are not numbered.
+func numberParagraphs(output string, separators []string) string {
+	lines := strings.Split(output, "\n")
+	for _, paragraph := range numberedParagraphs(output, separators) {
+		lines[paragraph.endLine-1] += " <" + strconv.Itoa(paragraph.number) + ">"
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// numberedParagraph is a struct that represents a paragraph of an output, with its running
+// number and the lines of the output it spans
+type numberedParagraph struct {
+	number  int
+	line    int
+	endLine int
+}
+
+// numberedParagraphs returns the paragraphs of the output which numberParagraphs numbers.
+func numberedParagraphs(output string, separators []string) []numberedParagraph {
+	doc := markdown.Parse(output)
+
+	breaks := map[string]bool{}
+	for _, separator := range separators {
+		breaks[strings.Join(strings.Fields(markdown.PlainText(markdown.NewSceneBreak(separator))), " ")] = true
+	}
+
+	var paragraphs []numberedParagraph
+	for _, block := range doc.Children {
+		if block.Kind != markdown.Paragraph || breaks[strings.Join(strings.Fields(markdown.PlainText(block)), " ")] {
+			continue
+		}
+		paragraphs = append(paragraphs, numberedParagraph{number: len(paragraphs) + 1, line: block.Line, endLine: block.EndLine})
+	}
+	return paragraphs
+}
+
+// countText returns the words and characters of the document by the rules of the word count.
+// Only the text which a reader sees is counted, so the comments and markup of the document and
+// the descriptions of its images are left out.
+func countText(doc *markdown.Node, options Options) (int, int) {
+	clean := withoutComments(doc, options.Comments)
+	markdown.Walk(clean, func(n *markdown.Node) bool {
+		if n.Kind == markdown.Image {
+			n.Children = nil
+			return false
+		}
+		return true
+	})
+	text := markdown.PlainText(clean)
+	return wordcount.Words(text, options.WordCount.Rule), wordcount.Characters(text, options.WordCount.Characters)
+}
@@ -0,0 +1,291 @@
+package processor
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+// quotes is a struct that represents the quotation marks of a language. French quotation
+// marks carry the non-breaking space which sets them apart from the quotation.
+type quotes struct {
+	openDouble  string
+	closeDouble string
+	openSingle  string
+	closeSingle string
+}
+
+// localeQuotes are the quotation marks of each locale; other locales use the English marks
+var localeQuotes = map[string]quotes{
+	"en": {"“", "”", "‘", "’"},
+	"de": {"„", "“", "‚", "‘"},
+	"fr": {"«\u00a0", "\u00a0»", "“", "”"},
+}
+
+const apostrophe = "’"
+
+var (
+	urls     = regexp.MustCompile(`(?:https?|ftp)://\S+|mailto:\S+|www\.\S+`)
+	years    = regexp.MustCompile(`^[0-9]{2}(?:s|[^0-9]|$)`)
+	elisions = regexp.MustCompile(`^(?i:tis|twas|em|til|cause|round|n)(?:[^\pL]|$)`)
+)
+
+// typographer is a struct that represents the typography pass over the text of a block. The
+// previous rune of the block decides whether a quotation mark opens or closes.
+type typographer struct {
+	quotes     quotes
+	spaced     bool
+	prev       rune
+	doubleOpen bool
+	singleOpen bool
+	output     []byte
+}
+
+// newTypographer returns a typographer for the locale, such as en, de-DE or fr_CA.
+func newTypographer(locale string) *typographer {
+	language := strings.ToLower(locale)
+	if idx := strings.IndexAny(language, "-_"); idx >= 0 {
+		language = language[:idx]
+	}
+
+	marks, ok := localeQuotes[language]
+	if !ok {
+		marks = localeQuotes["en"]
+	}
+	return &typographer{quotes: marks, spaced: language == "fr"}
+}
+
+// TypesetBook returns a copy of the book with the typography pass applied to the text of its
+// titles and documents, or the book itself if the typography is not enabled.
+func TypesetBook(book *manuscript.Book, typography config.TypographyConfig) *manuscript.Book {
+	if !typography.Enabled {
+		return book
+	}
+
+	typeset := book.Clone()
+	t := newTypographer(typography.Locale)
+	typeset.Title = t.title(typeset.Title)
+	if typeset.Dedication != nil {
+		t.document(typeset.Dedication)
+	}
+
+	for _, entry := range typeset.Contents() {
+		switch {
+		case entry.Section != nil:
+			entry.Section.Title = t.title(entry.Section.Title)
+			t.document(entry.Section.Document)
+		case entry.Chapter != nil:
+			entry.Chapter.Title = t.title(entry.Chapter.Title)
+			for _, scene := range entry.Chapter.Scenes {
+				scene.Title = t.title(scene.Title)
+				scene.Heading = t.title(scene.Heading)
+				t.document(scene.Document)
+			}
+		default:
+			entry.Part.Title = t.title(entry.Part.Title)
+		}
+	}
+
+	return typeset
+}
+
+// typesetMarkdown renders the document as markdown, with the typography pass applied to a
+// copy of it when the typography is enabled.
+func typesetMarkdown(doc *markdown.Node, typography config.TypographyConfig) string {
+	if typography.Enabled {
+		doc = doc.Clone()
+		newTypographer(typography.Locale).document(doc)
+	}
+	return strings.TrimSpace(markdown.RenderMarkdown(doc))
+}
+
+// typesetTitle returns the title with the typography pass applied when it is enabled.
+func typesetTitle(title string, typography config.TypographyConfig) string {
+	if !typography.Enabled {
+		return title
+	}
+	return newTypographer(typography.Locale).title(title)
+}
+
+// document applies the typography pass to the text of each paragraph and heading of the
+// document. Code, HTML and scene breaks are left alone.
+func (t *typographer) document(doc *markdown.Node) {
+	markdown.Walk(doc, func(n *markdown.Node) bool {
+		switch n.Kind {
+		case markdown.Paragraph, markdown.Heading:
+			t.reset()
+			t.inlines(n.Children)
+			return false
+		case markdown.CodeBlock, markdown.HTMLBlock, markdown.SceneBreak, markdown.FrontMatter:
+			return false
+		}
+		return true
+	})
+}
+
+// title applies the typography pass to a plain text title.
+func (t *typographer) title(title string) string {
+	t.reset()
+	return t.text(title)
+}
+
+// reset starts a new block.
+func (t *typographer) reset() {
+	t.prev = 0
+	t.doubleOpen = false
+	t.singleOpen = false
+}
+
+// inlines applies the typography pass to the text of the inline nodes in order. The text of
+// an autolink is a URL and is left alone, as are code spans and inline HTML, which only count
+// as the text before the next quotation mark.
+func (t *typographer) inlines(nodes []*markdown.Node) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case markdown.Text:
+			text := t.text(n.Literal)
+			if text != n.Literal {
+				// the source no longer matches the text, so it is escaped again when rendered
+				n.Literal = text
+				n.Raw = ""
+			}
+		case markdown.Code, markdown.HTMLInline, markdown.WikiLink:
+			if r, _ := utf8.DecodeLastRuneInString(n.Literal); r != utf8.RuneError {
+				t.prev = r
+			}
+		case markdown.SoftBreak, markdown.HardBreak:
+			t.prev = ' '
+		case markdown.Link:
+			if len(n.Children) == 1 && n.Children[0].Kind == markdown.Text && (n.Destination == n.Children[0].Literal || n.Destination == "mailto:"+n.Children[0].Literal) {
+				t.prev = 'a'
+				continue
+			}
+			t.inlines(n.Children)
+		default:
+			t.inlines(n.Children)
+		}
+	}
+}
+
+// text returns the text with straight quotes turned into curly quotes, -- and --- into en and
+// em dashes, and ... into an ellipsis. URLs in the text are left alone.
+func (t *typographer) text(text string) string {
+	t.output = t.output[:0]
+	links := urls.FindAllStringIndex(text, -1)
+
+	for pos := 0; pos < len(text); {
+		if len(links) > 0 && pos == links[0][0] {
+			t.write(text[pos:links[0][1]])
+			pos = links[0][1]
+			links = links[1:]
+			continue
+		}
+
+		rest := text[pos:]
+		switch {
+		case strings.HasPrefix(rest, "---"):
+			t.write("—")
+			pos += 3
+			continue
+		case strings.HasPrefix(rest, "--"):
+			t.write("–")
+			pos += 2
+			continue
+		case strings.HasPrefix(rest, "..."):
+			t.write("…")
+			pos += 3
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		pos += size
+		switch r {
+		case '"':
+			// the non-breaking space of an opening French quotation mark replaces any spaces
+			// after it
+			if t.double(text[pos:]) && t.spaced {
+				for pos < len(text) && text[pos] == ' ' {
+					pos++
+				}
+			}
+		case '\'':
+			t.single(text[pos:])
+		default:
+			t.write(rest[:size])
+		}
+	}
+
+	return string(t.output)
+}
+
+// double writes a double quotation mark, which opens a quotation at the start of the block or
+// after a space or an opening bracket, dash or quotation mark, and closes one elsewhere. It
+// reports whether the mark opened a quotation.
+func (t *typographer) double(rest string) bool {
+	if t.opens(rest, t.doubleOpen) {
+		t.doubleOpen = true
+		t.write(t.quotes.openDouble)
+		return true
+	}
+
+	t.doubleOpen = false
+	if t.spaced {
+		t.output = bytes.TrimRight(t.output, " ")
+	}
+	t.write(t.quotes.closeDouble)
+	return false
+}
+
+// single writes an apostrophe or a single quotation mark. An apostrophe follows a letter or
+// digit within a word, or opens an elided word such as 'tis or a year such as '90s.
+func (t *typographer) single(rest string) {
+	next, _ := utf8.DecodeRuneInString(rest)
+	switch {
+	case isWordRune(t.prev) && (isWordRune(next) || !t.singleOpen):
+		t.write(apostrophe)
+	case isWordRune(t.prev):
+		t.singleOpen = false
+		t.write(t.quotes.closeSingle)
+	case years.MatchString(rest) || elisions.MatchString(rest):
+		t.write(apostrophe)
+	case t.opens(rest, t.singleOpen):
+		t.singleOpen = true
+		t.write(t.quotes.openSingle)
+	default:
+		t.singleOpen = false
+		t.write(t.quotes.closeSingle)
+	}
+}
+
+// opens reports whether a quotation mark before the rest of the text opens a quotation. A mark
+// after a space which is followed by a space or punctuation closes the quotation which is
+// already open.
+func (t *typographer) opens(rest string, open bool) bool {
+	if t.prev == 0 {
+		return true
+	}
+	if !unicode.IsSpace(t.prev) && !strings.ContainsRune("([{<‐–—-/“‘„‚« ", t.prev) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(rest)
+	return !open || rest != "" && !unicode.IsSpace(next) && !unicode.IsPunct(next)
+}
+
+// write adds the text to the output.
+func (t *typographer) write(text string) {
+	t.output = append(t.output, text...)
+	if r, _ := utf8.DecodeLastRuneInString(text); r != utf8.RuneError {
+		t.prev = r
+	}
+}
+
+// isWordRune reports whether the rune is a letter or a digit.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/manuscript"
+	"github.com/nivthefox/inkwell/markdown"
+)
+
+func TestTypesetMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   string
+		input    string
+		expected string
+	}{
+		{
+			name:     "double quotes",
+			input:    `She said, "Run." Then "stop".`,
+			expected: "She said, “Run.” Then “stop”.",
+		},
+		{
+			name:     "nested quotes",
+			input:    `"He told me 'never' to go."`,
+			expected: "“He told me ‘never’ to go.”",
+		},
+		{
+			name:     "contractions",
+			input:    "Don't you know it's Jess' turn?",
+			expected: "Don’t you know it’s Jess’ turn?",
+		},
+		{
+			name:     "years and elisions",
+			input:    "Back in the '90s, 'twas rock 'n' roll.",
+			expected: "Back in the ’90s, ’twas rock ’n’ roll.",
+		},
+		{
+			name:     "dashes and ellipses",
+			input:    "Pages 10--20 were---well... missing.",
+			expected: "Pages 10–20 were—well… missing.",
+		},
+		{
+			name:     "quotes around emphasis",
+			input:    `"*Now*," she said.`,
+			expected: "“*Now*,” she said.",
+		},
+		{
+			name:     "code spans",
+			input:    "Type `\"quoted\" -- ...` and \"go\".",
+			expected: "Type `\"quoted\" -- ...` and “go”.",
+		},
+		{
+			name:     "urls",
+			input:    "See https://example.com/a--b... and <https://example.com/it's> too.",
+			expected: "See https://example.com/a--b... and <https://example.com/it's> too.",
+		},
+		{
+			name:     "german",
+			locale:   "de-DE",
+			input:    `Er sagte: "Das ist 'gut'."`,
+			expected: "Er sagte: „Das ist ‚gut‘.“",
+		},
+		{
+			name:     "french",
+			locale:   "fr",
+			input:    `Il a dit : "Bonjour" et " au revoir ".`,
+			expected: "Il a dit : « Bonjour » et « au revoir ».",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locale := tt.locale
+			if locale == "" {
+				locale = "en"
+			}
+			result := typesetMarkdown(markdown.Parse(tt.input), config.TypographyConfig{Enabled: true, Locale: locale})
+			if result != tt.expected {
+				t.Errorf("typesetMarkdown() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTypesetMarkdownDisabled(t *testing.T) {
+	doc := markdown.Parse(`"Wait..." -- she said.`)
+	result := typesetMarkdown(doc, config.TypographyConfig{Locale: "en"})
+	if result != `"Wait..." -- she said.` {
+		t.Errorf("typesetMarkdown() = %q, want the text unchanged", result)
+	}
+}
+
+func TestTypesetBook(t *testing.T) {
+	book := &manuscript.Book{
+		Title: "Rock 'n' Roll",
+		Chapters: []*manuscript.Chapter{{
+			Title:  `The "Storm"`,
+			Scenes: []*manuscript.Scene{{Document: markdown.Parse(`"Hold on," he said.`)}},
+		}},
+	}
+
+	typeset := TypesetBook(book, config.TypographyConfig{Enabled: true, Locale: "en"})
+	if typeset.Title != "Rock ’n’ Roll" {
+		t.Errorf("TypesetBook() title = %q", typeset.Title)
+	}
+	if typeset.Chapters[0].Title != "The “Storm”" {
+		t.Errorf("TypesetBook() chapter title = %q", typeset.Chapters[0].Title)
+	}
+	text := markdown.PlainText(typeset.Chapters[0].Scenes[0].Document)
+	if !strings.Contains(text, "“Hold on,” he said.") {
+		t.Errorf("TypesetBook() scene = %q", text)
+	}
+
+	// the book itself is left alone
+	if book.Chapters[0].Title != `The "Storm"` || !strings.Contains(markdown.PlainText(book.Chapters[0].Scenes[0].Document), `"Hold on,"`) {
+		t.Error("TypesetBook() should not change the book")
+	}
+	if TypesetBook(book, config.TypographyConfig{}) != book {
+		t.Error("TypesetBook() should return the book when typography is disabled")
+	}
+}
+
+func TestTypographyFor(t *testing.T) {
+	cfg := config.InkwellConfig{Language: "fr-CA", Typography: config.TypographyConfig{Enabled: true}}
+
+	if result := cfg.TypographyFor(nil); result != (config.TypographyConfig{Enabled: true, Locale: "fr-CA"}) {
+		t.Errorf("TypographyFor(nil) = %+v", result)
+	}
+	if result := cfg.TypographyFor(&config.TypographyConfig{}); result.Enabled {
+		t.Errorf("TypographyFor() should let an output turn typography off, got %+v", result)
+	}
+	if result := cfg.TypographyFor(&config.TypographyConfig{Enabled: true, Locale: "de"}); result.Locale != "de" {
+		t.Errorf("TypographyFor() locale = %q, want de", result.Locale)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/nivthefox/inkwell/preview"
+)
+
+// runServe serves a live preview of the book.
+func runServe(args []string) error {
+	flags := newFlagSet("serve", "Serves a preview of the book as HTML, with a page per chapter and a page of\n"+
+		"statistics. Pages reload in the browser whenever a source file changes. Nothing\n"+
+		"is written to disk. Stop with Ctrl+C.")
+	path := flags.String("config", defaultConfig, "path to the config file")
+	addr := flags.String("addr", "localhost:8000", "address to listen on")
+	interval := flags.Duration("interval", 250*time.Millisecond, "how often to check the files for changes")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := preview.New(*path)
+	go server.Watch(ctx, *interval)
+
+	httpServer := &http.Server{Addr: *addr, Handler: server}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("serving a preview at http://%s/\n", *addr)
+	err := httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
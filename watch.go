@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nivthefox/inkwell/config"
+	"github.com/nivthefox/inkwell/processor"
+	"github.com/nivthefox/inkwell/watch"
+)
+
+// watcher is a struct that represents the files followed by the watch command
+type watcher struct {
+	path   string
+	cfg    *config.InkwellConfig
+	poller *watch.Poller
+	cache  *processor.Cache
+}
+
+// runWatch rebuilds the book whenever the config or one of its source files changes.
+func runWatch(args []string) error {
+	flags := newFlagSet("watch", "Builds the book, then rebuilds it whenever the config or one of the source\n"+
+		"files it lists changes. Only the outputs of the scenes, chapters and sections\n"+
+		"which include a changed file are rewritten, along with the outputs of the book.\n"+
+		"Changes to the config, and files added to or removed from a directory or glob\n"+
+		"it lists, reload it and rebuild everything. Stop with Ctrl+C.")
+	path := flags.String("config", defaultConfig, "path to the config file")
+	interval := flags.Duration("interval", 250*time.Millisecond, "how often to check the files for changes")
+	debounce := flags.Duration("debounce", 300*time.Millisecond, "how long the files must be unchanged before rebuilding")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := &watcher{path: *path}
+	w.reload()
+
+	pending := map[string]bool{}
+	var last time.Time
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for _, file := range w.poller.Changes() {
+				pending[file] = true
+				last = now
+			}
+			// wait for a burst of saves to settle before rebuilding
+			if len(pending) == 0 || now.Sub(last) < *debounce {
+				continue
+			}
+
+			files := make([]string, 0, len(pending))
+			for file := range pending {
+				files = append(files, file)
+			}
+			sort.Strings(files)
+			pending = map[string]bool{}
+
+			// a changed directory may hold new files, so the config must be expanded again
+			if w.cfg == nil || contains(files, w.path) || containsAny(files, w.cfg.Directories()) {
+				w.reload()
+			} else {
+				w.rebuild(files)
+			}
+		}
+	}
+}
+
+// reload reads the config and builds the whole book, parsing every source file afresh.
+func (w *watcher) reload() {
+	start := time.Now()
+	cfg, err := config.NewInkwellConfig(w.path)
+	if err == nil {
+		w.cache = processor.NewCache()
+		err = processor.ProcessCached(*cfg, w.cache)
+	}
+	w.snapshot(cfg)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "inkwell watch: %v\n", err)
+		return
+	}
+	fmt.Printf("built %s in %s\n", cfg.Title, time.Since(start).Round(time.Millisecond))
+}
+
+// rebuild rebuilds the parts of the book which include or embed the changed files.
+func (w *watcher) rebuild(files []string) {
+	start := time.Now()
+	err := processor.ProcessChanges(*w.cfg, files, w.cache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "inkwell watch: %v\n", err)
+		return
+	}
+	for _, file := range files {
+		fmt.Printf("changed %s\n", file)
+	}
+	fmt.Printf("rebuilt in %s\n", time.Since(start).Round(time.Millisecond))
+}
+
+// snapshot records the config and starts following the files it lists, along with the
+// directories it discovered files in. The previous config
+// is kept if the new one could not be read.
+func (w *watcher) snapshot(cfg *config.InkwellConfig) {
+	if cfg != nil {
+		w.cfg = cfg
+	}
+	files := []string{w.path}
+	if w.cfg != nil {
+		files = append(files, w.cfg.SourceFiles()...)
+		files = append(files, w.cfg.Directories()...)
+	}
+	w.poller = watch.NewPoller(files)
+}
+
+// contains reports whether the files include the path.
+func contains(files []string, path string) bool {
+	for _, file := range files {
+		if filepath.Clean(file) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether the files include any of the paths.
+func containsAny(files []string, paths []string) bool {
+	for _, path := range paths {
+		if contains(files, path) {
+			return true
+		}
+	}
+	return false
+}
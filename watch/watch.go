@@ -0,0 +1,45 @@
+package watch
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// Poller is a struct that represents a set of files whose modification times are followed
+type Poller struct {
+	times map[string]time.Time
+}
+
+// NewPoller returns a poller which follows the given files from their current state.
+func NewPoller(files []string) *Poller {
+	p := &Poller{times: map[string]time.Time{}}
+	for _, file := range files {
+		p.times[file] = modTime(file)
+	}
+	return p
+}
+
+// Changes returns the followed files which have been modified, created or removed since
+// the last call, in sorted order.
+func (p *Poller) Changes() []string {
+	var files []string
+	for file, before := range p.times {
+		after := modTime(file)
+		if !after.Equal(before) {
+			files = append(files, file)
+			p.times[file] = after
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// modTime returns the modification time of the file, or the zero time if it does not exist.
+func modTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,34 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollerChanges(t *testing.T) {
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "edited.md")
+	untouched := filepath.Join(dir, "untouched.md")
+	created := filepath.Join(dir, "created.md")
+	_ = os.WriteFile(edited, []byte("one"), 0644)
+	_ = os.WriteFile(untouched, []byte("one"), 0644)
+
+	p := NewPoller([]string{edited, untouched, created})
+	if changes := p.Changes(); len(changes) != 0 {
+		t.Fatalf("Changes() = %v before any change, want none", changes)
+	}
+
+	later := time.Now().Add(time.Second)
+	_ = os.Chtimes(edited, later, later)
+	_ = os.WriteFile(created, []byte("new"), 0644)
+
+	changes := p.Changes()
+	if len(changes) != 2 || changes[0] != created || changes[1] != edited {
+		t.Errorf("Changes() = %v, want [%s %s]", changes, created, edited)
+	}
+	if changes := p.Changes(); len(changes) != 0 {
+		t.Errorf("Changes() = %v after the changes were returned, want none", changes)
+	}
+}
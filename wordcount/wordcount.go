@@ -0,0 +1,175 @@
+package wordcount
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The rules by which words are counted
+const (
+	// Word counts each run of characters between spaces as a word, as Microsoft Word does, so
+	// that words joined by a dash are one word and a dash between spaces is a word of its own
+	Word = "word"
+	// Scrivener counts the words between spaces and dashes, as Scrivener does, so that words
+	// joined by a dash are counted apart and a run without a letter or digit is not a word
+	Scrivener = "scrivener"
+	// Publisher counts words as Word does, and rounds the total of the book as publishers do
+	Publisher = "publisher"
+	// CJK counts each Chinese character and kana as a word, and the words between spaces in
+	// other scripts as Scrivener does
+	CJK = "cjk"
+)
+
+// The ways in which characters are counted
+const (
+	// Graphemes counts what a reader sees as a single character, such as a letter with an
+	// accent or an emoji made of several code points, as one character
+	Graphemes = "graphemes"
+	// Runes counts each Unicode code point as a character
+	Runes = "runes"
+)
+
+// Check returns an error if the rule or the way of counting characters is not known. Either
+// may be empty, for the default.
+func Check(rule string, characters string) error {
+	switch rule {
+	case "", Word, Scrivener, Publisher, CJK:
+	default:
+		return fmt.Errorf("invalid word count rule %q: want word, scrivener, publisher or cjk", rule)
+	}
+	switch characters {
+	case "", Graphemes, Runes:
+	default:
+		return fmt.Errorf("invalid character count %q: want graphemes or runes", characters)
+	}
+	return nil
+}
+
+// Words returns the number of words in the text by the given rule, which is Word if empty.
+func Words(text string, rule string) int {
+	switch rule {
+	case Scrivener:
+		return count(text, isScrivenerBreak, isCounted)
+	case CJK:
+		words := 0
+		for _, r := range text {
+			if isCJK(r) {
+				words++
+			}
+		}
+		return words + count(text, func(r rune) bool { return isScrivenerBreak(r) || isCJK(r) }, isCounted)
+	default:
+		return len(strings.Fields(text))
+	}
+}
+
+// count returns the number of the runs of the text between the breaks which are counted.
+func count(text string, isBreak func(rune) bool, counted func(string) bool) int {
+	words := 0
+	for _, word := range strings.FieldsFunc(text, isBreak) {
+		if counted(word) {
+			words++
+		}
+	}
+	return words
+}
+
+// isScrivenerBreak reports whether the rune separates words by the Scrivener rule.
+func isScrivenerBreak(r rune) bool {
+	return unicode.IsSpace(r) || r == '—' || r == '–' || r == '…'
+}
+
+// isCounted reports whether the run holds a letter or digit, and so is counted as a word.
+func isCounted(word string) bool {
+	return strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
+
+// isCJK reports whether the rune is a Chinese character or kana, which is a word of its own.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// Round rounds the word count the way manuscripts and publishers report it: to the nearest
+// hundred for short fiction, and to coarser increments as the manuscript grows. A count too
+// small to round to an increment is rounded up to it, but a book without words has none. It is
+// what the Publisher rule reports as the total of the book, and what the DOCX cover page shows.
+func Round(words int) int {
+	if words <= 0 {
+		return 0
+	}
+	increment := 100
+	switch {
+	case words >= 100000:
+		increment = 5000
+	case words >= 10000:
+		increment = 1000
+	case words >= 1000:
+		increment = 500
+	}
+
+	rounded := (words + increment/2) / increment * increment
+	if rounded == 0 {
+		rounded = increment
+	}
+	return rounded
+}
+
+// Characters returns the number of characters in the text, counted as graphemes unless runes
+// are asked for. A run of spaces counts as a single character, and line breaks are not counted.
+func Characters(text string, characters string) int {
+	total := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if characters == Runes {
+			total += len([]rune(line))
+		} else {
+			total += graphemes(line)
+		}
+	}
+	return total
+}
+
+// graphemes returns the number of grapheme clusters in the text, following the main rules of
+// Unicode text segmentation: combining marks, variation selectors and emoji modifiers extend
+// the character before them, a zero width joiner joins the characters on either side of it,
+// and regional indicators pair up into flags.
+func graphemes(text string) int {
+	clusters := 0
+	joined := false
+	regional := 0
+	for idx, r := range text {
+		switch {
+		case idx > 0 && (joined || isExtend(r)):
+		case isRegional(r) && regional%2 == 1:
+		default:
+			clusters++
+		}
+		joined = r == '‍'
+		if isRegional(r) {
+			regional++
+		} else {
+			regional = 0
+		}
+	}
+	return clusters
+}
+
+// isExtend reports whether the rune extends the character before it.
+func isExtend(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == '‍', r >= 0xfe00 && r <= 0xfe0f, r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f:
+		return true
+	case r >= 0x1160 && r <= 0x11ff:
+		// Hangul vowels and final consonants join the syllable before them
+		return true
+	}
+	return false
+}
+
+// isRegional reports whether the rune is a regional indicator, two of which make a flag.
+func isRegional(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
@@ -0,0 +1,98 @@
+package wordcount
+
+import "testing"
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		rule     string
+		expected int
+	}{
+		{name: "default", text: "Mara waited in the rain.", expected: 5},
+		{name: "word joins dashes", text: "She waited—and waited — in the well-lit hall…", rule: Word, expected: 8},
+		{name: "scrivener splits dashes", text: "She waited—and waited — in the well-lit hall…", rule: Scrivener, expected: 8},
+		{name: "scrivener skips punctuation", text: "Wait . . . what? * * *", rule: Scrivener, expected: 2},
+		{name: "publisher", text: "She waited—and waited.", rule: Publisher, expected: 3},
+		{name: "cjk", text: "我爱你。ありがとう Mara", rule: CJK, expected: 9},
+		{name: "cjk without spaces", text: "東京に行きました", rule: CJK, expected: 8},
+		{name: "word with cjk", text: "東京に行きました", rule: Word, expected: 1},
+		{name: "empty", text: " \n ", rule: Scrivener, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := Words(tt.text, tt.rule); result != tt.expected {
+				t.Errorf("Words(%q, %q) = %d, want %d", tt.text, tt.rule, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		words    int
+		expected int
+	}{
+		{words: 0, expected: 0},
+		{words: 1, expected: 100},
+		{words: 40, expected: 100},
+		{words: 449, expected: 400},
+		{words: 450, expected: 500},
+		{words: 3740, expected: 3500},
+		{words: 3760, expected: 4000},
+		{words: 24600, expected: 25000},
+		{words: 81234, expected: 81000},
+		{words: 112400, expected: 110000},
+	}
+
+	for _, tt := range tests {
+		if result := Round(tt.words); result != tt.expected {
+			t.Errorf("Round(%d) = %d, want %d", tt.words, result, tt.expected)
+		}
+	}
+}
+
+func TestCharacters(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		characters string
+		expected   int
+	}{
+		{name: "ascii", text: "Mara  waited.", expected: 12},
+		{name: "line breaks", text: "Mara\nwaited.\n", expected: 11},
+		{name: "accents", text: "café", characters: Graphemes, expected: 4},
+		{name: "combining accent", text: "cafe\u0301", characters: Graphemes, expected: 4},
+		{name: "combining accent as runes", text: "cafe\u0301", characters: Runes, expected: 5},
+		{name: "emoji with modifier", text: "hi 👋🏽", expected: 4},
+		{name: "family emoji", text: "👨‍👩‍👧", expected: 1},
+		{name: "family emoji as runes", text: "👨‍👩‍👧", characters: Runes, expected: 5},
+		{name: "flags", text: "🇳🇿🇯🇵", expected: 2},
+		{name: "cjk", text: "東京に行きました", expected: 8},
+		{name: "hangul jamo", text: "\u1112\u1161\u11ab", expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := Characters(tt.text, tt.characters); result != tt.expected {
+				t.Errorf("Characters(%q, %q) = %d, want %d", tt.text, tt.characters, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check("", ""); err != nil {
+		t.Errorf("Check() error = %v, want the defaults accepted", err)
+	}
+	if err := Check(CJK, Runes); err != nil {
+		t.Errorf("Check() error = %v, want cjk and runes accepted", err)
+	}
+	if err := Check("pages", ""); err == nil {
+		t.Errorf("Check() should fail for an unknown rule")
+	}
+	if err := Check("", "bytes"); err == nil {
+		t.Errorf("Check() should fail for an unknown way of counting characters")
+	}
+}